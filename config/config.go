@@ -6,23 +6,47 @@ import (
     "crypto/rand"
     "crypto/sha256"
     "encoding/base64"
+    "encoding/hex"
     "encoding/json"
     "errors"
     "io"
     "os"
     "path/filepath"
+    "sync"
     "time"
 )
 
 type SSHConfig struct {
-    Host              string `json:"host"`
-    Port              int    `json:"port"`
-    Username          string `json:"username,omitempty"`           // 运行时明文用户名
-    EncryptedUsername string `json:"encrypted_username,omitempty"` // 存储时加密用户名
-    Password          string `json:"password,omitempty"`           // 运行时明文密码
-    EncryptedPassword string `json:"encrypted_password,omitempty"` // 存储时加密密码
-    KeyPath           string `json:"key_path,omitempty"`
-    RemotePath        string `json:"remote_path"`
+    Host              string   `json:"host"`
+    Port              int      `json:"port"`
+    Username          string   `json:"username,omitempty"`           // 运行时明文用户名
+    EncryptedUsername string   `json:"encrypted_username,omitempty"` // 存储时加密用户名
+    Password          string   `json:"password,omitempty"`           // 运行时明文密码
+    EncryptedPassword string   `json:"encrypted_password,omitempty"` // 存储时加密密码
+    KeyPath           string   `json:"key_path,omitempty"`
+    KeyPassphrase     string   `json:"key_passphrase,omitempty"`           // 运行时明文私钥密码
+    EncryptedKeyPassphrase string `json:"encrypted_key_passphrase,omitempty"` // 存储时加密私钥密码
+    UseAgent          bool     `json:"use_agent,omitempty"`                // 通过 SSH_AUTH_SOCK 使用SSH代理认证
+    RemotePath        string   `json:"remote_path"`
+    HostKey           string   `json:"host_key,omitempty"`           // 已信任的主机公钥（authorized_keys格式）
+    PendingHostKey    string   `json:"pending_host_key,omitempty"`   // 检测到的新主机公钥，等待确认
+    TransferMode      string   `json:"transfer_mode,omitempty"`      // 文件传输方式: auto(默认), sftp, shell
+    MirrorDeletions   bool     `json:"mirror_deletions,omitempty"`   // 部署时删除本地已不存在的远程文件
+    ProtectedPaths    []string `json:"protected_paths,omitempty"`    // 镜像删除时保留的远程路径
+    ExcludePatterns   []string `json:"exclude_patterns,omitempty"`   // 部署排除规则（.gitignore 语法），追加在项目 .deployignore 之后
+    IncludePatterns   []string `json:"include_patterns,omitempty"`   // 部署包含规则，重新包含被排除的路径
+    ReleaseMode       bool     `json:"release_mode,omitempty"`       // 上传到 releases/<时间戳> 后切换 current 符号链接
+    KeepReleases      int      `json:"keep_releases,omitempty"`      // 保留的历史版本数，0 表示默认值
+    UploadWorkers     int      `json:"upload_workers,omitempty"`     // 并发上传数，0 表示默认值
+    BandwidthLimit    int64    `json:"bandwidth_limit,omitempty"`    // 上传带宽限制（字节/秒），0 表示不限制
+    PreDeployHooks    []DeployHook `json:"pre_deploy_hooks,omitempty"`  // 传输文件前在远程执行的命令
+    PostDeployHooks   []DeployHook `json:"post_deploy_hooks,omitempty"` // 传输文件后在远程执行的命令
+    FileMode          string   `json:"file_mode,omitempty"`          // 上传文件的权限（八进制），为空时与本地文件一致
+    DirMode           string   `json:"dir_mode,omitempty"`           // 创建目录的权限（八进制），为空时由远程umask决定
+    FileGroup         string   `json:"file_group,omitempty"`         // 上传文件和目录的属组，为空时不修改
+    SnapshotMode      string   `json:"snapshot_mode,omitempty"`      // 部署前快照: 空(不创建), remote(保存在服务器上), local(下载到本地)
+    KeepSnapshots     int      `json:"keep_snapshots,omitempty"`     // 保留的快照数，0 表示默认值
+    JumpHosts         []SSHConfig `json:"-"`                          // 运行时解析的跳板机链，按连接顺序
 }
 
 // 服务器配置结构
@@ -36,7 +60,46 @@ type ServerConfig struct {
     Password          string    `json:"password,omitempty"`           // 运行时明文密码
     EncryptedPassword string    `json:"encrypted_password,omitempty"` // 存储时加密密码
     KeyPath           string    `json:"key_path,omitempty"`           // 私钥路径
+    KeyPassphrase     string    `json:"key_passphrase,omitempty"`     // 运行时明文私钥密码
+    EncryptedKeyPassphrase string `json:"encrypted_key_passphrase,omitempty"` // 存储时加密私钥密码
+    UseAgent          bool      `json:"use_agent,omitempty"`          // 通过 SSH_AUTH_SOCK 使用SSH代理认证
     RemotePath        string    `json:"remote_path"`                  // 远程部署路径
+    HostKey           string    `json:"host_key,omitempty"`           // 已信任的主机公钥（authorized_keys格式）
+    PendingHostKey    string    `json:"pending_host_key,omitempty"`   // 检测到的新主机公钥，等待确认
+    TransferMode      string    `json:"transfer_mode,omitempty"`      // 文件传输方式: auto(默认), sftp, shell
+    MirrorDeletions   bool      `json:"mirror_deletions,omitempty"`   // 部署时删除本地已不存在的远程文件
+    ProtectedPaths    []string  `json:"protected_paths,omitempty"`    // 镜像删除时保留的远程路径
+    ExcludePatterns   []string  `json:"exclude_patterns,omitempty"`   // 部署排除规则（.gitignore 语法），追加在项目 .deployignore 之后
+    IncludePatterns   []string  `json:"include_patterns,omitempty"`   // 部署包含规则，重新包含被排除的路径
+    ReleaseMode       bool      `json:"release_mode,omitempty"`       // 上传到 releases/<时间戳> 后切换 current 符号链接
+    KeepReleases      int       `json:"keep_releases,omitempty"`      // 保留的历史版本数，0 表示默认值
+    UploadWorkers     int       `json:"upload_workers,omitempty"`     // 并发上传数，0 表示默认值
+    BandwidthLimit    int64     `json:"bandwidth_limit,omitempty"`    // 上传带宽限制（字节/秒），0 表示不限制
+    JumpServerID      string    `json:"jump_server_id,omitempty"`     // 跳板机：经由另一个服务器配置连接
+    PreDeployHooks    []DeployHook `json:"pre_deploy_hooks,omitempty"`  // 传输文件前在远程执行的命令
+    PostDeployHooks   []DeployHook `json:"post_deploy_hooks,omitempty"` // 传输文件后在远程执行的命令
+    FileMode          string    `json:"file_mode,omitempty"`          // 上传文件的权限（八进制），为空时与本地文件一致
+    DirMode           string    `json:"dir_mode,omitempty"`           // 创建目录的权限（八进制），为空时由远程umask决定
+    FileGroup         string    `json:"file_group,omitempty"`         // 上传文件和目录的属组，为空时不修改
+    SnapshotMode      string    `json:"snapshot_mode,omitempty"`      // 部署前快照: 空(不创建), remote(保存在服务器上), local(下载到本地)
+    KeepSnapshots     int       `json:"keep_snapshots,omitempty"`     // 保留的快照数，0 表示默认值
+    SmokeTest         bool      `json:"smoke_test,omitempty"`         // 部署后通过域名访问网站验证
+    SmokeTestAction   string    `json:"smoke_test_action,omitempty"`  // 验证失败时的处理: warn(默认), fail, rollback
+    SmokeTestSamples  int       `json:"smoke_test_samples,omitempty"` // 抽查的已上传页面数，0 表示默认值
+    Type              string    `json:"type,omitempty"`               // 部署目标类型: ssh(默认), local, s3
+    S3Endpoint        string    `json:"s3_endpoint,omitempty"`        // S3兼容存储地址，如 127.0.0.1:9000
+    S3Bucket          string    `json:"s3_bucket,omitempty"`          // 存储桶名称
+    S3Region          string    `json:"s3_region,omitempty"`          // 存储区域
+    S3AccessKey       string    `json:"s3_access_key,omitempty"`      // 运行时明文 Access Key
+    EncryptedS3AccessKey string `json:"encrypted_s3_access_key,omitempty"` // 存储时加密 Access Key
+    S3SecretKey       string    `json:"s3_secret_key,omitempty"`      // 运行时明文 Secret Key
+    EncryptedS3SecretKey string `json:"encrypted_s3_secret_key,omitempty"` // 存储时加密 Secret Key
+    S3UseSSL          bool      `json:"s3_use_ssl,omitempty"`         // 是否使用HTTPS连接
+    GitRemote         string    `json:"git_remote,omitempty"`         // Git远程仓库地址或本地裸仓库路径
+    GitBranch         string    `json:"git_branch,omitempty"`         // 部署分支，默认 gh-pages
+    Environment       string    `json:"environment,omitempty"`        // 所属部署环境ID
+    Group             string    `json:"group,omitempty"`              // 所属分组，用于按分组批量操作和汇总状态
+    Tags              []string  `json:"tags,omitempty"`               // 标签，用于按标签批量操作
     Domain            string    `json:"domain"`               // 网站域名
     Enabled           bool      `json:"enabled"`              // 是否启用
     CreatedAt         time.Time `json:"created_at"`           // 创建时间
@@ -58,6 +121,8 @@ type ServerDeploymentStatus struct {
     CanPause         bool       `json:"can_pause"`           // 是否可暂停
     CanResume        bool       `json:"can_resume"`          // 是否可继续
     CanStop          bool       `json:"can_stop"`            // 是否可停止
+    CurrentRelease   string     `json:"current_release,omitempty"` // 当前生效的版本（版本目录模式）
+    SmokeTest        *SmokeTestResult `json:"smoke_test,omitempty"` // 最近一次部署后验证的结果
 }
 
 // 多服务器部署配置
@@ -65,6 +130,8 @@ type MultiServerDeployment struct {
     Servers        []ServerConfig            `json:"servers"`          // 服务器列表
     StatusMap      map[string]ServerDeploymentStatus `json:"status_map"`       // 服务器状态映射
     GlobalSettings map[string]interface{}    `json:"global_settings"`  // 全局设置
+    Schedules      []Schedule                `json:"schedules,omitempty"` // 定时部署
+    Environments   []Environment             `json:"environments,omitempty"` // 部署环境（按提升顺序）
 }
 
 type UploadTask struct {
@@ -74,6 +141,9 @@ type UploadTask struct {
     Size             int64     `json:"size"`
     Completed        bool      `json:"completed"`
     CreatedAt        time.Time `json:"created_at"`
+    ServerID         string    `json:"server_id,omitempty"` // 所属服务器，单服务器部署为空
+    Offset           int64     `json:"offset,omitempty"`    // 分块上传已确认写入的字节数
+    Hash             string    `json:"hash,omitempty"`      // 分块上传时本地文件的SHA-256，文件变化后需从头上传
 }
 
 type ProgressInfo struct {
@@ -90,14 +160,22 @@ type ProgressInfo struct {
     UpdateTime  time.Time `json:"update_time"`  // 最后更新时间
 }
 
+// 服务器的上传队列和暂停状态，保存在配置文件中，程序重启后可以继续上传
+type UploadQueue struct {
+    Tasks       []UploadTask `json:"tasks,omitempty"`
+    IsPaused    bool         `json:"is_paused,omitempty"`   // 是否暂停
+    Interrupted bool         `json:"interrupted,omitempty"` // 程序退出时部署仍在进行
+    UpdatedAt   time.Time    `json:"updated_at"`
+}
+
 type DeploymentInfo struct {
     LastSyncTime     *time.Time    `json:"last_sync_time,omitempty"`
     LastSyncStatus   string        `json:"last_sync_status,omitempty"`   // "success", "failed", "building", "deploying", "paused"
     LastSyncMessage  string        `json:"last_sync_message,omitempty"`
     FilesDeployed    int           `json:"files_deployed,omitempty"`
     BytesTransferred int64         `json:"bytes_transferred,omitempty"`
-    UploadTasks      []UploadTask  `json:"upload_tasks,omitempty"`       // 待上传任务队列
-    IsPaused         bool          `json:"is_paused,omitempty"`          // 是否暂停
+    UploadTasks      []UploadTask  `json:"upload_tasks,omitempty"`       // 单服务器部署的待上传任务队列
+    IsPaused         bool          `json:"is_paused,omitempty"`          // 单服务器部署是否暂停
     Progress         ProgressInfo  `json:"progress,omitempty"`           // 实时进度信息
 }
 
@@ -106,6 +184,7 @@ type Config struct {
     SSH             SSHConfig      `json:"ssh"`
     Deployment      DeploymentInfo `json:"deployment"`
     MultiDeploy     MultiServerDeployment `json:"multi_deploy"` // 多服务器部署配置
+    UploadQueues    map[string]*UploadQueue `json:"upload_queues,omitempty"` // 各服务器的上传队列（多服务器部署）
     Language        string         `json:"language,omitempty"`        // 用户主动设置的语言
     UserSetLanguage bool           `json:"user_set_language,omitempty"` // 标记是否用户主动设置
 }
@@ -113,6 +192,12 @@ type Config struct {
 var currentConfig Config
 var decryptionKey string // 运行时解密密钥
 
+var (
+    saveMutex        sync.Mutex   // 串行化配置文件写入
+    multiDeployMutex sync.RWMutex // 保护多服务器配置和状态
+    uploadTasksMutex sync.Mutex   // 保护上传任务列表
+)
+
 func init() {
     LoadConfig()
 }
@@ -171,15 +256,75 @@ func LoadConfig() {
     }
 
     json.Unmarshal(data, &currentConfig)
+    migrateUploadTasks()
+}
+
+// 旧版本把所有服务器的上传任务保存在同一个列表中，按服务器拆分到各自的队列
+func migrateUploadTasks() {
+    var singleServer []UploadTask
+    for _, task := range currentConfig.Deployment.UploadTasks {
+        if task.ServerID == "" {
+            singleServer = append(singleServer, task)
+            continue
+        }
+        queue := serverUploadQueue(task.ServerID)
+        queue.Tasks = append(queue.Tasks, task)
+    }
+    currentConfig.Deployment.UploadTasks = singleServer
 }
 
 func SaveConfig() {
-    data, err := json.MarshalIndent(currentConfig, "", "  ")
+    saveMutex.Lock()
+    defer saveMutex.Unlock()
+    
+    multiDeployMutex.RLock()
+    uploadTasksMutex.Lock()
+    // 已加密的凭据在运行时解密为明文，保存时清除明文，只保存加密后的版本
+    configToSave := currentConfig
+    if configToSave.SSH.EncryptedUsername != "" {
+        configToSave.SSH.Username = ""
+    }
+    if configToSave.SSH.EncryptedPassword != "" {
+        configToSave.SSH.Password = ""
+    }
+    if configToSave.SSH.EncryptedKeyPassphrase != "" {
+        configToSave.SSH.KeyPassphrase = ""
+    }
+    data, err := json.MarshalIndent(configToSave, "", "  ")
+    uploadTasksMutex.Unlock()
+    multiDeployMutex.RUnlock()
     if err != nil {
         return
     }
     
-    os.WriteFile("config.json", data, 0644)
+    writeConfigFile(data)
+}
+
+// 写入config.json：先写临时文件再重命名，写入过程中崩溃不会截断原有配置
+// 上传任务的偏移等在部署过程中频繁保存，直接覆盖写入时中断会丢失所有服务器配置
+func writeConfigFile(data []byte) error {
+    tmpPath := "config.json.tmp"
+    file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+    if err != nil {
+        return err
+    }
+    if _, err := file.Write(data); err != nil {
+        file.Close()
+        return err
+    }
+    if err := file.Sync(); err != nil {
+        file.Close()
+        return err
+    }
+    if err := file.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmpPath, "config.json")
+}
+
+// 获取运行时数据目录（部署内容清单等），与config.json位于同一目录
+func GetDataDir() string {
+    return ".hugo-manager"
 }
 
 func GetHugoProjectPath() string {
@@ -243,15 +388,19 @@ func SetDeploymentStats(filesDeployed int, bytesTransferred int64) {
 
 // 加密函数
 func encrypt(plaintext, password string) (string, error) {
+    // 使用密码生成密钥
+    key := sha256.Sum256([]byte(password))
+    return encryptWithKey(plaintext, key[:])
+}
+
+// 使用32字节密钥加密
+func encryptWithKey(plaintext string, key []byte) (string, error) {
     if plaintext == "" {
         return "", nil
     }
     
-    // 使用密码生成密钥
-    key := sha256.Sum256([]byte(password))
-    
     // 创建AES加密器
-    block, err := aes.NewCipher(key[:])
+    block, err := aes.NewCipher(key)
     if err != nil {
         return "", err
     }
@@ -277,6 +426,13 @@ func encrypt(plaintext, password string) (string, error) {
 
 // 解密函数
 func decrypt(ciphertext, password string) (string, error) {
+    // 使用密码生成密钥
+    key := sha256.Sum256([]byte(password))
+    return decryptWithKey(ciphertext, key[:])
+}
+
+// 使用32字节密钥解密
+func decryptWithKey(ciphertext string, key []byte) (string, error) {
     if ciphertext == "" {
         return "", nil
     }
@@ -287,11 +443,8 @@ func decrypt(ciphertext, password string) (string, error) {
         return "", err
     }
     
-    // 使用密码生成密钥
-    key := sha256.Sum256([]byte(password))
-    
     // 创建AES加密器
-    block, err := aes.NewCipher(key[:])
+    block, err := aes.NewCipher(key)
     if err != nil {
         return "", err
     }
@@ -347,11 +500,30 @@ func SetDecryptionKey(key string) error {
         }
     }
     
+    // 解密私钥密码
+    if currentConfig.SSH.EncryptedKeyPassphrase != "" {
+        passphrase, err := decrypt(currentConfig.SSH.EncryptedKeyPassphrase, decryptionKey)
+        if err != nil {
+            decryptionErrors = append(decryptionErrors, err)
+        } else {
+            currentConfig.SSH.KeyPassphrase = passphrase
+        }
+    }
+    
+    // 解密服务器凭据
+    for _, server := range GetServerConfigs() {
+        if _, err := DecryptServerConfig(server, decryptionKey); err != nil {
+            decryptionErrors = append(decryptionErrors, err)
+            break
+        }
+    }
+    
     // 如果有任何解密错误，重置密钥
     if len(decryptionErrors) > 0 {
         decryptionKey = ""
         currentConfig.SSH.Username = ""
         currentConfig.SSH.Password = ""
+        currentConfig.SSH.KeyPassphrase = ""
         return errors.New("解密密钥错误")
     }
     
@@ -363,9 +535,33 @@ func IsDecryptionKeySet() bool {
     return decryptionKey != ""
 }
 
+// 检查主密码能否解密已加密的SSH凭据和服务器凭据，避免用不同的主密码加密新的凭据
+func VerifyMasterPassword(masterPassword string) error {
+    if decryptionKey != "" && decryptionKey != masterPassword {
+        return errors.New("主密码错误")
+    }
+    ciphertexts := []string{
+        currentConfig.SSH.EncryptedUsername,
+        currentConfig.SSH.EncryptedPassword,
+        currentConfig.SSH.EncryptedKeyPassphrase,
+    }
+    for _, ciphertext := range ciphertexts {
+        if _, err := decrypt(ciphertext, masterPassword); err != nil {
+            return errors.New("主密码错误")
+        }
+    }
+    for _, server := range GetServerConfigs() {
+        if _, err := DecryptServerConfig(server, masterPassword); err != nil {
+            return errors.New("主密码错误")
+        }
+    }
+    return nil
+}
+
 // 检查是否有加密的SSH凭据
 func HasEncryptedSSHCredentials() bool {
-    return currentConfig.SSH.EncryptedUsername != "" || currentConfig.SSH.EncryptedPassword != ""
+    return currentConfig.SSH.EncryptedUsername != "" || currentConfig.SSH.EncryptedPassword != "" ||
+           currentConfig.SSH.EncryptedKeyPassphrase != ""
 }
 
 // 检查SSH凭据是否需要解密
@@ -376,7 +572,8 @@ func NeedsDecryption() bool {
 // 检查是否有明文的SSH凭据
 func HasPlaintextSSHCredentials() bool {
     return (currentConfig.SSH.Username != "" && currentConfig.SSH.EncryptedUsername == "") ||
-           (currentConfig.SSH.Password != "" && currentConfig.SSH.EncryptedPassword == "")
+           (currentConfig.SSH.Password != "" && currentConfig.SSH.EncryptedPassword == "") ||
+           (currentConfig.SSH.KeyPassphrase != "" && currentConfig.SSH.EncryptedKeyPassphrase == "")
 }
 
 // 加密现有的明文凭据
@@ -404,6 +601,16 @@ func EncryptExistingCredentials(masterPassword string) error {
         modified = true
     }
     
+    // 如果有明文私钥密码且没有加密版本，进行加密
+    if ssh.KeyPassphrase != "" && ssh.EncryptedKeyPassphrase == "" {
+        encryptedPassphrase, err := encrypt(ssh.KeyPassphrase, masterPassword)
+        if err != nil {
+            return err
+        }
+        ssh.EncryptedKeyPassphrase = encryptedPassphrase
+        modified = true
+    }
+    
     if modified {
         currentConfig.SSH = ssh
         // 设置解密密钥以便后续使用
@@ -437,79 +644,245 @@ func SetSSHConfigWithEncryption(ssh SSHConfig, masterPassword string) error {
         ssh.Password = "" // 清除明文密码，不保存到文件
     }
     
+    // 加密私钥密码
+    if ssh.KeyPassphrase != "" {
+        encryptedPassphrase, err := encrypt(ssh.KeyPassphrase, masterPassword)
+        if err != nil {
+            return err
+        }
+        ssh.EncryptedKeyPassphrase = encryptedPassphrase
+        ssh.KeyPassphrase = "" // 清除明文私钥密码，不保存到文件
+    }
+    
     currentConfig.SSH = ssh
     return SaveConfigWithEncryption()
 }
 
 // 保存配置时加密敏感信息
 func SaveConfigWithEncryption() error {
-    // 创建配置副本用于保存
-    configToSave := currentConfig
+    saveMutex.Lock()
+    defer saveMutex.Unlock()
     
-    // 清除运行时明文凭据，只保存加密后的版本
+    multiDeployMutex.RLock()
+    uploadTasksMutex.Lock()
+    // 创建配置副本用于保存，清除运行时明文凭据，只保存加密后的版本
+    configToSave := currentConfig
     configToSave.SSH.Username = ""
     configToSave.SSH.Password = ""
-    
+    configToSave.SSH.KeyPassphrase = ""
     data, err := json.MarshalIndent(configToSave, "", "  ")
+    uploadTasksMutex.Unlock()
+    multiDeployMutex.RUnlock()
     if err != nil {
         return err
     }
     
-    return os.WriteFile("config.json", data, 0644)
+    return writeConfigFile(data)
 }
 
 // 上传任务管理函数
+// 每个服务器有独立的上传队列（单服务器部署的服务器ID为空），多个服务器同时部署时互不影响
 func SetUploadTasks(tasks []UploadTask) {
-    currentConfig.Deployment.UploadTasks = tasks
-    SaveConfig()
+    SetServerUploadTasks("", tasks)
 }
 
 func GetUploadTasks() []UploadTask {
-    return currentConfig.Deployment.UploadTasks
+    return GetServerUploadTasks("")
+}
+
+// 获取服务器的上传队列，不存在时创建，调用方需持有 uploadTasksMutex
+func serverUploadQueue(serverID string) *UploadQueue {
+    if currentConfig.UploadQueues == nil {
+        currentConfig.UploadQueues = make(map[string]*UploadQueue)
+    }
+    queue, exists := currentConfig.UploadQueues[serverID]
+    if !exists {
+        queue = &UploadQueue{}
+        currentConfig.UploadQueues[serverID] = queue
+    }
+    return queue
+}
+
+// 获取服务器的上传任务列表，调用方需持有 uploadTasksMutex
+func serverUploadTaskList(serverID string) *[]UploadTask {
+    if serverID == "" {
+        return &currentConfig.Deployment.UploadTasks
+    }
+    queue := serverUploadQueue(serverID)
+    queue.UpdatedAt = time.Now()
+    return &queue.Tasks
+}
+
+// 删除没有任务且未暂停的队列，调用方需持有 uploadTasksMutex
+func pruneUploadQueue(serverID string) {
+    if queue, exists := currentConfig.UploadQueues[serverID]; exists && len(queue.Tasks) == 0 && !queue.IsPaused {
+        delete(currentConfig.UploadQueues, serverID)
+    }
+}
+
+// 替换指定服务器的上传任务列表
+func SetServerUploadTasks(serverID string, tasks []UploadTask) {
+    uploadTasksMutex.Lock()
+    list := serverUploadTaskList(serverID)
+    *list = nil
+    for _, task := range tasks {
+        task.ServerID = serverID
+        *list = append(*list, task)
+    }
+    if serverID != "" {
+        currentConfig.UploadQueues[serverID].Interrupted = false
+        pruneUploadQueue(serverID)
+    }
+    uploadTasksMutex.Unlock()
+    
+    SaveConfig()
+}
+
+// 获取指定服务器的上传任务列表
+func GetServerUploadTasks(serverID string) []UploadTask {
+    uploadTasksMutex.Lock()
+    defer uploadTasksMutex.Unlock()
+    
+    if serverID == "" {
+        return append([]UploadTask(nil), currentConfig.Deployment.UploadTasks...)
+    }
+    if queue, exists := currentConfig.UploadQueues[serverID]; exists {
+        return append([]UploadTask(nil), queue.Tasks...)
+    }
+    return nil
 }
 
 func AddUploadTask(task UploadTask) {
-    currentConfig.Deployment.UploadTasks = append(currentConfig.Deployment.UploadTasks, task)
+    uploadTasksMutex.Lock()
+    list := serverUploadTaskList(task.ServerID)
+    *list = append(*list, task)
+    uploadTasksMutex.Unlock()
+    
     SaveConfig()
 }
 
-func MarkTaskCompleted(taskID string) {
-    for i := range currentConfig.Deployment.UploadTasks {
-        if currentConfig.Deployment.UploadTasks[i].ID == taskID {
-            currentConfig.Deployment.UploadTasks[i].Completed = true
+func MarkTaskCompleted(serverID, taskID string) {
+    MarkTasksCompleted(serverID, []string{taskID})
+}
+
+// 记录分块上传的进度
+func SetUploadTaskOffset(serverID, taskID string, offset int64, hash string) {
+    uploadTasksMutex.Lock()
+    list := serverUploadTaskList(serverID)
+    for i := range *list {
+        if (*list)[i].ID == taskID {
+            (*list)[i].Offset = offset
+            (*list)[i].Hash = hash
             break
         }
     }
+    uploadTasksMutex.Unlock()
+    
+    SaveConfig()
+}
+
+// 批量标记上传任务为已完成，只保存一次配置
+func MarkTasksCompleted(serverID string, taskIDs []string) {
+    if len(taskIDs) == 0 {
+        return
+    }
+    
+    completed := make(map[string]bool, len(taskIDs))
+    for _, id := range taskIDs {
+        completed[id] = true
+    }
+    
+    uploadTasksMutex.Lock()
+    list := serverUploadTaskList(serverID)
+    for i := range *list {
+        if completed[(*list)[i].ID] {
+            (*list)[i].Completed = true
+        }
+    }
+    uploadTasksMutex.Unlock()
+    
     SaveConfig()
 }
 
 func RemoveCompletedTasks() {
+    RemoveServerCompletedTasks("")
+}
+
+// 清除指定服务器已完成的上传任务
+func RemoveServerCompletedTasks(serverID string) {
+    uploadTasksMutex.Lock()
+    list := serverUploadTaskList(serverID)
     var pendingTasks []UploadTask
-    for _, task := range currentConfig.Deployment.UploadTasks {
+    for _, task := range *list {
         if !task.Completed {
             pendingTasks = append(pendingTasks, task)
         }
     }
-    currentConfig.Deployment.UploadTasks = pendingTasks
+    *list = pendingTasks
+    pruneUploadQueue(serverID)
+    uploadTasksMutex.Unlock()
+    
     SaveConfig()
 }
 
 func SetDeploymentPaused(paused bool) {
+    uploadTasksMutex.Lock()
     currentConfig.Deployment.IsPaused = paused
     if paused {
         currentConfig.Deployment.LastSyncStatus = "paused"
         currentConfig.Deployment.LastSyncMessage = "上传已暂停"
     }
+    uploadTasksMutex.Unlock()
     SaveConfig()
 }
 
 func IsDeploymentPaused() bool {
-    return currentConfig.Deployment.IsPaused
+    return IsServerDeploymentPaused("")
 }
 
+// 设置指定服务器的暂停状态，正在上传的任务会在当前文件或批次完成后停止
+func SetServerDeploymentPaused(serverID string, paused bool) {
+    if serverID == "" {
+        SetDeploymentPaused(paused)
+        return
+    }
+    
+    uploadTasksMutex.Lock()
+    queue := serverUploadQueue(serverID)
+    queue.IsPaused = paused
+    queue.UpdatedAt = time.Now()
+    if !paused {
+        queue.Interrupted = false
+    }
+    pruneUploadQueue(serverID)
+    uploadTasksMutex.Unlock()
+    
+    SaveConfig()
+}
+
+// 指定服务器的部署是否已暂停
+func IsServerDeploymentPaused(serverID string) bool {
+    uploadTasksMutex.Lock()
+    defer uploadTasksMutex.Unlock()
+    
+    if serverID == "" {
+        return currentConfig.Deployment.IsPaused
+    }
+    if queue, exists := currentConfig.UploadQueues[serverID]; exists {
+        return queue.IsPaused
+    }
+    return false
+}
+
+// 单服务器部署未完成的上传任务数
 func GetPendingTasksCount() int {
+    return GetServerPendingTasksCount("")
+}
+
+// 指定服务器未完成的上传任务数
+func GetServerPendingTasksCount(serverID string) int {
     count := 0
-    for _, task := range currentConfig.Deployment.UploadTasks {
+    for _, task := range GetServerUploadTasks(serverID) {
         if !task.Completed {
             count++
         }
@@ -517,6 +890,20 @@ func GetPendingTasksCount() int {
     return count
 }
 
+// 获取所有服务器的上传队列（副本），键为服务器ID
+func GetUploadQueues() map[string]UploadQueue {
+    uploadTasksMutex.Lock()
+    defer uploadTasksMutex.Unlock()
+    
+    queues := make(map[string]UploadQueue, len(currentConfig.UploadQueues))
+    for serverID, queue := range currentConfig.UploadQueues {
+        snapshot := *queue
+        snapshot.Tasks = append([]UploadTask(nil), queue.Tasks...)
+        queues[serverID] = snapshot
+    }
+    return queues
+}
+
 // 进度管理函数
 func UpdateProgress(progressType, status, message string, progress, total, current int, currentFile, speed, eta string) {
     now := time.Now()
@@ -579,22 +966,32 @@ func GetTaskDuration() time.Duration {
 }
 
 // 多服务器部署管理函数
+// 多个服务器可以同时部署，服务器列表和状态的读写都需要持有 multiDeployMutex
 func GetMultiServerDeployment() MultiServerDeployment {
-    if currentConfig.MultiDeploy.StatusMap == nil {
-        currentConfig.MultiDeploy.StatusMap = make(map[string]ServerDeploymentStatus)
-    }
-    return currentConfig.MultiDeploy
+    multiDeployMutex.RLock()
+    defer multiDeployMutex.RUnlock()
+    
+    deployment := currentConfig.MultiDeploy
+    deployment.Servers = append([]ServerConfig(nil), currentConfig.MultiDeploy.Servers...)
+    deployment.StatusMap = copyStatusMap(currentConfig.MultiDeploy.StatusMap)
+    deployment.Schedules = append([]Schedule(nil), currentConfig.MultiDeploy.Schedules...)
+    deployment.Environments = append([]Environment(nil), currentConfig.MultiDeploy.Environments...)
+    return deployment
 }
 
 func GetServerConfigs() []ServerConfig {
-    return currentConfig.MultiDeploy.Servers
+    multiDeployMutex.RLock()
+    defer multiDeployMutex.RUnlock()
+    return append([]ServerConfig(nil), currentConfig.MultiDeploy.Servers...)
 }
 
 func AddServerConfig(server ServerConfig) {
     if server.ID == "" {
-        server.ID = generateServerID()
+        server.ID = GenerateServerID()
     }
     server.CreatedAt = time.Now()
+    
+    multiDeployMutex.Lock()
     currentConfig.MultiDeploy.Servers = append(currentConfig.MultiDeploy.Servers, server)
     
     // 初始化服务器状态
@@ -608,38 +1005,70 @@ func AddServerConfig(server ServerConfig) {
         Progress:   0,
         UpdateTime: time.Now(),
     }
+    multiDeployMutex.Unlock()
     
     SaveConfig()
 }
 
-func UpdateServerConfig(serverID string, server ServerConfig) error {
-    for i, s := range currentConfig.MultiDeploy.Servers {
-        if s.ID == serverID {
-            server.ID = serverID
-            server.CreatedAt = s.CreatedAt // 保留创建时间
-            currentConfig.MultiDeploy.Servers[i] = server
-            SaveConfig()
-            return nil
+// 修改指定服务器的配置并保存，服务器不存在时返回错误
+func updateServer(serverID string, update func(server *ServerConfig)) error {
+    multiDeployMutex.Lock()
+    found := false
+    for i := range currentConfig.MultiDeploy.Servers {
+        if currentConfig.MultiDeploy.Servers[i].ID == serverID {
+            update(&currentConfig.MultiDeploy.Servers[i])
+            found = true
+            break
         }
     }
-    return errors.New("server not found")
+    multiDeployMutex.Unlock()
+    
+    if !found {
+        return errors.New("server not found")
+    }
+    SaveConfig()
+    return nil
+}
+
+func UpdateServerConfig(serverID string, server ServerConfig) error {
+    return updateServer(serverID, func(s *ServerConfig) {
+        server.ID = serverID
+        server.CreatedAt = s.CreatedAt // 保留创建时间
+        *s = server
+    })
 }
 
 func DeleteServerConfig(serverID string) error {
+    multiDeployMutex.Lock()
+    found := false
     for i, s := range currentConfig.MultiDeploy.Servers {
         if s.ID == serverID {
             // 删除服务器配置
             currentConfig.MultiDeploy.Servers = append(currentConfig.MultiDeploy.Servers[:i], currentConfig.MultiDeploy.Servers[i+1:]...)
             // 删除对应的状态
             delete(currentConfig.MultiDeploy.StatusMap, serverID)
-            SaveConfig()
-            return nil
+            found = true
+            break
         }
     }
-    return errors.New("server not found")
+    multiDeployMutex.Unlock()
+    
+    if !found {
+        return errors.New("server not found")
+    }
+    // 删除对应的上传队列
+    uploadTasksMutex.Lock()
+    delete(currentConfig.UploadQueues, serverID)
+    uploadTasksMutex.Unlock()
+    
+    SaveConfig()
+    return nil
 }
 
 func GetServerConfig(serverID string) (ServerConfig, error) {
+    multiDeployMutex.RLock()
+    defer multiDeployMutex.RUnlock()
+    
     for _, s := range currentConfig.MultiDeploy.Servers {
         if s.ID == serverID {
             return s, nil
@@ -648,24 +1077,129 @@ func GetServerConfig(serverID string) (ServerConfig, error) {
     return ServerConfig{}, errors.New("server not found")
 }
 
+// 更新服务器的最后部署时间
+func SetServerLastDeployment(serverID string, deployedAt time.Time) {
+    updateServer(serverID, func(s *ServerConfig) {
+        s.LastDeployment = &deployedAt
+    })
+}
+
+// 将服务器配置转换为SSH连接配置，加密存储的凭据使用运行时解密密钥解密
+func ServerToSSHConfig(server ServerConfig) SSHConfig {
+    server = WithDecryptedCredentials(server)
+    return SSHConfig{
+        Host:            server.Host,
+        Port:            server.Port,
+        Username:        server.Username,
+        Password:        server.Password,
+        KeyPath:         server.KeyPath,
+        KeyPassphrase:   server.KeyPassphrase,
+        UseAgent:        server.UseAgent,
+        RemotePath:      server.RemotePath,
+        HostKey:         server.HostKey,
+        PendingHostKey:  server.PendingHostKey,
+        TransferMode:    server.TransferMode,
+        MirrorDeletions: server.MirrorDeletions,
+        ProtectedPaths:  server.ProtectedPaths,
+        ExcludePatterns: server.ExcludePatterns,
+        IncludePatterns: server.IncludePatterns,
+        ReleaseMode:     server.ReleaseMode,
+        KeepReleases:    server.KeepReleases,
+        UploadWorkers:   server.UploadWorkers,
+        BandwidthLimit:  server.BandwidthLimit,
+        PreDeployHooks:  server.PreDeployHooks,
+        PostDeployHooks: server.PostDeployHooks,
+        FileMode:        server.FileMode,
+        DirMode:         server.DirMode,
+        FileGroup:       server.FileGroup,
+        SnapshotMode:    server.SnapshotMode,
+        KeepSnapshots:   server.KeepSnapshots,
+    }
+}
+
+// 信任服务器主机密钥，同时清除待确认的密钥
+func SetServerHostKey(serverID, hostKey string) error {
+    return updateServer(serverID, func(s *ServerConfig) {
+        s.HostKey = hostKey
+        s.PendingHostKey = ""
+    })
+}
+
+// 记录服务器出现的新主机密钥，等待用户确认
+func SetServerPendingHostKey(serverID, hostKey string) error {
+    return updateServer(serverID, func(s *ServerConfig) {
+        s.PendingHostKey = hostKey
+    })
+}
+
+// 信任单服务器SSH配置的主机密钥
+func SetSSHHostKey(hostKey string) {
+    currentConfig.SSH.HostKey = hostKey
+    currentConfig.SSH.PendingHostKey = ""
+    SaveConfig()
+}
+
+// 记录单服务器SSH配置出现的新主机密钥
+func SetSSHPendingHostKey(hostKey string) {
+    currentConfig.SSH.PendingHostKey = hostKey
+    SaveConfig()
+}
+
 func UpdateServerDeploymentStatus(serverID string, status ServerDeploymentStatus) {
+    multiDeployMutex.Lock()
     if currentConfig.MultiDeploy.StatusMap == nil {
         currentConfig.MultiDeploy.StatusMap = make(map[string]ServerDeploymentStatus)
     }
     status.ServerID = serverID
     status.UpdateTime = time.Now()
+    // 未指定时保留当前生效的版本
+    if status.CurrentRelease == "" {
+        status.CurrentRelease = currentConfig.MultiDeploy.StatusMap[serverID].CurrentRelease
+    }
     currentConfig.MultiDeploy.StatusMap[serverID] = status
+    multiDeployMutex.Unlock()
+    
     SaveConfig()
 }
 
-func GetServerDeploymentStatus(serverID string) ServerDeploymentStatus {
+// 更新服务器当前生效的版本
+func SetServerCurrentRelease(serverID, release string) {
+    multiDeployMutex.Lock()
     if currentConfig.MultiDeploy.StatusMap == nil {
-        return ServerDeploymentStatus{
-            ServerID: serverID,
-            Status:   "idle",
-            Message:  "等待部署",
-        }
+        currentConfig.MultiDeploy.StatusMap = make(map[string]ServerDeploymentStatus)
     }
+    status := currentConfig.MultiDeploy.StatusMap[serverID]
+    status.ServerID = serverID
+    status.CurrentRelease = release
+    if status.Status == "" {
+        status.Status = "idle"
+    }
+    status.UpdateTime = time.Now()
+    currentConfig.MultiDeploy.StatusMap[serverID] = status
+    multiDeployMutex.Unlock()
+    
+    SaveConfig()
+}
+
+// 更新服务器部署的实时传输速度
+// 速度每秒更新，只保存在内存中，随下一次状态变化写入配置文件
+func SetServerDeploymentSpeed(serverID, speed string) {
+    multiDeployMutex.Lock()
+    defer multiDeployMutex.Unlock()
+    
+    status, exists := currentConfig.MultiDeploy.StatusMap[serverID]
+    if !exists {
+        return
+    }
+    status.Speed = speed
+    status.UpdateTime = time.Now()
+    currentConfig.MultiDeploy.StatusMap[serverID] = status
+}
+
+func GetServerDeploymentStatus(serverID string) ServerDeploymentStatus {
+    multiDeployMutex.RLock()
+    defer multiDeployMutex.RUnlock()
+    
     if status, exists := currentConfig.MultiDeploy.StatusMap[serverID]; exists {
         return status
     }
@@ -676,16 +1210,27 @@ func GetServerDeploymentStatus(serverID string) ServerDeploymentStatus {
     }
 }
 
+// 获取所有服务器状态（副本）
 func GetAllServerStatuses() map[string]ServerDeploymentStatus {
-    if currentConfig.MultiDeploy.StatusMap == nil {
-        currentConfig.MultiDeploy.StatusMap = make(map[string]ServerDeploymentStatus)
+    multiDeployMutex.RLock()
+    defer multiDeployMutex.RUnlock()
+    return copyStatusMap(currentConfig.MultiDeploy.StatusMap)
+}
+
+func copyStatusMap(statusMap map[string]ServerDeploymentStatus) map[string]ServerDeploymentStatus {
+    statuses := make(map[string]ServerDeploymentStatus, len(statusMap))
+    for serverID, status := range statusMap {
+        statuses[serverID] = status
     }
-    return currentConfig.MultiDeploy.StatusMap
+    return statuses
 }
 
 // 生成服务器ID
-func generateServerID() string {
-    return "server_" + time.Now().Format("20060102150405")
+func GenerateServerID() string {
+    // 加随机后缀，避免同一秒内（如批量导入）生成重复的ID
+    suffix := make([]byte, 3)
+    rand.Read(suffix)
+    return "server_" + time.Now().Format("20060102150405") + "_" + hex.EncodeToString(suffix)
 }
 
 // 加密服务器配置中的敏感信息
@@ -710,6 +1255,34 @@ func SetServerConfigWithEncryption(serverID string, server ServerConfig, masterP
         server.Password = "" // 清除明文
     }
     
+    // 加密私钥密码
+    if server.KeyPassphrase != "" {
+        encryptedPassphrase, err := encrypt(server.KeyPassphrase, masterPassword)
+        if err != nil {
+            return err
+        }
+        server.EncryptedKeyPassphrase = encryptedPassphrase
+        server.KeyPassphrase = "" // 清除明文
+    }
+    
+    // 加密S3密钥
+    if server.S3AccessKey != "" {
+        encryptedAccessKey, err := encrypt(server.S3AccessKey, masterPassword)
+        if err != nil {
+            return err
+        }
+        server.EncryptedS3AccessKey = encryptedAccessKey
+        server.S3AccessKey = "" // 清除明文
+    }
+    if server.S3SecretKey != "" {
+        encryptedSecretKey, err := encrypt(server.S3SecretKey, masterPassword)
+        if err != nil {
+            return err
+        }
+        server.EncryptedS3SecretKey = encryptedSecretKey
+        server.S3SecretKey = "" // 清除明文
+    }
+    
     // 更新服务器配置
     if serverID == "" {
         AddServerConfig(server)
@@ -720,6 +1293,111 @@ func SetServerConfigWithEncryption(serverID string, server ServerConfig, masterP
     return nil
 }
 
+// 是否为新的或修改过的明文凭据
+func changedSecret(value, existing string) bool {
+    return value != "" && value != existing
+}
+
+// 保存服务器配置，serverID 为空时添加服务器
+// 提供了主密码或已解锁时加密保存凭据；否则用户名以明文保存，新的密码、私钥密码和S3密钥不能保存
+// 旧版本以明文保存的凭据未修改时仍可保存，提供主密码后加密
+func SaveServerConfig(serverID string, server ServerConfig, masterPassword string) error {
+    if masterPassword != "" {
+        if err := VerifyMasterPassword(masterPassword); err != nil {
+            return err
+        }
+    } else {
+        masterPassword = decryptionKey
+    }
+    
+    if masterPassword == "" {
+        var existing ServerConfig
+        if serverID != "" {
+            existing, _ = GetServerConfig(serverID)
+        }
+        if changedSecret(server.Password, existing.Password) || changedSecret(server.KeyPassphrase, existing.KeyPassphrase) ||
+           changedSecret(server.S3AccessKey, existing.S3AccessKey) || changedSecret(server.S3SecretKey, existing.S3SecretKey) {
+            return errors.New("保存密码或密钥需要提供主密码进行加密")
+        }
+        if serverID == "" {
+            AddServerConfig(server)
+            return nil
+        }
+        return UpdateServerConfig(serverID, server)
+    }
+    
+    if err := SetServerConfigWithEncryption(serverID, server, masterPassword); err != nil {
+        return err
+    }
+    // 设置解密密钥以便立即可用
+    if decryptionKey == "" {
+        SetDecryptionKey(masterPassword)
+    }
+    return nil
+}
+
+// 服务器是否有加密存储的凭据
+func (s ServerConfig) HasEncryptedCredentials() bool {
+    return s.EncryptedUsername != "" || s.EncryptedPassword != "" || s.EncryptedKeyPassphrase != "" ||
+        s.EncryptedS3AccessKey != "" || s.EncryptedS3SecretKey != ""
+}
+
+// 服务器是否有需要用主密码加密的明文凭据（用户名、密码、私钥密码、S3密钥）
+func (s ServerConfig) HasSecretCredentials() bool {
+    return s.Username != "" || s.Password != "" || s.KeyPassphrase != "" || s.S3AccessKey != "" || s.S3SecretKey != ""
+}
+
+// 沿用原配置中加密存储的凭据：留空的凭据保留原来的加密值，重新填写的凭据以明文保存
+func KeepEncryptedCredentials(server *ServerConfig, existing ServerConfig) {
+    server.EncryptedUsername, server.EncryptedPassword, server.EncryptedKeyPassphrase = "", "", ""
+    server.EncryptedS3AccessKey, server.EncryptedS3SecretKey = "", ""
+    if server.Username == "" {
+        server.EncryptedUsername = existing.EncryptedUsername
+    }
+    if server.Password == "" {
+        server.EncryptedPassword = existing.EncryptedPassword
+    }
+    if server.KeyPassphrase == "" {
+        server.EncryptedKeyPassphrase = existing.EncryptedKeyPassphrase
+    }
+    if server.S3AccessKey == "" {
+        server.EncryptedS3AccessKey = existing.EncryptedS3AccessKey
+    }
+    if server.S3SecretKey == "" {
+        server.EncryptedS3SecretKey = existing.EncryptedS3SecretKey
+    }
+}
+
+// 用新的主密码重新加密服务器凭据
+func ReencryptServerCredentials(oldPassword, newPassword string) error {
+    for _, server := range GetServerConfigs() {
+        if !server.HasEncryptedCredentials() {
+            continue
+        }
+        decrypted, err := DecryptServerConfig(server, oldPassword)
+        if err != nil {
+            return err
+        }
+        decrypted.EncryptedUsername, decrypted.EncryptedPassword, decrypted.EncryptedKeyPassphrase = "", "", ""
+        decrypted.EncryptedS3AccessKey, decrypted.EncryptedS3SecretKey = "", ""
+        if err := SetServerConfigWithEncryption(server.ID, decrypted, newPassword); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// 使用运行时解密密钥补全加密存储的凭据，未设置密钥或解密失败时保持不变
+func WithDecryptedCredentials(server ServerConfig) ServerConfig {
+    if decryptionKey == "" || !server.HasEncryptedCredentials() {
+        return server
+    }
+    if decrypted, err := DecryptServerConfig(server, decryptionKey); err == nil {
+        return decrypted
+    }
+    return server
+}
+
 // 解密服务器配置
 func DecryptServerConfig(server ServerConfig, masterPassword string) (ServerConfig, error) {
     var err error
@@ -740,5 +1418,27 @@ func DecryptServerConfig(server ServerConfig, masterPassword string) (ServerConf
         }
     }
     
+    // 解密私钥密码
+    if server.EncryptedKeyPassphrase != "" {
+        server.KeyPassphrase, err = decrypt(server.EncryptedKeyPassphrase, masterPassword)
+        if err != nil {
+            return server, err
+        }
+    }
+    
+    // 解密S3密钥
+    if server.EncryptedS3AccessKey != "" {
+        server.S3AccessKey, err = decrypt(server.EncryptedS3AccessKey, masterPassword)
+        if err != nil {
+            return server, err
+        }
+    }
+    if server.EncryptedS3SecretKey != "" {
+        server.S3SecretKey, err = decrypt(server.EncryptedS3SecretKey, masterPassword)
+        if err != nil {
+            return server, err
+        }
+    }
+    
     return server, nil
 }
\ No newline at end of file
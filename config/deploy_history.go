@@ -0,0 +1,214 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 最多保留的部署历史记录数
+const maxDeploymentRecords = 500
+
+// 部署触发方式
+const (
+	DeployTriggerManual   = "manual"   // 页面或API手动触发
+	DeployTriggerResume   = "resume"   // 继续中断的部署
+	DeployTriggerSchedule = "schedule" // 定时部署
+	DeployTriggerPromote  = "promote"  // 构件提升到下一个环境
+)
+
+// 单个文件的上传错误
+type DeploymentFileError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// 部署历史记录（每次部署、每个服务器一条）
+type DeploymentRecord struct {
+	ID               string                `json:"id"`
+	RunID            string                `json:"run_id"`              // 同一次操作部署到多个服务器时共享
+	ServerID         string                `json:"server_id,omitempty"` // 单服务器部署时为空
+	ServerName       string                `json:"server_name"`
+	Incremental      bool                  `json:"incremental"`  // 是否增量部署
+	Build            bool                  `json:"build"`        // 是否包含构建
+	Trigger          string                `json:"trigger"`      // 触发方式
+	TriggeredBy      string                `json:"triggered_by"` // 触发者
+	Status           string                `json:"status"`       // running, success, failed, paused
+	Message          string                `json:"message"`
+	BuildOutput      string                `json:"build_output,omitempty"`
+	StartTime        time.Time             `json:"start_time"`
+	EndTime          *time.Time            `json:"end_time,omitempty"`
+	FilesUploaded    int                   `json:"files_uploaded"`
+	FilesFailed      int                   `json:"files_failed"`
+	FilesDeleted     int                   `json:"files_deleted"`
+	BytesTransferred int64                 `json:"bytes_transferred"`
+	Release          string                `json:"release,omitempty"`
+	UploadedFiles    []string              `json:"uploaded_files,omitempty"`
+	FailedFiles      []DeploymentFileError `json:"failed_files,omitempty"`
+	DeletedFiles     []string              `json:"deleted_files,omitempty"`
+	Hooks            []DeployHookResult    `json:"hooks,omitempty"`      // 部署前后命令的执行结果
+	SmokeTest        *SmokeTestResult      `json:"smoke_test,omitempty"` // 部署后验证结果
+	Artifact         string                `json:"artifact,omitempty"`   // 部署的构件（按环境部署时）
+	Snapshot         string                `json:"snapshot,omitempty"`   // 部署前创建的快照
+}
+
+// 部署历史查询条件
+type DeploymentFilter struct {
+	ServerID string
+	Status   string
+	Trigger  string
+	Since    *time.Time
+	Until    *time.Time
+	Limit    int
+	Offset   int
+}
+
+var deploymentHistoryMutex sync.Mutex
+
+// 部署历史记录目录
+func deploymentHistoryDir() string {
+	return filepath.Join(GetDataDir(), "deployments")
+}
+
+// 生成部署记录ID（按时间排序）
+func GenerateDeploymentID() string {
+	bytes := make([]byte, 3)
+	rand.Read(bytes)
+	return fmt.Sprintf("%s-%x", time.Now().Format("20060102150405"), bytes)
+}
+
+// 保存部署记录
+func SaveDeploymentRecord(record DeploymentRecord) error {
+	if record.ID == "" || strings.ContainsAny(record.ID, `/\.`) {
+		return errors.New("无效的部署记录ID")
+	}
+
+	deploymentHistoryMutex.Lock()
+	defer deploymentHistoryMutex.Unlock()
+
+	dir := deploymentHistoryDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, record.ID+".json"), data, 0644); err != nil {
+		return err
+	}
+
+	pruneDeploymentRecords(dir)
+	return nil
+}
+
+// 删除超出数量上限的旧记录
+func pruneDeploymentRecords(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) <= maxDeploymentRecords {
+		return
+	}
+
+	// 文件名以时间开头，按名称排序即按时间排序
+	var names []string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for i := 0; i < len(names)-maxDeploymentRecords; i++ {
+		os.Remove(filepath.Join(dir, names[i]))
+	}
+}
+
+// 获取部署记录详情
+func GetDeploymentRecord(id string) (DeploymentRecord, error) {
+	var record DeploymentRecord
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return record, errors.New("deployment not found")
+	}
+
+	deploymentHistoryMutex.Lock()
+	defer deploymentHistoryMutex.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(deploymentHistoryDir(), id+".json"))
+	if err != nil {
+		return record, errors.New("deployment not found")
+	}
+	err = json.Unmarshal(data, &record)
+	return record, err
+}
+
+// 查询部署记录（按开始时间倒序），返回不含文件列表和构建输出的摘要及匹配总数
+func ListDeploymentRecords(filter DeploymentFilter) ([]DeploymentRecord, int) {
+	deploymentHistoryMutex.Lock()
+	defer deploymentHistoryMutex.Unlock()
+
+	records := []DeploymentRecord{}
+	dir := deploymentHistoryDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return records, 0
+	}
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var record DeploymentRecord
+		if json.Unmarshal(data, &record) != nil {
+			continue
+		}
+
+		if filter.ServerID != "" && record.ServerID != filter.ServerID {
+			continue
+		}
+		if filter.Status != "" && record.Status != filter.Status {
+			continue
+		}
+		if filter.Trigger != "" && record.Trigger != filter.Trigger {
+			continue
+		}
+		if filter.Since != nil && record.StartTime.Before(*filter.Since) {
+			continue
+		}
+		if filter.Until != nil && record.StartTime.After(*filter.Until) {
+			continue
+		}
+
+		record.BuildOutput = ""
+		record.UploadedFiles = nil
+		record.FailedFiles = nil
+		record.DeletedFiles = nil
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].StartTime.After(records[j].StartTime)
+	})
+
+	total := len(records)
+	if filter.Offset > 0 {
+		if filter.Offset >= len(records) {
+			return []DeploymentRecord{}, total
+		}
+		records = records[filter.Offset:]
+	}
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records, total
+}
@@ -0,0 +1,23 @@
+package config
+
+// 部署钩子执行阶段
+const (
+	HookStagePre  = "pre"  // 传输文件之前
+	HookStagePost = "post" // 传输文件之后
+)
+
+// 部署前后在远程服务器执行的命令
+type DeployHook struct {
+	Command string `json:"command"`
+	Timeout int    `json:"timeout,omitempty"` // 超时秒数，0 表示默认值
+}
+
+// 部署钩子的执行结果
+type DeployHookResult struct {
+	Stage      string `json:"stage"` // pre, post
+	Command    string `json:"command"`
+	ExitCode   int    `json:"exit_code"` // 超时或连接中断时为 -1
+	Output     string `json:"output"`    // 标准输出和标准错误（过长时截断）
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// 内存中最多保留的批量部署任务数
+const maxDeployJobs = 20
+
+// 批量部署中单个服务器的状态
+const (
+	JobServerPending = "pending" // 等待部署
+	JobServerRunning = "running" // 正在部署
+	JobServerSuccess = "success" // 部署成功
+	JobServerFailed  = "failed"  // 部署失败
+	JobServerSkipped = "skipped" // 失败即停止时未开始部署
+	JobServerPaused  = "paused"  // 部署已暂停，可以继续
+)
+
+// 批量部署中单个服务器的结果
+type DeployJobServer struct {
+	ServerID     string                  `json:"server_id"`
+	ServerName   string                  `json:"server_name"`
+	State        string                  `json:"state"`                   // pending, running, success, failed, skipped, paused
+	DeploymentID string                  `json:"deployment_id,omitempty"` // 对应的部署历史记录
+	Message      string                  `json:"message,omitempty"`
+	Status       *ServerDeploymentStatus `json:"status,omitempty"` // 服务器实时部署状态
+}
+
+// 批量部署任务：构建一次，然后并发部署到多个服务器
+type DeployJob struct {
+	ID          string            `json:"id"`
+	Status      string            `json:"status"` // running, success, partial, failed, paused
+	Build       bool              `json:"build"`
+	Incremental bool              `json:"incremental"`
+	Parallelism int               `json:"parallelism"`
+	FailFast    bool              `json:"fail_fast"` // 有服务器失败时不再开始新的部署
+	Message     string            `json:"message"`
+	BuildOutput string            `json:"build_output,omitempty"`
+	Environment string            `json:"environment,omitempty"` // 按环境部署时的环境ID
+	Artifact    string            `json:"artifact,omitempty"`    // 部署的构件
+	StartTime   time.Time         `json:"start_time"`
+	EndTime     *time.Time        `json:"end_time,omitempty"`
+	Servers     []DeployJobServer `json:"servers"`
+	Total       int               `json:"total"`
+	Pending     int               `json:"pending"`
+	Running     int               `json:"running"`
+	Succeeded   int               `json:"succeeded"`
+	Failed      int               `json:"failed"`
+	Skipped     int               `json:"skipped"`
+	Paused      int               `json:"paused"`
+}
+
+var (
+	deployJobs      []*DeployJob // 按创建顺序排列
+	deployJobsMutex sync.Mutex
+)
+
+// 保存新的批量部署任务，超出数量时丢弃最早已结束的任务
+func AddDeployJob(job *DeployJob) {
+	deployJobsMutex.Lock()
+	defer deployJobsMutex.Unlock()
+
+	deployJobs = append(deployJobs, job)
+	for len(deployJobs) > maxDeployJobs {
+		removed := false
+		for i, existing := range deployJobs {
+			if existing.EndTime != nil {
+				deployJobs = append(deployJobs[:i], deployJobs[i+1:]...)
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			break
+		}
+	}
+}
+
+// 修改批量部署任务
+func UpdateDeployJob(jobID string, update func(job *DeployJob)) {
+	deployJobsMutex.Lock()
+	defer deployJobsMutex.Unlock()
+
+	for _, job := range deployJobs {
+		if job.ID == jobID {
+			update(job)
+			return
+		}
+	}
+}
+
+// 修改批量部署任务中指定服务器的结果
+func UpdateDeployJobServer(jobID, serverID, state, message string) {
+	UpdateDeployJob(jobID, func(job *DeployJob) {
+		for i := range job.Servers {
+			if job.Servers[i].ServerID == serverID {
+				job.Servers[i].State = state
+				job.Servers[i].Message = message
+				return
+			}
+		}
+	})
+}
+
+// 获取批量部署任务，附带各服务器的实时状态和汇总
+func GetDeployJob(jobID string) (DeployJob, error) {
+	deployJobsMutex.Lock()
+	var job *DeployJob
+	for _, existing := range deployJobs {
+		if existing.ID == jobID {
+			job = existing
+			break
+		}
+	}
+	if job == nil {
+		deployJobsMutex.Unlock()
+		return DeployJob{}, errors.New("deploy job not found")
+	}
+	snapshot := *job
+	snapshot.Servers = append([]DeployJobServer(nil), job.Servers...)
+	deployJobsMutex.Unlock()
+
+	rollUpDeployJob(&snapshot)
+	return snapshot, nil
+}
+
+// 获取所有批量部署任务，最新的在前
+func ListDeployJobs() []DeployJob {
+	deployJobsMutex.Lock()
+	ids := make([]string, 0, len(deployJobs))
+	for i := len(deployJobs) - 1; i >= 0; i-- {
+		ids = append(ids, deployJobs[i].ID)
+	}
+	deployJobsMutex.Unlock()
+
+	jobs := make([]DeployJob, 0, len(ids))
+	for _, id := range ids {
+		if job, err := GetDeployJob(id); err == nil {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// 汇总各服务器的状态
+func rollUpDeployJob(job *DeployJob) {
+	job.Total = len(job.Servers)
+	job.Pending, job.Running, job.Succeeded, job.Failed, job.Skipped, job.Paused = 0, 0, 0, 0, 0, 0
+	for i := range job.Servers {
+		server := &job.Servers[i]
+		switch server.State {
+		case JobServerPending:
+			job.Pending++
+		case JobServerRunning:
+			job.Running++
+		case JobServerSuccess:
+			job.Succeeded++
+		case JobServerFailed:
+			job.Failed++
+		case JobServerSkipped:
+			job.Skipped++
+		case JobServerPaused:
+			job.Paused++
+		}
+		if server.State != JobServerPending && server.State != JobServerSkipped {
+			status := GetServerDeploymentStatus(server.ServerID)
+			server.Status = &status
+		}
+	}
+}
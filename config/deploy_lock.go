@@ -0,0 +1,302 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 构建到共享的public目录时持有的锁
+const BuildLockKey = "build"
+
+// 服务器部署锁的前缀，完整的键为 server:<服务器ID>
+const serverLockPrefix = "server:"
+
+// 服务器部署锁的键
+func ServerLockKey(serverID string) string {
+	return serverLockPrefix + serverID
+}
+
+// 部署锁：同一服务器同时只能有一个部署，public目录同时只能有一个构建
+// 锁保存在数据目录中，程序崩溃后遗留的锁会被识别为过期，在下次启动时释放
+type DeployLock struct {
+	Key          string    `json:"key"`
+	ID           string    `json:"id"` // 本次获取锁的标识，释放时校验，避免释放他人重新获取的锁
+	ServerID     string    `json:"server_id,omitempty"`
+	ServerName   string    `json:"server_name,omitempty"`
+	Operation    string    `json:"operation"`               // 持有锁的操作，如 构建和部署、恢复快照
+	JobID        string    `json:"job_id,omitempty"`        // 批量部署任务
+	DeploymentID string    `json:"deployment_id,omitempty"` // 部署历史记录
+	TriggeredBy  string    `json:"triggered_by,omitempty"`
+	Instance     string    `json:"instance"` // 持有锁的程序实例
+	PID          int       `json:"pid"`
+	AcquiredAt   time.Time `json:"acquired_at"`
+	Stale        bool      `json:"stale"` // 持有锁的程序已退出（读取时计算）
+}
+
+// 锁已被持有
+type DeployLockedError struct {
+	Lock DeployLock
+}
+
+func (e *DeployLockedError) Error() string {
+	var message string
+	if e.Lock.Key == BuildLockKey {
+		message = "Hugo站点正在构建中（" + e.Lock.Operation + "）"
+	} else {
+		name := e.Lock.ServerName
+		if name == "" {
+			name = e.Lock.ServerID
+		}
+		message = fmt.Sprintf("服务器 %s 已有部署正在进行（%s）", name, e.Lock.Operation)
+	}
+	if e.Lock.Stale {
+		message += "，持有锁的程序已退出，可以强制释放"
+	}
+	return message
+}
+
+// 判断错误是否为锁已被持有
+func AsDeployLocked(err error) (*DeployLockedError, bool) {
+	var locked *DeployLockedError
+	if errors.As(err, &locked) {
+		return locked, true
+	}
+	return nil, false
+}
+
+var (
+	deployLocks       map[string]DeployLock
+	deployLocksMutex  sync.Mutex
+	deployLockProcess = fmt.Sprintf("%d-%s", os.Getpid(), GenerateDeploymentID())
+)
+
+func deployLocksFile() string {
+	return filepath.Join(GetDataDir(), "deploy-locks.json")
+}
+
+// 首次使用时读取保存的锁，调用方需持有 deployLocksMutex
+func loadDeployLocks() {
+	if deployLocks != nil {
+		return
+	}
+	deployLocks = make(map[string]DeployLock)
+	data, err := os.ReadFile(deployLocksFile())
+	if err != nil {
+		return
+	}
+	var locks []DeployLock
+	if json.Unmarshal(data, &locks) != nil {
+		return
+	}
+	for _, lock := range locks {
+		deployLocks[lock.Key] = lock
+	}
+}
+
+// 保存锁，调用方需持有 deployLocksMutex
+func saveDeployLocks() {
+	locks := make([]DeployLock, 0, len(deployLocks))
+	for _, lock := range deployLocks {
+		lock.Stale = false
+		locks = append(locks, lock)
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Key < locks[j].Key
+	})
+
+	if err := os.MkdirAll(GetDataDir(), 0755); err != nil {
+		fmt.Printf("保存部署锁失败: %v\n", err)
+		return
+	}
+	data, err := json.MarshalIndent(locks, "", "  ")
+	if err == nil {
+		err = os.WriteFile(deployLocksFile(), data, 0644)
+	}
+	if err != nil {
+		fmt.Printf("保存部署锁失败: %v\n", err)
+	}
+}
+
+// 计算锁是否过期
+func withStale(lock DeployLock) DeployLock {
+	lock.Stale = lock.Instance != deployLockProcess
+	return lock
+}
+
+// 持有的一组部署锁
+type DeployLockHandle struct {
+	info  DeployLock
+	mutex sync.Mutex
+	keys  []string
+}
+
+// 创建锁句柄，info 为持有者信息，之后通过 Acquire 获取锁
+func NewDeployLock(operation, triggeredBy string) *DeployLockHandle {
+	return &DeployLockHandle{info: DeployLock{
+		ID:          GenerateDeploymentID(),
+		Operation:   operation,
+		TriggeredBy: triggeredBy,
+		Instance:    deployLockProcess,
+		PID:         os.Getpid(),
+	}}
+}
+
+// 获取一组锁，任意一个已被持有（包括过期的锁）时都不获取，返回 *DeployLockedError
+func (h *DeployLockHandle) Acquire(keys ...string) error {
+	// 在获取锁之前查找服务器名称，避免同时持有两个互斥锁
+	names := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if serverID, ok := strings.CutPrefix(key, serverLockPrefix); ok {
+			if server, err := GetServerConfig(serverID); err == nil {
+				names[key] = server.Name
+			}
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	deployLocksMutex.Lock()
+	defer deployLocksMutex.Unlock()
+	loadDeployLocks()
+
+	for _, key := range keys {
+		if lock, exists := deployLocks[key]; exists {
+			return &DeployLockedError{Lock: withStale(lock)}
+		}
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		lock := h.info
+		lock.Key = key
+		if serverID, ok := strings.CutPrefix(key, serverLockPrefix); ok {
+			lock.ServerID = serverID
+		}
+		lock.ServerName = names[key]
+		lock.AcquiredAt = now
+		deployLocks[key] = lock
+		h.keys = append(h.keys, key)
+	}
+	saveDeployLocks()
+	return nil
+}
+
+// 释放指定的锁，不指定时释放全部，已被强制释放的锁会被忽略
+func (h *DeployLockHandle) Release(keys ...string) {
+	if h == nil {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(keys) == 0 {
+		keys = h.keys
+	}
+	release := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		release[key] = true
+	}
+
+	deployLocksMutex.Lock()
+	defer deployLocksMutex.Unlock()
+	loadDeployLocks()
+
+	remaining := h.keys[:0]
+	for _, key := range h.keys {
+		if !release[key] {
+			remaining = append(remaining, key)
+			continue
+		}
+		if lock, exists := deployLocks[key]; exists && lock.ID == h.info.ID {
+			delete(deployLocks, key)
+		}
+	}
+	h.keys = remaining
+	saveDeployLocks()
+}
+
+// 修改持有的锁的信息（如记录部署任务和部署记录ID）
+func (h *DeployLockHandle) Update(fn func(lock *DeployLock)) {
+	if h == nil {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	deployLocksMutex.Lock()
+	defer deployLocksMutex.Unlock()
+	loadDeployLocks()
+
+	for _, key := range h.keys {
+		if lock, exists := deployLocks[key]; exists && lock.ID == h.info.ID {
+			fn(&lock)
+			deployLocks[key] = lock
+		}
+	}
+	saveDeployLocks()
+}
+
+// 获取所有部署锁
+func ListDeployLocks() []DeployLock {
+	deployLocksMutex.Lock()
+	defer deployLocksMutex.Unlock()
+	loadDeployLocks()
+
+	locks := make([]DeployLock, 0, len(deployLocks))
+	for _, lock := range deployLocks {
+		locks = append(locks, withStale(lock))
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Key < locks[j].Key
+	})
+	return locks
+}
+
+// 释放上次运行遗留的（过期的）部署锁，返回释放的锁
+// 程序启动时调用，之后继续部署和定时部署不会被已退出的程序持有的锁阻塞
+func ReleaseStaleDeployLocks() []DeployLock {
+	deployLocksMutex.Lock()
+	defer deployLocksMutex.Unlock()
+	loadDeployLocks()
+
+	released := []DeployLock{}
+	for key, lock := range deployLocks {
+		if lock = withStale(lock); lock.Stale {
+			released = append(released, lock)
+			delete(deployLocks, key)
+		}
+	}
+	if len(released) > 0 {
+		saveDeployLocks()
+	}
+	sort.Slice(released, func(i, j int) bool {
+		return released[i].Key < released[j].Key
+	})
+	return released
+}
+
+// 强制释放部署锁：过期的锁可以直接释放，仍在运行的操作持有的锁需要 force
+func ForceReleaseDeployLock(key string, force bool) (DeployLock, error) {
+	deployLocksMutex.Lock()
+	defer deployLocksMutex.Unlock()
+	loadDeployLocks()
+
+	lock, exists := deployLocks[key]
+	if !exists {
+		return lock, errors.New("部署锁不存在")
+	}
+	lock = withStale(lock)
+	if !lock.Stale && !force {
+		return lock, &DeployLockedError{Lock: lock}
+	}
+	delete(deployLocks, key)
+	saveDeployLocks()
+	return lock, nil
+}
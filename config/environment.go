@@ -0,0 +1,385 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 每个环境最多保留的构件数（当前部署的和还有上传任务未完成的构件总是保留）
+const maxArtifactsPerEnvironment = 10
+
+// 部署环境：一组服务器，构建时使用自己的 Hugo environment 和 baseURL
+// 环境按列表顺序排列，构件从一个环境提升到下一个环境
+type Environment struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	HugoEnvironment string    `json:"hugo_environment,omitempty"` // hugo --environment，为空时使用Hugo默认值
+	BaseURL         string    `json:"base_url,omitempty"`         // hugo --baseURL，为空时使用站点配置
+	CurrentArtifact string    `json:"current_artifact,omitempty"` // 最近一次部署成功的构件
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// 构件：一次构建生成的站点，保存后可以原样部署或提升到下一个环境
+type Artifact struct {
+	ID              string    `json:"id"`
+	Environment     string    `json:"environment"` // 所属环境ID
+	HugoEnvironment string    `json:"hugo_environment,omitempty"`
+	BaseURL         string    `json:"base_url,omitempty"`
+	PromotedFrom    string    `json:"promoted_from,omitempty"` // 由其他环境的构件提升而来时为原构件ID
+	Digest          string    `json:"digest"`                  // 所有文件路径和内容的SHA-256，提升后保持不变
+	Files           int       `json:"files"`
+	Size            int64     `json:"size"`
+	BuildOutput     string    `json:"build_output,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+var artifactsMutex sync.Mutex
+
+func generateEnvironmentID() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return "env_" + time.Now().Format("20060102150405") + "_" + hex.EncodeToString(suffix)
+}
+
+// 获取所有环境（副本，按提升顺序）
+func GetEnvironments() []Environment {
+	multiDeployMutex.RLock()
+	defer multiDeployMutex.RUnlock()
+	return append([]Environment(nil), currentConfig.MultiDeploy.Environments...)
+}
+
+// 获取环境
+func GetEnvironment(environmentID string) (Environment, error) {
+	multiDeployMutex.RLock()
+	defer multiDeployMutex.RUnlock()
+
+	for _, environment := range currentConfig.MultiDeploy.Environments {
+		if environment.ID == environmentID {
+			return environment, nil
+		}
+	}
+	return Environment{}, errors.New("environment not found")
+}
+
+// 获取下一个环境（提升目标），已是最后一个环境时返回错误
+func GetNextEnvironment(environmentID string) (Environment, error) {
+	multiDeployMutex.RLock()
+	defer multiDeployMutex.RUnlock()
+
+	environments := currentConfig.MultiDeploy.Environments
+	for i, environment := range environments {
+		if environment.ID == environmentID {
+			if i+1 < len(environments) {
+				return environments[i+1], nil
+			}
+			return Environment{}, errors.New("已是最后一个环境")
+		}
+	}
+	return Environment{}, errors.New("environment not found")
+}
+
+// 添加环境（排在最后），返回生成的ID
+func AddEnvironment(environment Environment) string {
+	environment.ID = generateEnvironmentID()
+	environment.CreatedAt = time.Now()
+	environment.CurrentArtifact = ""
+
+	multiDeployMutex.Lock()
+	currentConfig.MultiDeploy.Environments = append(currentConfig.MultiDeploy.Environments, environment)
+	multiDeployMutex.Unlock()
+
+	SaveConfig()
+	return environment.ID
+}
+
+// 修改环境
+func updateEnvironment(environmentID string, update func(environment *Environment)) error {
+	multiDeployMutex.Lock()
+	found := false
+	for i := range currentConfig.MultiDeploy.Environments {
+		if currentConfig.MultiDeploy.Environments[i].ID == environmentID {
+			update(&currentConfig.MultiDeploy.Environments[i])
+			found = true
+			break
+		}
+	}
+	multiDeployMutex.Unlock()
+
+	if !found {
+		return errors.New("environment not found")
+	}
+	SaveConfig()
+	return nil
+}
+
+// 更新环境的名称和构建参数，保留创建时间和当前构件
+func UpdateEnvironment(environmentID string, environment Environment) error {
+	return updateEnvironment(environmentID, func(e *Environment) {
+		e.Name = environment.Name
+		e.HugoEnvironment = environment.HugoEnvironment
+		e.BaseURL = environment.BaseURL
+	})
+}
+
+// 记录环境当前部署的构件
+func SetEnvironmentArtifact(environmentID, artifactID string) error {
+	return updateEnvironment(environmentID, func(e *Environment) {
+		e.CurrentArtifact = artifactID
+	})
+}
+
+// 按给定的ID顺序重新排列环境，必须包含所有环境
+func ReorderEnvironments(environmentIDs []string) error {
+	multiDeployMutex.Lock()
+	environments := currentConfig.MultiDeploy.Environments
+	if len(environmentIDs) != len(environments) {
+		multiDeployMutex.Unlock()
+		return errors.New("环境列表不完整")
+	}
+	byID := make(map[string]Environment, len(environments))
+	for _, environment := range environments {
+		byID[environment.ID] = environment
+	}
+	ordered := make([]Environment, 0, len(environments))
+	for _, id := range environmentIDs {
+		environment, exists := byID[id]
+		if !exists {
+			multiDeployMutex.Unlock()
+			return errors.New("环境不存在: " + id)
+		}
+		delete(byID, id)
+		ordered = append(ordered, environment)
+	}
+	currentConfig.MultiDeploy.Environments = ordered
+	multiDeployMutex.Unlock()
+
+	SaveConfig()
+	return nil
+}
+
+// 删除环境及其构件，环境中还有服务器时不能删除
+func DeleteEnvironment(environmentID string) error {
+	for _, server := range GetServerConfigs() {
+		if server.Environment == environmentID {
+			return errors.New("环境中还有服务器: " + server.Name)
+		}
+	}
+
+	multiDeployMutex.Lock()
+	found := false
+	for i, environment := range currentConfig.MultiDeploy.Environments {
+		if environment.ID == environmentID {
+			currentConfig.MultiDeploy.Environments = append(currentConfig.MultiDeploy.Environments[:i], currentConfig.MultiDeploy.Environments[i+1:]...)
+			found = true
+			break
+		}
+	}
+	multiDeployMutex.Unlock()
+
+	if !found {
+		return errors.New("environment not found")
+	}
+	SaveConfig()
+
+	for _, artifact := range ListArtifacts(environmentID) {
+		DeleteArtifact(artifact.ID)
+	}
+	return nil
+}
+
+// 获取环境中的服务器
+func GetEnvironmentServers(environmentID string) []ServerConfig {
+	var servers []ServerConfig
+	for _, server := range GetServerConfigs() {
+		if server.Environment == environmentID {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+// 构件目录
+func artifactsDir() string {
+	return filepath.Join(GetDataDir(), "artifacts")
+}
+
+func validArtifactID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\.`)
+}
+
+// 生成构件ID（按时间排序）
+func GenerateArtifactID() string {
+	return GenerateDeploymentID()
+}
+
+// 构件的站点目录（绝对路径，可直接作为 hugo --destination）
+func ArtifactSiteDir(artifactID string) string {
+	dir, err := filepath.Abs(filepath.Join(artifactsDir(), artifactID, "site"))
+	if err != nil {
+		return filepath.Join(artifactsDir(), artifactID, "site")
+	}
+	return dir
+}
+
+// 保存构件信息，并删除该环境超出数量的旧构件
+func SaveArtifact(artifact Artifact) error {
+	if !validArtifactID(artifact.ID) {
+		return errors.New("无效的构件ID")
+	}
+
+	artifactsMutex.Lock()
+	dir := filepath.Join(artifactsDir(), artifact.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		artifactsMutex.Unlock()
+		return err
+	}
+	data, err := json.MarshalIndent(artifact, "", "  ")
+	if err != nil {
+		artifactsMutex.Unlock()
+		return err
+	}
+	err = os.WriteFile(filepath.Join(dir, "artifact.json"), data, 0644)
+	artifactsMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	pruneArtifacts(artifact.Environment)
+	return nil
+}
+
+// 获取构件信息
+func GetArtifact(artifactID string) (Artifact, error) {
+	var artifact Artifact
+	if !validArtifactID(artifactID) {
+		return artifact, errors.New("artifact not found")
+	}
+
+	artifactsMutex.Lock()
+	defer artifactsMutex.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(artifactsDir(), artifactID, "artifact.json"))
+	if err != nil {
+		return artifact, errors.New("artifact not found")
+	}
+	if err := json.Unmarshal(data, &artifact); err != nil {
+		return artifact, err
+	}
+	return artifact, nil
+}
+
+// 列出构件（最新的在前，不含构建输出），environmentID 为空时返回所有构件
+func ListArtifacts(environmentID string) []Artifact {
+	artifactsMutex.Lock()
+	defer artifactsMutex.Unlock()
+
+	artifacts := []Artifact{}
+	entries, err := os.ReadDir(artifactsDir())
+	if err != nil {
+		return artifacts
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(artifactsDir(), entry.Name(), "artifact.json"))
+		if err != nil {
+			continue
+		}
+		var artifact Artifact
+		if json.Unmarshal(data, &artifact) != nil {
+			continue
+		}
+		if environmentID != "" && artifact.Environment != environmentID {
+			continue
+		}
+		artifact.BuildOutput = ""
+		artifacts = append(artifacts, artifact)
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].ID > artifacts[j].ID
+	})
+	return artifacts
+}
+
+// 构件是否为某个环境当前部署的构件
+func isCurrentArtifact(artifactID string) bool {
+	for _, environment := range GetEnvironments() {
+		if environment.CurrentArtifact == artifactID {
+			return true
+		}
+	}
+	return false
+}
+
+// 服务器上传队列中未完成的任务所属的构件ID，不是构件部署的任务时返回空
+// 继续部署时按任务记录的本地文件上传，这些文件必须保留
+func UploadQueueArtifact(serverID string) string {
+	root, err := filepath.Abs(artifactsDir())
+	if err != nil {
+		return ""
+	}
+	for _, task := range GetServerUploadTasks(serverID) {
+		if task.Completed {
+			continue
+		}
+		rel, err := filepath.Rel(root, task.LocalFile)
+		if err != nil {
+			return ""
+		}
+		artifactID, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+		if !validArtifactID(artifactID) {
+			return ""
+		}
+		return artifactID
+	}
+	return ""
+}
+
+// 构件是否还有服务器的上传任务未完成
+func isQueuedArtifact(artifactID string) bool {
+	for serverID := range GetUploadQueues() {
+		if UploadQueueArtifact(serverID) == artifactID {
+			return true
+		}
+	}
+	return false
+}
+
+// 删除构件，环境当前部署的构件和还有上传任务未完成的构件不能删除
+func DeleteArtifact(artifactID string) error {
+	if !validArtifactID(artifactID) {
+		return errors.New("artifact not found")
+	}
+	if isCurrentArtifact(artifactID) {
+		return errors.New("不能删除环境当前部署的构件")
+	}
+	if isQueuedArtifact(artifactID) {
+		return errors.New("不能删除还有上传任务未完成的构件，请先继续或停止部署")
+	}
+
+	artifactsMutex.Lock()
+	defer artifactsMutex.Unlock()
+
+	dir := filepath.Join(artifactsDir(), artifactID)
+	if _, err := os.Stat(dir); err != nil {
+		return errors.New("artifact not found")
+	}
+	return os.RemoveAll(dir)
+}
+
+// 删除环境超出数量的旧构件
+func pruneArtifacts(environmentID string) {
+	artifacts := ListArtifacts(environmentID)
+	for i := maxArtifactsPerEnvironment; i < len(artifacts); i++ {
+		DeleteArtifact(artifacts[i].ID)
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 跳板机链的最大层数
+const maxJumpHops = 8
+
+// 按连接顺序返回服务器的跳板机链：第一个直接连接，之后的每一个都经由前一个连接
+// 引用的服务器不存在、不是SSH服务器、形成循环或层数过多时返回错误
+func ResolveJumpChain(server ServerConfig) ([]ServerConfig, error) {
+	var chain []ServerConfig
+	visited := map[string]bool{server.ID: server.ID != ""}
+
+	jumpServerID := server.JumpServerID
+	for jumpServerID != "" {
+		if visited[jumpServerID] {
+			return nil, fmt.Errorf("跳板机配置形成循环")
+		}
+		if len(chain) >= maxJumpHops {
+			return nil, fmt.Errorf("跳板机层数不能超过 %d 层", maxJumpHops)
+		}
+		visited[jumpServerID] = true
+
+		jump, err := GetServerConfig(jumpServerID)
+		if err != nil {
+			return nil, fmt.Errorf("跳板机不存在: %s", jumpServerID)
+		}
+		if jump.Type != "" && !strings.EqualFold(jump.Type, "ssh") {
+			return nil, fmt.Errorf("跳板机 %s 不是SSH服务器", jump.Name)
+		}
+
+		chain = append(chain, jump)
+		jumpServerID = jump.JumpServerID
+	}
+
+	// 反转为连接顺序：最外层的跳板机在前
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// 将服务器配置转换为SSH连接配置，并解析跳板机链
+// 跳板机必须已经信任主机密钥（测试一次该跳板机的连接即可），避免经由未验证的主机转发
+func ServerToSSHConfigWithJumps(server ServerConfig) (SSHConfig, error) {
+	sshConfig := ServerToSSHConfig(server)
+
+	chain, err := ResolveJumpChain(server)
+	if err != nil {
+		return sshConfig, err
+	}
+	for _, jump := range chain {
+		if jump.HostKey == "" {
+			return sshConfig, fmt.Errorf("跳板机 %s 尚未信任主机密钥，请先测试该服务器的连接", jump.Name)
+		}
+		sshConfig.JumpHosts = append(sshConfig.JumpHosts, ServerToSSHConfig(jump))
+	}
+	return sshConfig, nil
+}
+
+// 使用指定服务器作为跳板机的服务器名称
+func ServersUsingJumpServer(serverID string) []string {
+	var names []string
+	for _, server := range GetServerConfigs() {
+		if server.JumpServerID == serverID {
+			names = append(names, server.Name)
+		}
+	}
+	return names
+}
+
+// 设置服务器的跳板机
+func SetServerJumpServer(serverID, jumpServerID string) error {
+	return updateServer(serverID, func(s *ServerConfig) {
+		s.JumpServerID = jumpServerID
+	})
+}
@@ -0,0 +1,256 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 定时部署的触发方式
+const (
+	ScheduleKindCron     = "cron"      // 按cron表达式定时构建和部署
+	ScheduleKindNextPost = "next_post" // 下一篇定时发布的文章到期时构建和部署
+)
+
+// 最多保留的定时部署执行记录数
+const maxScheduleRuns = 200
+
+// 定时部署：到期时构建一次，然后部署到指定的服务器
+type Schedule struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Kind        string     `json:"kind"`                 // cron, next_post
+	Cron        string     `json:"cron,omitempty"`       // cron表达式（分 时 日 月 周），按本地时间
+	ServerIDs   []string   `json:"server_ids,omitempty"` // 为空时部署到所有启用的服务器
+	Build       bool       `json:"build"`                // 部署前是否构建，文章到期触发时总是构建
+	Incremental bool       `json:"incremental"`
+	Enabled     bool       `json:"enabled"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+	NextRun     *time.Time `json:"next_run,omitempty"`     // 下一次执行时间，由调度器计算
+	NextPost    string     `json:"next_post,omitempty"`    // 文章到期触发时，下一篇到期的文章
+	LastStatus  string     `json:"last_status,omitempty"`  // 最近一次执行的结果
+	LastMessage string     `json:"last_message,omitempty"` // 最近一次执行的消息
+	// 文章到期触发时，此时间之前到期的文章已成功部署，执行成功后才推进
+	PublishedUntil *time.Time `json:"published_until,omitempty"`
+	RetryCount     int        `json:"retry_count,omitempty"` // 文章到期触发连续失败的次数
+	RetryAt        *time.Time `json:"retry_at,omitempty"`    // 文章到期触发失败后，下一次重试的时间
+}
+
+// 定时部署的一次执行
+type ScheduleRun struct {
+	ID           string     `json:"id"`
+	ScheduleID   string     `json:"schedule_id"`
+	ScheduleName string     `json:"schedule_name"`
+	Trigger      string     `json:"trigger"` // schedule（到期触发）, manual（立即执行）
+	Status       string     `json:"status"`  // running, success, partial, failed, skipped
+	Message      string     `json:"message"`
+	JobID        string     `json:"job_id,omitempty"` // 对应的批量部署任务
+	Posts        []string   `json:"posts,omitempty"`  // 文章到期触发时，本次发布的文章
+	StartTime    time.Time  `json:"start_time"`
+	EndTime      *time.Time `json:"end_time,omitempty"`
+}
+
+var scheduleRunsMutex sync.Mutex
+
+func generateScheduleID() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return "schedule_" + time.Now().Format("20060102150405") + "_" + hex.EncodeToString(suffix)
+}
+
+// 获取所有定时部署（副本）
+func GetSchedules() []Schedule {
+	multiDeployMutex.RLock()
+	defer multiDeployMutex.RUnlock()
+	return append([]Schedule(nil), currentConfig.MultiDeploy.Schedules...)
+}
+
+// 获取定时部署
+func GetSchedule(scheduleID string) (Schedule, error) {
+	multiDeployMutex.RLock()
+	defer multiDeployMutex.RUnlock()
+
+	for _, schedule := range currentConfig.MultiDeploy.Schedules {
+		if schedule.ID == scheduleID {
+			return schedule, nil
+		}
+	}
+	return Schedule{}, errors.New("schedule not found")
+}
+
+// 添加定时部署，返回生成的ID
+func AddSchedule(schedule Schedule) string {
+	schedule.ID = generateScheduleID()
+	schedule.CreatedAt = time.Now()
+
+	multiDeployMutex.Lock()
+	currentConfig.MultiDeploy.Schedules = append(currentConfig.MultiDeploy.Schedules, schedule)
+	multiDeployMutex.Unlock()
+
+	SaveConfig()
+	return schedule.ID
+}
+
+// 修改定时部署
+func updateSchedule(scheduleID string, update func(schedule *Schedule)) error {
+	multiDeployMutex.Lock()
+	found := false
+	for i := range currentConfig.MultiDeploy.Schedules {
+		if currentConfig.MultiDeploy.Schedules[i].ID == scheduleID {
+			update(&currentConfig.MultiDeploy.Schedules[i])
+			found = true
+			break
+		}
+	}
+	multiDeployMutex.Unlock()
+
+	if !found {
+		return errors.New("schedule not found")
+	}
+	SaveConfig()
+	return nil
+}
+
+// 替换定时部署的配置，保留创建时间和执行状态
+func UpdateSchedule(scheduleID string, schedule Schedule) error {
+	return updateSchedule(scheduleID, func(s *Schedule) {
+		schedule.ID = scheduleID
+		schedule.CreatedAt = s.CreatedAt
+		schedule.LastRun = s.LastRun
+		schedule.PublishedUntil = s.PublishedUntil
+		schedule.LastStatus = s.LastStatus
+		schedule.LastMessage = s.LastMessage
+		*s = schedule
+	})
+}
+
+// 更新下一次执行时间
+func SetScheduleNextRun(scheduleID string, nextRun *time.Time, nextPost string) error {
+	return updateSchedule(scheduleID, func(s *Schedule) {
+		s.NextRun = nextRun
+		s.NextPost = nextPost
+	})
+}
+
+// 记录定时部署开始执行
+func SetScheduleLastRun(scheduleID string, lastRun time.Time) error {
+	return updateSchedule(scheduleID, func(s *Schedule) {
+		s.LastRun = &lastRun
+		s.LastStatus = "running"
+		s.LastMessage = ""
+	})
+}
+
+// 记录文章到期触发已部署到的时间，并清除重试状态
+func SetSchedulePublishedUntil(scheduleID string, publishedUntil time.Time) error {
+	return updateSchedule(scheduleID, func(s *Schedule) {
+		s.PublishedUntil = &publishedUntil
+		s.RetryCount = 0
+		s.RetryAt = nil
+	})
+}
+
+// 记录文章到期触发失败后的重试次数和下一次重试的时间
+func SetScheduleRetry(scheduleID string, retryCount int, retryAt time.Time) error {
+	return updateSchedule(scheduleID, func(s *Schedule) {
+		s.RetryCount = retryCount
+		s.RetryAt = &retryAt
+	})
+}
+
+// 记录定时部署最近一次执行的结果
+func SetScheduleLastResult(scheduleID, status, message string) error {
+	return updateSchedule(scheduleID, func(s *Schedule) {
+		s.LastStatus = status
+		s.LastMessage = message
+	})
+}
+
+// 删除定时部署
+func DeleteSchedule(scheduleID string) error {
+	multiDeployMutex.Lock()
+	found := false
+	for i, schedule := range currentConfig.MultiDeploy.Schedules {
+		if schedule.ID == scheduleID {
+			currentConfig.MultiDeploy.Schedules = append(currentConfig.MultiDeploy.Schedules[:i], currentConfig.MultiDeploy.Schedules[i+1:]...)
+			found = true
+			break
+		}
+	}
+	multiDeployMutex.Unlock()
+
+	if !found {
+		return errors.New("schedule not found")
+	}
+	SaveConfig()
+	return nil
+}
+
+func scheduleRunsPath() string {
+	return filepath.Join(GetDataDir(), "schedule_runs.json")
+}
+
+// 读取执行记录（最新的在前），调用方需持有 scheduleRunsMutex
+func loadScheduleRuns() []ScheduleRun {
+	var runs []ScheduleRun
+	data, err := os.ReadFile(scheduleRunsPath())
+	if err == nil {
+		json.Unmarshal(data, &runs)
+	}
+	return runs
+}
+
+// 保存定时部署的执行记录，已存在时替换，超出数量时删除最早的记录
+func SaveScheduleRun(run ScheduleRun) error {
+	scheduleRunsMutex.Lock()
+	defer scheduleRunsMutex.Unlock()
+
+	runs := loadScheduleRuns()
+	replaced := false
+	for i := range runs {
+		if runs[i].ID == run.ID {
+			runs[i] = run
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		runs = append([]ScheduleRun{run}, runs...)
+	}
+	if len(runs) > maxScheduleRuns {
+		runs = runs[:maxScheduleRuns]
+	}
+
+	if err := os.MkdirAll(GetDataDir(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(scheduleRunsPath(), data, 0644)
+}
+
+// 查询定时部署的执行记录（最新的在前），scheduleID 为空时返回所有记录
+func ListScheduleRuns(scheduleID string, limit int) []ScheduleRun {
+	scheduleRunsMutex.Lock()
+	defer scheduleRunsMutex.Unlock()
+
+	runs := []ScheduleRun{}
+	for _, run := range loadScheduleRuns() {
+		if scheduleID != "" && run.ScheduleID != scheduleID {
+			continue
+		}
+		runs = append(runs, run)
+		if limit > 0 && len(runs) >= limit {
+			break
+		}
+	}
+	return runs
+}
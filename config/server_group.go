@@ -0,0 +1,125 @@
+package config
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 分组和标签名称的最大长度
+const maxServerLabelLength = 64
+
+// 服务器选择条件：按分组和标签选择服务器，同时指定时需要都满足，都为空时选择所有服务器
+type ServerSelector struct {
+	Group string `json:"group" form:"group"`
+	Tag   string `json:"tag" form:"tag"`
+}
+
+// 是否未指定任何条件
+func (s ServerSelector) IsEmpty() bool {
+	return s.Group == "" && s.Tag == ""
+}
+
+// 判断服务器是否满足选择条件
+func (s ServerSelector) Matches(server ServerConfig) bool {
+	if s.Group != "" && server.Group != s.Group {
+		return false
+	}
+	if s.Tag != "" && !server.HasTag(s.Tag) {
+		return false
+	}
+	return true
+}
+
+// 选择条件的说明，用于消息
+func (s ServerSelector) String() string {
+	var parts []string
+	if s.Group != "" {
+		parts = append(parts, "分组 "+s.Group)
+	}
+	if s.Tag != "" {
+		parts = append(parts, "标签 "+s.Tag)
+	}
+	if len(parts) == 0 {
+		return "所有服务器"
+	}
+	return strings.Join(parts, "、")
+}
+
+// 服务器是否带有指定标签
+func (s ServerConfig) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// 获取满足选择条件的服务器
+func SelectServers(selector ServerSelector) []ServerConfig {
+	var servers []ServerConfig
+	for _, server := range GetServerConfigs() {
+		if selector.Matches(server) {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+// 整理分组和标签：去除首尾空白，去掉空标签和重复标签
+func NormalizeServerLabels(server *ServerConfig) {
+	server.Group = strings.TrimSpace(server.Group)
+
+	var tags []string
+	seen := make(map[string]bool, len(server.Tags))
+	for _, tag := range server.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	server.Tags = tags
+}
+
+// 校验分组或标签名称
+func ValidateServerLabel(label string) error {
+	if len(label) > maxServerLabelLength {
+		return errors.New("名称不能超过64个字符: " + label)
+	}
+	for _, r := range label {
+		if unicode.IsControl(r) || r == ',' {
+			return errors.New("名称不能包含逗号或控制字符: " + label)
+		}
+	}
+	return nil
+}
+
+// 所有服务器使用的分组和标签（已排序）
+func ListServerLabels() (groups []string, tags []string) {
+	groupSet := make(map[string]bool)
+	tagSet := make(map[string]bool)
+	for _, server := range GetServerConfigs() {
+		if server.Group != "" {
+			groupSet[server.Group] = true
+		}
+		for _, tag := range server.Tags {
+			tagSet[tag] = true
+		}
+	}
+
+	groups = make([]string, 0, len(groupSet))
+	for group := range groupSet {
+		groups = append(groups, group)
+	}
+	tags = make([]string, 0, len(tagSet))
+	for tag := range tagSet {
+		tags = append(tags, tag)
+	}
+	sort.Strings(groups)
+	sort.Strings(tags)
+	return groups, tags
+}
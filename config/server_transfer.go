@@ -0,0 +1,181 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// 服务器配置导出文件的格式标识和版本
+// 版本2用 scrypt 从传输口令和随机盐派生密钥；版本1直接使用口令的SHA-256作为密钥，仍可导入
+const (
+	ServerExportFormat  = "hugo-manager-servers"
+	ServerExportVersion = 2
+)
+
+// 版本1的导出文件中用传输口令加密的固定文本，导入时用于校验口令
+const serverExportCheckText = "hugo-manager-servers"
+
+// 派生导出密钥的盐长度和 scrypt 参数
+const (
+	serverExportSaltSize = 16
+	serverExportScryptN  = 1 << 15
+	serverExportScryptR  = 8
+	serverExportScryptP  = 1
+)
+
+// 用 scrypt 从传输口令和盐派生导出文件的加密密钥
+func serverExportKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, serverExportScryptN, serverExportScryptR, serverExportScryptP, 32)
+}
+
+// 导出的服务器凭据，整体用传输口令加密
+type serverCredentials struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	KeyPassphrase string `json:"key_passphrase,omitempty"`
+	S3AccessKey   string `json:"s3_access_key,omitempty"`
+	S3SecretKey   string `json:"s3_secret_key,omitempty"`
+}
+
+// 导出文件中的一个服务器
+type ServerExportEntry struct {
+	Server      ServerConfig `json:"server"`                // 不含凭据的服务器配置
+	Credentials string       `json:"credentials,omitempty"` // 用传输口令加密的凭据
+}
+
+// 服务器配置导出文件
+type ServerExport struct {
+	Format     string              `json:"format"`
+	Version    int                 `json:"version"`
+	ExportedAt time.Time           `json:"exported_at"`
+	Salt       string              `json:"salt,omitempty"`  // 派生密钥的随机盐（base64）
+	Check      string              `json:"check,omitempty"` // 版本1：用传输口令加密的固定文本
+	Servers    []ServerExportEntry `json:"servers"`
+}
+
+// 导出服务器配置，凭据用传输口令重新加密（不使用导出者的主密码）
+// 加密存储的凭据需要先设置解密密钥
+func ExportServerConfigs(servers []ServerConfig, passphrase string) (*ServerExport, error) {
+	if passphrase == "" {
+		return nil, errors.New("请设置导出口令")
+	}
+
+	salt := make([]byte, serverExportSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := serverExportKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	export := &ServerExport{
+		Format:     ServerExportFormat,
+		Version:    ServerExportVersion,
+		ExportedAt: time.Now(),
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Servers:    []ServerExportEntry{},
+	}
+
+	for _, server := range servers {
+		if server.HasEncryptedCredentials() {
+			if decryptionKey == "" {
+				return nil, fmt.Errorf("服务器 %s 的凭据已加密，请先输入主密码解锁", server.Name)
+			}
+			if server, err = DecryptServerConfig(server, decryptionKey); err != nil {
+				return nil, fmt.Errorf("解密服务器 %s 的凭据失败", server.Name)
+			}
+		}
+
+		entry := ServerExportEntry{}
+		credentials := serverCredentials{
+			Username:      server.Username,
+			Password:      server.Password,
+			KeyPassphrase: server.KeyPassphrase,
+			S3AccessKey:   server.S3AccessKey,
+			S3SecretKey:   server.S3SecretKey,
+		}
+		if credentials != (serverCredentials{}) {
+			data, err := json.Marshal(credentials)
+			if err != nil {
+				return nil, err
+			}
+			if entry.Credentials, err = encryptWithKey(string(data), key); err != nil {
+				return nil, err
+			}
+		}
+
+		server.Username, server.Password, server.KeyPassphrase = "", "", ""
+		server.EncryptedUsername, server.EncryptedPassword, server.EncryptedKeyPassphrase = "", "", ""
+		server.S3AccessKey, server.S3SecretKey = "", ""
+		server.EncryptedS3AccessKey, server.EncryptedS3SecretKey = "", ""
+		server.PendingHostKey = ""
+		server.LastDeployment = nil
+		entry.Server = server
+		export.Servers = append(export.Servers, entry)
+	}
+	return export, nil
+}
+
+// 用传输口令解密导出文件，返回带明文凭据的服务器配置
+// 版本2没有口令校验文本，口令错误时解密凭据失败
+func DecryptServerExport(export ServerExport, passphrase string) ([]ServerConfig, error) {
+	if export.Format != ServerExportFormat {
+		return nil, errors.New("不是有效的服务器配置导出文件")
+	}
+	if export.Version > ServerExportVersion {
+		return nil, fmt.Errorf("不支持的导出文件版本: %d", export.Version)
+	}
+
+	var decryptCredentials func(ciphertext string) (string, error)
+	if export.Version >= 2 {
+		salt, err := base64.StdEncoding.DecodeString(export.Salt)
+		if err != nil || len(salt) == 0 {
+			return nil, errors.New("导出文件缺少密钥盐")
+		}
+		key, err := serverExportKey(passphrase, salt)
+		if err != nil {
+			return nil, err
+		}
+		decryptCredentials = func(ciphertext string) (string, error) {
+			return decryptWithKey(ciphertext, key)
+		}
+	} else {
+		if check, err := decrypt(export.Check, passphrase); err != nil || check != serverExportCheckText {
+			return nil, errors.New("导出口令错误")
+		}
+		decryptCredentials = func(ciphertext string) (string, error) {
+			return decrypt(ciphertext, passphrase)
+		}
+	}
+
+	servers := make([]ServerConfig, 0, len(export.Servers))
+	for _, entry := range export.Servers {
+		server := entry.Server
+		server.EncryptedUsername, server.EncryptedPassword, server.EncryptedKeyPassphrase = "", "", ""
+		server.EncryptedS3AccessKey, server.EncryptedS3SecretKey = "", ""
+
+		if entry.Credentials != "" {
+			data, err := decryptCredentials(entry.Credentials)
+			if err != nil {
+				return nil, fmt.Errorf("解密服务器 %s 的凭据失败，请检查导出口令", server.Name)
+			}
+			var credentials serverCredentials
+			if err := json.Unmarshal([]byte(data), &credentials); err != nil {
+				return nil, fmt.Errorf("服务器 %s 的凭据格式错误", server.Name)
+			}
+			server.Username = credentials.Username
+			server.Password = credentials.Password
+			server.KeyPassphrase = credentials.KeyPassphrase
+			server.S3AccessKey = credentials.S3AccessKey
+			server.S3SecretKey = credentials.S3SecretKey
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
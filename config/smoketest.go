@@ -0,0 +1,31 @@
+package config
+
+import "time"
+
+// 部署后验证失败时的处理方式
+const (
+	SmokeTestActionWarn     = "warn"     // 只记录结果（默认）
+	SmokeTestActionFail     = "fail"     // 将部署记为失败
+	SmokeTestActionRollback = "rollback" // 回滚到上一个版本（仅版本目录模式），否则记为失败
+)
+
+// 部署后验证中单个URL的检查结果
+type SmokeTestCheck struct {
+	URL        string `json:"url"`
+	LocalFile  string `json:"local_file"` // 对应的本地文件（相对 public/）
+	StatusCode int    `json:"status_code"`
+	LocalHash  string `json:"local_hash,omitempty"`
+	RemoteHash string `json:"remote_hash,omitempty"`
+	Passed     bool   `json:"passed"`
+	Error      string `json:"error,omitempty"`
+}
+
+// 部署后验证结果
+type SmokeTestResult struct {
+	BaseURL   string           `json:"base_url"`
+	Passed    bool             `json:"passed"`
+	Message   string           `json:"message"`
+	Action    string           `json:"action,omitempty"` // 验证失败后执行的处理
+	Checks    []SmokeTestCheck `json:"checks"`
+	CheckedAt time.Time        `json:"checked_at"`
+}
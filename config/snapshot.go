@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 部署前快照的保存位置
+const (
+	SnapshotModeRemote = "remote" // 在服务器上打包，保存在 <远程路径>.snapshots/ 目录
+	SnapshotModeLocal  = "local"  // 打包后下载到管理端数据目录
+)
+
+// 未设置保留数量时每个服务器保留的快照数
+const DefaultKeepSnapshots = 5
+
+// 快照：部署覆盖远程文件前远程路径的完整打包（tar.gz）
+type Snapshot struct {
+	ID         string    `json:"id"`
+	ServerID   string    `json:"server_id"`
+	Location   string    `json:"location"`              // remote 或 local
+	RemotePath string    `json:"remote_path"`           // 打包的远程目录，恢复时解压到此目录
+	RemoteFile string    `json:"remote_file,omitempty"` // 保存在服务器上时的快照文件路径
+	Size       int64     `json:"size"`
+	Manual     bool      `json:"manual,omitempty"` // 手动创建，否则为部署前自动创建
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+var snapshotsMutex sync.Mutex
+
+// 服务器的快照目录
+func snapshotsDir(serverID string) string {
+	return filepath.Join(GetDataDir(), "snapshots", serverID)
+}
+
+func validSnapshotKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, `/\.`)
+}
+
+// 生成快照ID（按时间排序）
+func GenerateSnapshotID() string {
+	return GenerateDeploymentID()
+}
+
+// 下载到本地的快照文件路径
+func SnapshotArchivePath(serverID, snapshotID string) string {
+	return filepath.Join(snapshotsDir(serverID), snapshotID+".tar.gz")
+}
+
+// 保存快照信息
+func SaveSnapshot(snapshot Snapshot) error {
+	if !validSnapshotKey(snapshot.ServerID) || !validSnapshotKey(snapshot.ID) {
+		return errors.New("无效的快照ID")
+	}
+
+	snapshotsMutex.Lock()
+	defer snapshotsMutex.Unlock()
+
+	dir := snapshotsDir(snapshot.ServerID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, snapshot.ID+".json"), data, 0644)
+}
+
+// 获取快照信息
+func GetSnapshot(serverID, snapshotID string) (Snapshot, error) {
+	var snapshot Snapshot
+	if !validSnapshotKey(serverID) || !validSnapshotKey(snapshotID) {
+		return snapshot, errors.New("snapshot not found")
+	}
+
+	snapshotsMutex.Lock()
+	defer snapshotsMutex.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(snapshotsDir(serverID), snapshotID+".json"))
+	if err != nil {
+		return snapshot, errors.New("snapshot not found")
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, err
+	}
+	return snapshot, nil
+}
+
+// 列出服务器的快照（最新的在前）
+func ListSnapshots(serverID string) []Snapshot {
+	snapshots := []Snapshot{}
+	if !validSnapshotKey(serverID) {
+		return snapshots
+	}
+
+	snapshotsMutex.Lock()
+	defer snapshotsMutex.Unlock()
+
+	entries, err := os.ReadDir(snapshotsDir(serverID))
+	if err != nil {
+		return snapshots
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(snapshotsDir(serverID), entry.Name()))
+		if err != nil {
+			continue
+		}
+		var snapshot Snapshot
+		if json.Unmarshal(data, &snapshot) != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID > snapshots[j].ID
+	})
+	return snapshots
+}
+
+// 删除快照信息和本地快照文件（服务器上的快照文件由调用方删除）
+func DeleteSnapshotRecord(serverID, snapshotID string) error {
+	if !validSnapshotKey(serverID) || !validSnapshotKey(snapshotID) {
+		return errors.New("snapshot not found")
+	}
+
+	snapshotsMutex.Lock()
+	defer snapshotsMutex.Unlock()
+
+	if err := os.Remove(filepath.Join(snapshotsDir(serverID), snapshotID+".json")); err != nil {
+		if os.IsNotExist(err) {
+			return errors.New("snapshot not found")
+		}
+		return err
+	}
+	if err := os.Remove(SnapshotArchivePath(serverID, snapshotID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 可以继续的部署：服务器的上传队列中还有未完成的任务
+type ResumableDeployment struct {
+	ServerID    string    `json:"server_id"` // 单服务器部署为空
+	ServerName  string    `json:"server_name"`
+	Pending     int       `json:"pending"` // 未完成的任务数
+	Total       int       `json:"total"`   // 队列中的任务数
+	Paused      bool      `json:"paused"`
+	Interrupted bool      `json:"interrupted"` // 程序退出时部署仍在进行
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// 程序启动时检查上次退出时仍在进行的部署
+// 上传未完成的服务器标记为已暂停并可以继续部署，其余标记为失败，对应的部署记录也一并结束
+func RecoverInterruptedDeployments() []ResumableDeployment {
+	for serverID, status := range GetAllServerStatuses() {
+		if status.Status != "deploying" && status.Status != "building" {
+			continue
+		}
+
+		// 构建阶段中断时队列中只可能是更早的任务，不能继续
+		pending := GetServerPendingTasksCount(serverID)
+		if status.Status == "building" || pending == 0 {
+			UpdateServerDeploymentStatus(serverID, ServerDeploymentStatus{
+				Status:  "failed",
+				Message: "程序退出时部署被中断，请重新部署",
+			})
+			continue
+		}
+
+		SetServerDeploymentPaused(serverID, true)
+		uploadTasksMutex.Lock()
+		serverUploadQueue(serverID).Interrupted = true
+		uploadTasksMutex.Unlock()
+		total := len(GetServerUploadTasks(serverID))
+		UpdateServerDeploymentStatus(serverID, ServerDeploymentStatus{
+			Status:    "paused",
+			Message:   fmt.Sprintf("程序退出时部署被中断，剩余 %d 个文件，可以继续部署", pending),
+			Progress:  (total - pending) * 100 / total,
+			CanResume: true,
+			CanStop:   true,
+		})
+	}
+
+	// 单服务器部署
+	if status := currentConfig.Deployment.LastSyncStatus; status == "deploying" || status == "building" {
+		pending := GetPendingTasksCount()
+		if status == "building" || pending == 0 {
+			UpdateDeploymentStatus("failed", "程序退出时部署被中断，请重新部署")
+		} else {
+			SetDeploymentPaused(true)
+			UpdateDeploymentStatus("paused", fmt.Sprintf("程序退出时部署被中断，剩余 %d 个文件，可以继续部署", pending))
+		}
+		ClearProgress()
+	}
+
+	// 结束仍为进行中的部署记录
+	records, _ := ListDeploymentRecords(DeploymentFilter{Status: "running"})
+	for _, summary := range records {
+		record, err := GetDeploymentRecord(summary.ID)
+		if err != nil {
+			continue
+		}
+		now := time.Now()
+		record.EndTime = &now
+		if GetServerPendingTasksCount(record.ServerID) > 0 && IsServerDeploymentPaused(record.ServerID) {
+			record.Status = "paused"
+		} else {
+			record.Status = "failed"
+		}
+		record.Message = "程序退出时部署被中断"
+		SaveDeploymentRecord(record)
+	}
+
+	return ListResumableDeployments()
+}
+
+// 列出上传队列中还有未完成任务的部署
+func ListResumableDeployments() []ResumableDeployment {
+	names := make(map[string]string)
+	for _, server := range GetServerConfigs() {
+		names[server.ID] = server.Name
+	}
+
+	deployments := []ResumableDeployment{}
+	for serverID, queue := range GetUploadQueues() {
+		name, exists := names[serverID]
+		if !exists {
+			continue
+		}
+		deployment := ResumableDeployment{
+			ServerID:    serverID,
+			ServerName:  name,
+			Total:       len(queue.Tasks),
+			Paused:      queue.IsPaused,
+			Interrupted: queue.Interrupted,
+			UpdatedAt:   queue.UpdatedAt,
+		}
+		for _, task := range queue.Tasks {
+			if !task.Completed {
+				deployment.Pending++
+			}
+		}
+		if deployment.Pending > 0 {
+			deployments = append(deployments, deployment)
+		}
+	}
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].ServerName < deployments[j].ServerName
+	})
+
+	// 单服务器部署
+	if pending := GetPendingTasksCount(); pending > 0 {
+		deployments = append(deployments, ResumableDeployment{
+			ServerName: GetSSHConfig().Host,
+			Pending:    pending,
+			Total:      len(GetUploadTasks()),
+			Paused:     IsDeploymentPaused(),
+		})
+	}
+	return deployments
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"hugo-manager-go/config"
@@ -11,7 +12,6 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 )
 
 // 部署管理页面 - 多服务器部署功能
@@ -102,12 +102,21 @@ func GetSSHConfig(c *gin.Context) {
 // 更新SSH配置
 func UpdateSSHConfig(c *gin.Context) {
 	var request struct {
-		Host       string `json:"host"`
-		Port       int    `json:"port"`
-		Username   string `json:"username"`
-		Password   string `json:"password"`
-		KeyPath    string `json:"key_path"`
-		RemotePath string `json:"remote_path"`
+		Host            string              `json:"host"`
+		Port            int                 `json:"port"`
+		Username        string              `json:"username"`
+		Password        string              `json:"password"`
+		KeyPath         string              `json:"key_path"`
+		KeyPassphrase   string              `json:"key_passphrase"`
+		UseAgent        bool                `json:"use_agent"`
+		RemotePath      string              `json:"remote_path"`
+		TransferMode    string              `json:"transfer_mode"`
+		MirrorDeletions bool                `json:"mirror_deletions"`
+		ProtectedPaths  []string            `json:"protected_paths"`
+		ReleaseMode     bool                `json:"release_mode"`
+		KeepReleases    int                 `json:"keep_releases"`
+		PreDeployHooks  []config.DeployHook `json:"pre_deploy_hooks"`
+		PostDeployHooks []config.DeployHook `json:"post_deploy_hooks"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -116,12 +125,36 @@ func UpdateSSHConfig(c *gin.Context) {
 	}
 
 	sshConfig := config.SSHConfig{
-		Host:       request.Host,
-		Port:       request.Port,
-		Username:   request.Username,
-		Password:   request.Password,
-		KeyPath:    request.KeyPath,
-		RemotePath: request.RemotePath,
+		Host:            request.Host,
+		Port:            request.Port,
+		Username:        request.Username,
+		Password:        request.Password,
+		KeyPath:         request.KeyPath,
+		KeyPassphrase:   request.KeyPassphrase,
+		UseAgent:        request.UseAgent,
+		RemotePath:      request.RemotePath,
+		TransferMode:    request.TransferMode,
+		MirrorDeletions: request.MirrorDeletions,
+		ProtectedPaths:  request.ProtectedPaths,
+		ReleaseMode:     request.ReleaseMode,
+		KeepReleases:    request.KeepReleases,
+		PreDeployHooks:  request.PreDeployHooks,
+		PostDeployHooks: request.PostDeployHooks,
+	}
+
+	if err := utils.ValidateTransferMode(sshConfig.TransferMode); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := utils.ValidateDeployHooks(sshConfig.PreDeployHooks, sshConfig.PostDeployHooks); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 服务器地址未变化时保留已信任的主机密钥
+	if existing := config.GetSSHConfig(); existing.Host == sshConfig.Host && existing.Port == sshConfig.Port {
+		sshConfig.HostKey = existing.HostKey
+		sshConfig.PendingHostKey = existing.PendingHostKey
 	}
 
 	config.SetSSHConfig(sshConfig)
@@ -154,6 +187,12 @@ func BuildHugo(c *gin.Context) {
 		}
 	}
 
+	lock, ok := acquireDeployLock(c, "Hugo构建", true)
+	if !ok {
+		return
+	}
+	defer lock.Release()
+
 	// 广播构建开始
 	utils.BroadcastBuildProgress("正在构建Hugo静态文件...", 0)
 
@@ -210,8 +249,17 @@ func DeployToServer(c *gin.Context) {
 	// 广播部署开始
 	utils.BroadcastDeployProgress("正在连接服务器...", 0, 100, 0, "")
 
+	record := startDeploymentRecord(c, config.DeployTriggerManual, "", sshConfig.Host, false, false)
+
 	// 使用原生Go SSH进行部署
 	result, err := utils.ExecuteDeployment(sshConfig, publicDir, sshConfig.RemotePath, false)
+	recordSSHHostKey(sshConfig, result.HostKey, err)
+	finishDeploymentRecord(record, result, err)
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		config.UpdateDeploymentStatus("failed", mismatch.Error())
+		respondHostKeyMismatch(c, mismatch, nil)
+		return
+	}
 	if err != nil {
 		config.UpdateDeploymentStatus("failed", "部署失败: "+err.Error())
 		c.JSON(500, gin.H{
@@ -239,8 +287,11 @@ func DeployToServer(c *gin.Context) {
 		"output":  result.Output,
 		"stats": gin.H{
 			"files_deployed":    result.FilesDeployed,
+			"files_deleted":     result.FilesDeleted,
 			"bytes_transferred": result.BytesTransferred,
+			"release":           result.Release,
 		},
+		"deployment_id": record.ID,
 	})
 }
 
@@ -260,14 +311,14 @@ func TestSSHConnection(c *gin.Context) {
 	}
 
 	// 检查认证方式
-	if sshConfig.KeyPath == "" && sshConfig.Password == "" {
+	if sshConfig.KeyPath == "" && sshConfig.Password == "" && !sshConfig.UseAgent && os.Getenv("SSH_AUTH_SOCK") == "" {
 		c.JSON(400, gin.H{"error": "未配置SSH密码或密钥，请选择一种认证方式"})
 		return
 	}
 
 	// 如果使用密钥认证，检查密钥文件
 	if sshConfig.KeyPath != "" {
-		if _, err := os.Stat(sshConfig.KeyPath); os.IsNotExist(err) {
+		if _, err := os.Stat(utils.ExpandHomePath(sshConfig.KeyPath)); os.IsNotExist(err) {
 			c.JSON(400, gin.H{
 				"error":  "SSH密钥文件不存在: " + sshConfig.KeyPath,
 				"output": "请检查密钥文件路径是否正确",
@@ -290,7 +341,12 @@ func TestSSHConnection(c *gin.Context) {
 	}
 
 	// 使用原生Go SSH库进行连接测试
-	err := utils.TestSSHConnection(sshConfig)
+	hostKey, err := utils.TestSSHConnection(sshConfig)
+	recordSSHHostKey(sshConfig, hostKey, err)
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		respondHostKeyMismatch(c, mismatch, nil)
+		return
+	}
 	if err != nil {
 		errorMsg := err.Error()
 
@@ -328,19 +384,29 @@ func BuildAndDeploy(c *gin.Context) {
 		return
 	}
 
+	lock, ok := acquireDeployLock(c, "构建和部署", true)
+	if !ok {
+		return
+	}
+
 	// 更新构建状态
 	config.UpdateDeploymentStatus("building", "正在构建Hugo静态文件...")
 
 	// 广播构建开始
 	utils.BroadcastBuildProgress("正在构建Hugo静态文件...", 0)
 
+	record := startDeploymentRecord(c, config.DeployTriggerManual, "", config.GetSSHConfig().Host, false, true)
+
 	// 执行Hugo构建
 	buildCmd := exec.Command("hugo", "--source", projectPath)
 	buildOutput, err := buildCmd.CombinedOutput()
 	buildOutputStr := string(buildOutput)
+	record.BuildOutput = buildOutputStr
+	lock.Release()
 
 	if err != nil {
 		config.UpdateDeploymentStatus("failed", "Hugo构建失败: "+err.Error())
+		failDeploymentRecord(record, "Hugo构建失败: "+err.Error())
 		utils.BroadcastError("build", "Hugo构建失败: "+err.Error())
 		c.JSON(500, gin.H{
 			"error":  "Hugo构建失败: " + err.Error(),
@@ -357,6 +423,7 @@ func BuildAndDeploy(c *gin.Context) {
 
 	if sshConfig.Host == "" || sshConfig.Username == "" || sshConfig.RemotePath == "" {
 		config.UpdateDeploymentStatus("failed", "SSH配置不完整")
+		failDeploymentRecord(record, "SSH配置不完整")
 		utils.BroadcastError("deploy", "SSH配置不完整")
 		c.JSON(400, gin.H{
 			"error":        "SSH配置不完整，请先配置SSH连接信息",
@@ -375,6 +442,13 @@ func BuildAndDeploy(c *gin.Context) {
 
 	// 使用原生Go SSH进行部署
 	result, err := utils.ExecuteDeployment(sshConfig, publicDir, sshConfig.RemotePath, false)
+	recordSSHHostKey(sshConfig, result.HostKey, err)
+	finishDeploymentRecord(record, result, err)
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		config.UpdateDeploymentStatus("failed", mismatch.Error())
+		respondHostKeyMismatch(c, mismatch, gin.H{"build_output": buildOutputStr})
+		return
+	}
 	if err != nil {
 		config.UpdateDeploymentStatus("failed", "部署失败: "+err.Error())
 		c.JSON(500, gin.H{
@@ -405,8 +479,11 @@ func BuildAndDeploy(c *gin.Context) {
 		"deploy_output": result.Output,
 		"stats": gin.H{
 			"files_deployed":    result.FilesDeployed,
+			"files_deleted":     result.FilesDeleted,
 			"bytes_transferred": result.BytesTransferred,
+			"release":           result.Release,
 		},
+		"deployment_id": record.ID,
 	})
 }
 
@@ -490,13 +567,22 @@ func CheckDecryptionStatus(c *gin.Context) {
 // 使用加密保存SSH配置
 func UpdateSSHConfigWithEncryption(c *gin.Context) {
 	var request struct {
-		Host           string `json:"host"`
-		Port           int    `json:"port"`
-		Username       string `json:"username"`
-		Password       string `json:"password"`
-		KeyPath        string `json:"key_path"`
-		RemotePath     string `json:"remote_path"`
-		MasterPassword string `json:"master_password"`
+		Host            string              `json:"host"`
+		Port            int                 `json:"port"`
+		Username        string              `json:"username"`
+		Password        string              `json:"password"`
+		KeyPath         string              `json:"key_path"`
+		KeyPassphrase   string              `json:"key_passphrase"`
+		UseAgent        bool                `json:"use_agent"`
+		RemotePath      string              `json:"remote_path"`
+		TransferMode    string              `json:"transfer_mode"`
+		MirrorDeletions bool                `json:"mirror_deletions"`
+		ProtectedPaths  []string            `json:"protected_paths"`
+		ReleaseMode     bool                `json:"release_mode"`
+		KeepReleases    int                 `json:"keep_releases"`
+		PreDeployHooks  []config.DeployHook `json:"pre_deploy_hooks"`
+		PostDeployHooks []config.DeployHook `json:"post_deploy_hooks"`
+		MasterPassword  string              `json:"master_password"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -505,22 +591,47 @@ func UpdateSSHConfigWithEncryption(c *gin.Context) {
 	}
 
 	sshConfig := config.SSHConfig{
-		Host:       request.Host,
-		Port:       request.Port,
-		Username:   request.Username,
-		Password:   request.Password,
-		KeyPath:    request.KeyPath,
-		RemotePath: request.RemotePath,
+		Host:            request.Host,
+		Port:            request.Port,
+		Username:        request.Username,
+		Password:        request.Password,
+		KeyPath:         request.KeyPath,
+		KeyPassphrase:   request.KeyPassphrase,
+		UseAgent:        request.UseAgent,
+		RemotePath:      request.RemotePath,
+		TransferMode:    request.TransferMode,
+		MirrorDeletions: request.MirrorDeletions,
+		ProtectedPaths:  request.ProtectedPaths,
+		ReleaseMode:     request.ReleaseMode,
+		KeepReleases:    request.KeepReleases,
+		PreDeployHooks:  request.PreDeployHooks,
+		PostDeployHooks: request.PostDeployHooks,
+	}
+
+	if err := utils.ValidateTransferMode(sshConfig.TransferMode); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := utils.ValidateDeployHooks(sshConfig.PreDeployHooks, sshConfig.PostDeployHooks); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
 	}
 
-	// 如果提供了密码，需要主密码来加密
-	if request.Password != "" && request.MasterPassword == "" {
+	// 服务器地址未变化时保留已信任的主机密钥
+	if existing := config.GetSSHConfig(); existing.Host == sshConfig.Host && existing.Port == sshConfig.Port {
+		sshConfig.HostKey = existing.HostKey
+		sshConfig.PendingHostKey = existing.PendingHostKey
+	}
+
+	// 如果提供了密码或私钥密码，需要主密码来加密
+	hasSecret := request.Password != "" || request.KeyPassphrase != ""
+	if hasSecret && request.MasterPassword == "" {
 		c.JSON(400, gin.H{"error": "保存SSH密码需要提供主密码进行加密"})
 		return
 	}
 
 	var err error
-	if request.Password != "" {
+	if hasSecret {
 		err = config.SetSSHConfigWithEncryption(sshConfig, request.MasterPassword)
 		// 设置解密密钥以便立即可用
 		config.SetDecryptionKey(request.MasterPassword)
@@ -559,8 +670,17 @@ func IncrementalDeployToServer(c *gin.Context) {
 	// 更新部署状态为开始增量部署
 	config.UpdateDeploymentStatus("deploying", "正在进行增量部署，只传输变化的文件...")
 
+	record := startDeploymentRecord(c, config.DeployTriggerManual, "", sshConfig.Host, true, false)
+
 	// 使用原生Go SSH进行增量部署
 	result, err := utils.ExecuteDeployment(sshConfig, publicDir, sshConfig.RemotePath, true)
+	recordSSHHostKey(sshConfig, result.HostKey, err)
+	finishDeploymentRecord(record, result, err)
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		config.UpdateDeploymentStatus("failed", mismatch.Error())
+		respondHostKeyMismatch(c, mismatch, nil)
+		return
+	}
 	if err != nil {
 		config.UpdateDeploymentStatus("failed", "增量部署失败: "+err.Error())
 		c.JSON(500, gin.H{
@@ -588,8 +708,11 @@ func IncrementalDeployToServer(c *gin.Context) {
 		"output":  result.Output,
 		"stats": gin.H{
 			"files_deployed":    result.FilesDeployed,
+			"files_deleted":     result.FilesDeleted,
 			"bytes_transferred": result.BytesTransferred,
+			"release":           result.Release,
 		},
+		"deployment_id": record.ID,
 	})
 }
 
@@ -603,16 +726,26 @@ func IncrementalBuildAndDeploy(c *gin.Context) {
 		return
 	}
 
+	lock, ok := acquireDeployLock(c, "增量构建和部署", true)
+	if !ok {
+		return
+	}
+
 	// 更新构建状态
 	config.UpdateDeploymentStatus("building", "正在构建Hugo静态文件...")
 
+	record := startDeploymentRecord(c, config.DeployTriggerManual, "", config.GetSSHConfig().Host, true, true)
+
 	// 执行Hugo构建
 	buildCmd := exec.Command("hugo", "--source", projectPath)
 	buildOutput, err := buildCmd.CombinedOutput()
 	buildOutputStr := string(buildOutput)
+	record.BuildOutput = buildOutputStr
+	lock.Release()
 
 	if err != nil {
 		config.UpdateDeploymentStatus("failed", "Hugo构建失败: "+err.Error())
+		failDeploymentRecord(record, "Hugo构建失败: "+err.Error())
 		c.JSON(500, gin.H{
 			"error":  "Hugo构建失败: " + err.Error(),
 			"output": buildOutputStr,
@@ -625,6 +758,7 @@ func IncrementalBuildAndDeploy(c *gin.Context) {
 
 	if sshConfig.Host == "" || sshConfig.Username == "" || sshConfig.RemotePath == "" {
 		config.UpdateDeploymentStatus("failed", "SSH配置不完整")
+		failDeploymentRecord(record, "SSH配置不完整")
 		c.JSON(400, gin.H{
 			"error":        "SSH配置不完整，请先配置SSH连接信息",
 			"build_output": buildOutputStr,
@@ -639,6 +773,13 @@ func IncrementalBuildAndDeploy(c *gin.Context) {
 
 	// 使用原生Go SSH进行增量部署
 	result, err := utils.ExecuteDeployment(sshConfig, publicDir, sshConfig.RemotePath, true)
+	recordSSHHostKey(sshConfig, result.HostKey, err)
+	finishDeploymentRecord(record, result, err)
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		config.UpdateDeploymentStatus("failed", mismatch.Error())
+		respondHostKeyMismatch(c, mismatch, gin.H{"build_output": buildOutputStr})
+		return
+	}
 	if err != nil {
 		config.UpdateDeploymentStatus("failed", "增量部署失败: "+err.Error())
 		c.JSON(500, gin.H{
@@ -669,8 +810,11 @@ func IncrementalBuildAndDeploy(c *gin.Context) {
 		"deploy_output": result.Output,
 		"stats": gin.H{
 			"files_deployed":    result.FilesDeployed,
+			"files_deleted":     result.FilesDeleted,
 			"bytes_transferred": result.BytesTransferred,
+			"release":           result.Release,
 		},
+		"deployment_id": record.ID,
 	})
 }
 
@@ -707,10 +851,19 @@ func ResumeDeployment(c *gin.Context) {
 
 	publicDir := config.GetPublicDir()
 
+	record := startDeploymentRecord(c, config.DeployTriggerResume, "", sshConfig.Host, true, false)
+
 	// 使用原生Go SSH进行部署
 	result, err := utils.ExecuteDeployment(sshConfig, publicDir, sshConfig.RemotePath, true)
+	recordSSHHostKey(sshConfig, result.HostKey, err)
+	finishDeploymentRecord(record, result, err)
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		config.UpdateDeploymentStatus("failed", mismatch.Error())
+		respondHostKeyMismatch(c, mismatch, nil)
+		return
+	}
 	if err != nil {
-		if strings.Contains(err.Error(), "暂停") {
+		if errors.Is(err, utils.ErrDeploymentPaused) {
 			c.JSON(200, gin.H{
 				"message": "部署已暂停",
 				"status":  "paused",
@@ -744,8 +897,11 @@ func ResumeDeployment(c *gin.Context) {
 		"output":  result.Output,
 		"stats": gin.H{
 			"files_deployed":    result.FilesDeployed,
+			"files_deleted":     result.FilesDeleted,
 			"bytes_transferred": result.BytesTransferred,
+			"release":           result.Release,
 		},
+		"deployment_id": record.ID,
 	})
 }
 
@@ -890,6 +1046,12 @@ func UpdateMasterPassword(c *gin.Context) {
 		return
 	}
 
+	// 服务器凭据同样用新密码重新加密
+	if err := config.ReencryptServerCredentials(request.OldMasterPassword, request.NewMasterPassword); err != nil {
+		c.JSON(500, gin.H{"error": "重新加密服务器凭据失败: " + err.Error()})
+		return
+	}
+
 	// 设置新的解密密钥
 	config.SetDecryptionKey(request.NewMasterPassword)
 
@@ -903,9 +1065,18 @@ func UpdateMasterPassword(c *gin.Context) {
 
 // 获取所有服务器配置
 func GetMultiServerConfigs(c *gin.Context) {
-	servers := config.GetServerConfigs()
+	var selector config.ServerSelector
+	c.ShouldBindQuery(&selector)
+
+	servers := []config.ServerConfig{}
+	for _, server := range config.SelectServers(selector) {
+		servers = append(servers, redactServerCredentials(server))
+	}
+	groups, tags := config.ListServerLabels()
 	c.JSON(200, gin.H{
 		"servers": servers,
+		"groups":  groups,
+		"tags":    tags,
 	})
 }
 
@@ -919,27 +1090,131 @@ func GetMultiServerConfig(c *gin.Context) {
 	}
 
 	c.JSON(200, gin.H{
-		"server": server,
+		"server": redactServerCredentials(server),
 	})
 }
 
+// 返回给浏览器的服务器配置不包含密码和密钥（明文和密文）
+func redactServerCredentials(server config.ServerConfig) config.ServerConfig {
+	server.Password, server.EncryptedPassword = "", ""
+	server.KeyPassphrase, server.EncryptedKeyPassphrase = "", ""
+	server.S3AccessKey, server.EncryptedS3AccessKey = "", ""
+	server.S3SecretKey, server.EncryptedS3SecretKey = "", ""
+	return server
+}
+
+// 按部署目标类型校验服务器配置
+func validateServerConfig(server config.ServerConfig) error {
+	if err := utils.ValidateDeployerType(server.Type); err != nil {
+		return err
+	}
+	if err := utils.ValidateSmokeTest(server); err != nil {
+		return err
+	}
+	if err := utils.ValidateDeployPatterns(server.ExcludePatterns); err != nil {
+		return fmt.Errorf("排除规则: %v", err)
+	}
+	if err := utils.ValidateDeployPatterns(server.IncludePatterns); err != nil {
+		return fmt.Errorf("包含规则: %v", err)
+	}
+	if server.Environment != "" {
+		if _, err := config.GetEnvironment(server.Environment); err != nil {
+			return errors.New("部署环境不存在")
+		}
+	}
+	if err := config.ValidateServerLabel(server.Group); err != nil {
+		return fmt.Errorf("分组%v", err)
+	}
+	for _, tag := range server.Tags {
+		if err := config.ValidateServerLabel(tag); err != nil {
+			return fmt.Errorf("标签%v", err)
+		}
+	}
+	if server.SmokeTestAction == config.SmokeTestActionRollback &&
+		(utils.ServerDeployerType(server) != utils.DeployerTypeSSH || !server.ReleaseMode) {
+		return errSmokeTestRollbackUnsupported
+	}
+	if server.SnapshotMode != "" && utils.ServerDeployerType(server) != utils.DeployerTypeSSH {
+		return errors.New("部署前快照仅支持SSH服务器")
+	}
+	if err := utils.ValidateTransferLimits(server.UploadWorkers, server.BandwidthLimit); err != nil {
+		return err
+	}
+
+	switch utils.ServerDeployerType(server) {
+	case utils.DeployerTypeLocal:
+		if server.Name == "" || server.RemotePath == "" {
+			return errors.New("服务器名称和目标目录不能为空")
+		}
+		if !filepath.IsAbs(server.RemotePath) {
+			return errors.New("目标目录必须是绝对路径")
+		}
+	case utils.DeployerTypeS3:
+		if server.Name == "" || server.S3Endpoint == "" || server.S3Bucket == "" {
+			return errors.New("服务器名称、S3服务地址和存储桶不能为空")
+		}
+	case utils.DeployerTypeGit:
+		if server.Name == "" || server.GitRemote == "" {
+			return errors.New("服务器名称和Git远程仓库不能为空")
+		}
+	default:
+		if server.Name == "" || server.Host == "" || (server.Username == "" && server.EncryptedUsername == "") || server.RemotePath == "" {
+			return errors.New("服务器名称、地址、用户名和远程路径不能为空")
+		}
+		if err := utils.ValidateTransferMode(server.TransferMode); err != nil {
+			return err
+		}
+		if _, err := config.ResolveJumpChain(server); err != nil {
+			return err
+		}
+		if err := utils.ValidateDeployHooks(server.PreDeployHooks, server.PostDeployHooks); err != nil {
+			return err
+		}
+		if err := utils.ValidatePermissions(server.FileMode, server.DirMode, server.FileGroup); err != nil {
+			return err
+		}
+		if err := utils.ValidateSnapshotMode(server.SnapshotMode, server.KeepSnapshots, server.ReleaseMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 服务器配置请求，主密码用于加密密码和密钥，已解锁时可以为空
+type serverConfigRequest struct {
+	config.ServerConfig
+	MasterPassword string `json:"master_password"`
+}
+
 // 添加服务器配置
 func AddMultiServerConfig(c *gin.Context) {
-	var request config.ServerConfig
+	var body serverConfigRequest
 
-	if err := c.ShouldBindJSON(&request); err != nil {
+	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(400, gin.H{"error": "请求格式错误: " + err.Error()})
 		return
 	}
+	request := body.ServerConfig
+
+	config.NormalizeServerLabels(&request)
+	// 加密凭据只能由服务端根据明文生成
+	config.KeepEncryptedCredentials(&request, config.ServerConfig{})
 
 	// 验证必填字段
-	if request.Name == "" || request.Host == "" || request.Username == "" || request.RemotePath == "" {
-		c.JSON(400, gin.H{"error": "服务器名称、地址、用户名和远程路径不能为空"})
+	if err := validateServerConfig(request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
+	// 主机密钥在首次测试连接时记录，或从known_hosts导入
+	request.HostKey = ""
+	request.PendingHostKey = ""
+
 	// 添加服务器
-	config.AddServerConfig(request)
+	if err := config.SaveServerConfig("", request, body.MasterPassword); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(200, gin.H{
 		"message": "服务器配置已添加",
@@ -949,23 +1224,61 @@ func AddMultiServerConfig(c *gin.Context) {
 // 更新服务器配置
 func UpdateMultiServerConfig(c *gin.Context) {
 	serverID := c.Param("server_id")
-	var request config.ServerConfig
+	var body serverConfigRequest
 
-	if err := c.ShouldBindJSON(&request); err != nil {
+	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(400, gin.H{"error": "请求格式错误: " + err.Error()})
 		return
 	}
+	request := body.ServerConfig
+	request.ID = serverID
+
+	existing, err := config.GetServerConfig(serverID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+
+	config.NormalizeServerLabels(&request)
+	// 编辑时不回显密码和密钥，留空表示沿用原来的（明文或加密保存的）
+	if request.Password == "" && request.KeyPath == "" {
+		request.Password = existing.Password
+	}
+	if request.KeyPassphrase == "" && request.KeyPath != "" && existing.KeyPath == request.KeyPath {
+		request.KeyPassphrase = existing.KeyPassphrase
+	}
+	if request.S3AccessKey == "" {
+		request.S3AccessKey = existing.S3AccessKey
+	}
+	if request.S3SecretKey == "" {
+		request.S3SecretKey = existing.S3SecretKey
+	}
+	config.KeepEncryptedCredentials(&request, existing)
+	// 改用私钥认证或更换私钥时不再沿用原来的密码或私钥密码
+	if request.KeyPath != "" {
+		request.EncryptedPassword = ""
+	}
+	if request.KeyPath != existing.KeyPath {
+		request.EncryptedKeyPassphrase = ""
+	}
 
 	// 验证必填字段
-	if request.Name == "" || request.Host == "" || request.Username == "" || request.RemotePath == "" {
-		c.JSON(400, gin.H{"error": "服务器名称、地址、用户名和远程路径不能为空"})
+	if err := validateServerConfig(request); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
+	// 服务器地址未变化时保留已信任的主机密钥（主机密钥只能通过测试连接或导入来更新）
+	request.HostKey = ""
+	request.PendingHostKey = ""
+	if existing.Host == request.Host && existing.Port == request.Port {
+		request.HostKey = existing.HostKey
+		request.PendingHostKey = existing.PendingHostKey
+	}
+
 	// 更新服务器
-	err := config.UpdateServerConfig(serverID, request)
-	if err != nil {
-		c.JSON(404, gin.H{"error": err.Error()})
+	if err := config.SaveServerConfig(serverID, request, body.MasterPassword); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -978,6 +1291,12 @@ func UpdateMultiServerConfig(c *gin.Context) {
 func DeleteMultiServerConfig(c *gin.Context) {
 	serverID := c.Param("server_id")
 
+	// 仍被其他服务器用作跳板机时不能删除
+	if users := config.ServersUsingJumpServer(serverID); len(users) > 0 {
+		c.JSON(409, gin.H{"error": "该服务器是以下服务器的跳板机，请先修改它们的配置: " + strings.Join(users, ", ")})
+		return
+	}
+
 	err := config.DeleteServerConfig(serverID)
 	if err != nil {
 		c.JSON(404, gin.H{"error": err.Error()})
@@ -999,29 +1318,38 @@ func TestMultiServerConnection(c *gin.Context) {
 		return
 	}
 
-	// 转换为SSH配置格式进行测试
-	sshConfig := config.SSHConfig{
-		Host:       server.Host,
-		Port:       server.Port,
-		Username:   server.Username,
-		Password:   server.Password,
-		KeyPath:    server.KeyPath,
-		RemotePath: server.RemotePath,
+	message, err := testMultiServer(server)
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		respondHostKeyMismatch(c, mismatch, nil)
+		return
 	}
-
-	err = utils.TestSSHConnection(sshConfig)
 	if err != nil {
 		c.JSON(500, gin.H{
-			"error": "SSH连接失败: " + err.Error(),
+			"error": message,
 		})
 		return
 	}
 
 	c.JSON(200, gin.H{
-		"message": "SSH连接测试成功",
+		"message": message,
 	})
 }
 
+// 按服务器类型测试部署目标并记录主机密钥，返回结果消息
+func testMultiServer(server config.ServerConfig) (string, error) {
+	hostKey, err := utils.TestServerConnection(server)
+	recordServerHostKey(server, hostKey, err)
+
+	label := "SSH连接"
+	if utils.ServerDeployerType(server) != utils.DeployerTypeSSH {
+		label = "连接"
+	}
+	if err != nil {
+		return label + "失败: " + err.Error(), err
+	}
+	return label + "测试成功", nil
+}
+
 // 部署到指定服务器
 func DeployToMultiServer(c *gin.Context) {
 	serverID := c.Param("server_id")
@@ -1037,74 +1365,15 @@ func DeployToMultiServer(c *gin.Context) {
 		return
 	}
 
-	// 更新服务器状态为部署中
-	config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-		Status:   "deploying",
-		Message:  "正在部署到 " + server.Name,
-		Progress: 0,
-		CanPause: true,
-		CanStop:  true,
-	})
-
-	// 广播部署开始消息
-	utils.BroadcastMultiServerDeployProgress(serverID, server.Name, "开始部署到 "+server.Name, 0, 100, 0, "")
-
 	// 启动部署（异步）
-	go func() {
-		publicDir := config.GetPublicDir()
-
-		// 检查public目录
-		if _, err := os.Stat(publicDir); os.IsNotExist(err) {
-			config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-				Status:  "failed",
-				Message: "public目录不存在，请先运行Hugo构建",
-			})
-			utils.BroadcastMultiServerError(serverID, server.Name, "deploy", "public目录不存在，请先运行Hugo构建")
-			return
-		}
-
-		// 转换为SSH配置格式
-		sshConfig := config.SSHConfig{
-			Host:       server.Host,
-			Port:       server.Port,
-			Username:   server.Username,
-			Password:   server.Password,
-			KeyPath:    server.KeyPath,
-			RemotePath: server.RemotePath,
-		}
-
-		// 执行部署
-		result, err := utils.ExecuteDeploymentWithServer(sshConfig, publicDir, server.RemotePath, false, serverID, server.Name)
-
-		if err != nil || !result.Success {
-			config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-				Status:  "failed",
-				Message: "部署失败: " + result.Message,
-			})
-			utils.BroadcastMultiServerError(serverID, server.Name, "deploy", "部署失败: "+result.Message)
-			return
-		}
-
-		// 更新成功状态
-		config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-			Status:           "success",
-			Message:          fmt.Sprintf("部署完成，传输了 %d 个文件", result.FilesDeployed),
-			Progress:         100,
-			FilesDeployed:    result.FilesDeployed,
-			BytesTransferred: result.BytesTransferred,
-		})
-
-		// 广播部署完成消息
-		utils.BroadcastMultiServerComplete(serverID, server.Name, "deploy", fmt.Sprintf("部署完成，传输了 %d 个文件", result.FilesDeployed), result.FilesDeployed)
-
-		// 更新服务器的最后部署时间
-		now := time.Now()
-		server.LastDeployment = &now
-		config.UpdateServerConfig(serverID, server)
-	}()
+	record := startMultiServerDeployment(c, server, false, false)
+	if record == nil {
+		return
+	}
 
 	c.JSON(200, gin.H{
-		"message": "开始部署到 " + server.Name,
+		"message":       "开始部署到 " + server.Name,
+		"deployment_id": record.ID,
 	})
 }
 
@@ -1123,92 +1392,20 @@ func BuildAndDeployToMultiServer(c *gin.Context) {
 		return
 	}
 
-	// 启动构建和部署（异步）
-	go func() {
-		// 1. 构建阶段
-		config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-			Status:   "building",
-			Message:  "正在构建Hugo站点...",
-			Progress: 0,
-		})
-
-		// 广播构建开始消息
-		utils.BroadcastMultiServerBuildProgress(serverID, server.Name, "开始构建Hugo站点...", 0)
-
-		projectPath := config.GetHugoProjectPath()
-		buildCmd := exec.Command("hugo", "--source", projectPath)
-		_, err := buildCmd.CombinedOutput()
-
-		if err != nil {
-			config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-				Status:  "failed",
-				Message: "Hugo构建失败: " + err.Error(),
-			})
-			utils.BroadcastMultiServerError(serverID, server.Name, "build", "Hugo构建失败: "+err.Error())
-			return
-		}
-
-		// 广播构建完成消息
-		utils.BroadcastMultiServerBuildProgress(serverID, server.Name, "Hugo构建完成", 100)
-
-		// 2. 部署阶段
-		config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-			Status:   "deploying",
-			Message:  "正在部署到 " + server.Name,
-			Progress: 50,
-		})
-
-		// 广播部署开始消息
-		utils.BroadcastMultiServerDeployProgress(serverID, server.Name, "开始部署到 "+server.Name, 50, 100, 50, "")
-
-		publicDir := config.GetPublicDir()
-
-		// 转换为SSH配置格式
-		sshConfig := config.SSHConfig{
-			Host:       server.Host,
-			Port:       server.Port,
-			Username:   server.Username,
-			Password:   server.Password,
-			KeyPath:    server.KeyPath,
-			RemotePath: server.RemotePath,
-		}
-
-		// 执行部署
-		result, err := utils.ExecuteDeploymentWithServer(sshConfig, publicDir, server.RemotePath, false, serverID, server.Name)
-
-		if err != nil || !result.Success {
-			config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-				Status:  "failed",
-				Message: "部署失败: " + result.Message,
-			})
-			utils.BroadcastMultiServerError(serverID, server.Name, "deploy", "部署失败: "+result.Message)
-			return
-		}
-
-		// 更新成功状态
-		config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-			Status:           "success",
-			Message:          fmt.Sprintf("构建和部署完成，传输了 %d 个文件", result.FilesDeployed),
-			Progress:         100,
-			FilesDeployed:    result.FilesDeployed,
-			BytesTransferred: result.BytesTransferred,
-		})
-
-		// 广播部署完成消息
-		utils.BroadcastMultiServerComplete(serverID, server.Name, "deploy", fmt.Sprintf("构建和部署完成，传输了 %d 个文件", result.FilesDeployed), result.FilesDeployed)
-
-		// 更新服务器的最后部署时间
-		now := time.Now()
-		server.LastDeployment = &now
-		config.UpdateServerConfig(serverID, server)
-	}()
+	// 启动部署（异步）
+	record := startMultiServerDeployment(c, server, false, true)
+	if record == nil {
+		return
+	}
 
 	c.JSON(200, gin.H{
-		"message": "开始构建并部署到 " + server.Name,
+		"message":       "开始构建并部署到 " + server.Name,
+		"deployment_id": record.ID,
 	})
 }
 
 // 暂停服务器部署
+// 正在上传的文件完成后停止，未完成的任务保留在服务器的上传队列中
 func PauseMultiServerDeployment(c *gin.Context) {
 	serverID := c.Param("server_id")
 
@@ -1217,22 +1414,34 @@ func PauseMultiServerDeployment(c *gin.Context) {
 		c.JSON(404, gin.H{"error": "服务器不存在"})
 		return
 	}
+	if err := pauseServerDeployment(server); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
-	config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-		Status:    "paused",
-		Message:   "部署已暂停",
-		CanResume: true,
+	c.JSON(200, gin.H{
+		"message": "正在暂停部署",
 	})
+}
 
-	// 广播暂停消息
-	utils.BroadcastMultiServerPause(serverID, server.Name, "部署已暂停", 0, 0, 0)
+// 发出暂停信号，上传实际停止后部署状态更新为已暂停
+func pauseServerDeployment(server config.ServerConfig) error {
+	if utils.ServerDeployerType(server) != utils.DeployerTypeSSH {
+		return errors.New("只有SSH服务器的部署可以暂停")
+	}
+	if status := config.GetServerDeploymentStatus(server.ID); status.Status != "deploying" {
+		return errors.New("服务器没有正在进行的部署")
+	}
 
-	c.JSON(200, gin.H{
-		"message": "部署已暂停",
-	})
+	config.SetServerDeploymentPaused(server.ID, true)
+
+	// 广播暂停消息
+	utils.BroadcastMultiServerProgress(server.ID, server.Name, "deploy", "deploying", "正在暂停部署...", 0, 0, 0, "")
+	return nil
 }
 
-// 继续服务器部署
+// 继续服务器部署：上传服务器上传队列中未完成的文件
+// 程序重启后同样可以继续
 func ResumeMultiServerDeployment(c *gin.Context) {
 	serverID := c.Param("server_id")
 
@@ -1241,19 +1450,65 @@ func ResumeMultiServerDeployment(c *gin.Context) {
 		c.JSON(404, gin.H{"error": "服务器不存在"})
 		return
 	}
+	if !server.Enabled {
+		c.JSON(400, gin.H{"error": "服务器已禁用"})
+		return
+	}
 
+	pendingCount := config.GetServerPendingTasksCount(serverID)
+	if pendingCount == 0 {
+		c.JSON(400, gin.H{"error": "没有待处理的上传任务"})
+		return
+	}
+
+	publicDir, artifactID, err := resumeSiteDir(server)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	lock, ok := acquireDeployLock(c, "继续部署", false, serverID)
+	if !ok {
+		return
+	}
+
+	config.SetServerDeploymentPaused(serverID, false)
+	record := startDeploymentRecord(c, config.DeployTriggerResume, server.ID, server.Name, true, false)
+	if artifactID != "" {
+		record.Artifact = artifactID
+		config.SaveDeploymentRecord(*record)
+	}
+	lock.Update(func(lock *config.DeployLock) {
+		lock.DeploymentID = record.ID
+	})
+
+	message := fmt.Sprintf("继续部署到 %s，剩余 %d 个文件", server.Name, pendingCount)
 	config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
 		Status:   "deploying",
-		Message:  "继续部署到 " + server.Name,
+		Message:  message,
 		CanPause: true,
 		CanStop:  true,
 	})
 
 	// 广播继续部署消息
-	utils.BroadcastMultiServerDeployProgress(serverID, server.Name, "继续部署到 "+server.Name, 0, 100, 0, "")
+	utils.BroadcastMultiServerDeployProgress(serverID, server.Name, message, 0, 100, 0, "")
 
+	go func() {
+		defer lock.Release()
+		deployBuiltSiteToServer(server, publicDir, true, "继续部署", record)
+	}()
+
+	c.JSON(200, gin.H{
+		"message":       message,
+		"pending_tasks": pendingCount,
+		"deployment_id": record.ID,
+	})
+}
+
+// 获取可以继续的部署（上传队列中还有未完成的任务），包括程序退出时中断的部署
+func GetResumableDeployments(c *gin.Context) {
 	c.JSON(200, gin.H{
-		"message": "继续部署到 " + server.Name,
+		"deployments": config.ListResumableDeployments(),
 	})
 }
 
@@ -1267,17 +1522,26 @@ func StopMultiServerDeployment(c *gin.Context) {
 		return
 	}
 
-	config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
+	stopServerDeployment(server)
+
+	c.JSON(200, gin.H{
+		"message": "部署已停止",
+	})
+}
+
+// 停止服务器部署：放弃未完成的上传任务，正在上传时通过暂停信号停止
+func stopServerDeployment(server config.ServerConfig) {
+	status := config.GetServerDeploymentStatus(server.ID)
+	config.SetServerUploadTasks(server.ID, nil)
+	config.SetServerDeploymentPaused(server.ID, status.Status == "deploying" && utils.ServerDeployerType(server) == utils.DeployerTypeSSH)
+
+	config.UpdateServerDeploymentStatus(server.ID, config.ServerDeploymentStatus{
 		Status:  "idle",
 		Message: "部署已停止",
 	})
 
 	// 广播停止部署消息
-	utils.BroadcastMultiServerProgress(serverID, server.Name, "deploy", "idle", "部署已停止", 0, 0, 0, "")
-
-	c.JSON(200, gin.H{
-		"message": "部署已停止",
-	})
+	utils.BroadcastMultiServerProgress(server.ID, server.Name, "deploy", "idle", "部署已停止", 0, 0, 0, "")
 }
 
 // 增量部署到指定服务器
@@ -1295,73 +1559,15 @@ func IncrementalDeployToMultiServer(c *gin.Context) {
 		return
 	}
 
-	// 更新服务器状态为增量部署中
-	config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-		Status:   "deploying",
-		Message:  "正在增量部署到 " + server.Name,
-		Progress: 0,
-		CanPause: true,
-		CanStop:  true,
-	})
-
-	// 广播增量部署开始消息
-	utils.BroadcastMultiServerDeployProgress(serverID, server.Name, "开始增量部署到 "+server.Name, 0, 100, 0, "")
-
-	// 启动增量部署（异步）
-	go func() {
-		publicDir := config.GetPublicDir()
-
-		// 检查public目录
-		if _, err := os.Stat(publicDir); os.IsNotExist(err) {
-			config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-				Status:  "failed",
-				Message: "public目录不存在，请先运行Hugo构建",
-			})
-			return
-		}
-
-		// 转换为SSH配置格式
-		sshConfig := config.SSHConfig{
-			Host:       server.Host,
-			Port:       server.Port,
-			Username:   server.Username,
-			Password:   server.Password,
-			KeyPath:    server.KeyPath,
-			RemotePath: server.RemotePath,
-		}
-
-		// 执行增量部署
-		result, err := utils.ExecuteDeploymentWithServer(sshConfig, publicDir, server.RemotePath, true, serverID, server.Name)
-
-		if err != nil || !result.Success {
-			config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-				Status:  "failed",
-				Message: "增量部署失败: " + result.Message,
-			})
-			utils.BroadcastMultiServerError(serverID, server.Name, "deploy", "增量部署失败: "+result.Message)
-			return
-		}
-
-		// 更新成功状态
-		config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-			Status:           "success",
-			Message:          fmt.Sprintf("增量部署完成，传输了 %d 个文件", result.FilesDeployed),
-			Progress:         100,
-			FilesDeployed:    result.FilesDeployed,
-			BytesTransferred: result.BytesTransferred,
-		})
-
-		// 广播增量部署完成消息
-		utils.BroadcastMultiServerComplete(serverID, server.Name, "deploy", fmt.Sprintf("增量部署完成，传输了 %d 个文件", result.FilesDeployed), result.FilesDeployed)
-
-		// 更新服务器的最后部署时间
-		now := time.Now()
-		server.LastDeployment = &now
-		config.UpdateServerConfig(serverID, server)
-	}()
+	// 启动部署（异步）
+	record := startMultiServerDeployment(c, server, true, false)
+	if record == nil {
+		return
+	}
 
 	c.JSON(200, gin.H{
-		"message": "开始增量部署到 " + server.Name,
+		"message":       "开始增量部署到 " + server.Name,
+		"deployment_id": record.ID,
 	})
 }
 
@@ -1380,95 +1586,41 @@ func IncrementalBuildAndDeployToMultiServer(c *gin.Context) {
 		return
 	}
 
-	// 启动增量构建和部署（异步）
-	go func() {
-		// 1. 构建阶段
-		config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-			Status:   "building",
-			Message:  "正在构建Hugo站点...",
-			Progress: 0,
-		})
-
-		// 广播增量构建开始消息
-		utils.BroadcastMultiServerBuildProgress(serverID, server.Name, "开始增量构建Hugo站点...", 0)
-
-		projectPath := config.GetHugoProjectPath()
-		buildCmd := exec.Command("hugo", "--source", projectPath)
-		_, err := buildCmd.CombinedOutput()
-
-		if err != nil {
-			config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-				Status:  "failed",
-				Message: "Hugo构建失败: " + err.Error(),
-			})
-			utils.BroadcastMultiServerError(serverID, server.Name, "build", "Hugo构建失败: "+err.Error())
-			return
-		}
-
-		// 广播构建完成消息
-		utils.BroadcastMultiServerBuildProgress(serverID, server.Name, "Hugo构建完成", 100)
-
-		// 2. 增量部署阶段
-		config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-			Status:   "deploying",
-			Message:  "正在增量部署到 " + server.Name,
-			Progress: 50,
-		})
-
-		// 广播部署开始消息
-		utils.BroadcastMultiServerDeployProgress(serverID, server.Name, "开始增量部署到 "+server.Name, 50, 100, 50, "")
-
-		publicDir := config.GetPublicDir()
-
-		// 转换为SSH配置格式
-		sshConfig := config.SSHConfig{
-			Host:       server.Host,
-			Port:       server.Port,
-			Username:   server.Username,
-			Password:   server.Password,
-			KeyPath:    server.KeyPath,
-			RemotePath: server.RemotePath,
-		}
-
-		// 执行增量部署
-		result, err := utils.ExecuteDeploymentWithServer(sshConfig, publicDir, server.RemotePath, true, serverID, server.Name)
-
-		if err != nil || !result.Success {
-			config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-				Status:  "failed",
-				Message: "增量部署失败: " + result.Message,
-			})
-			utils.BroadcastMultiServerError(serverID, server.Name, "deploy", "增量部署失败: "+result.Message)
-			return
-		}
-
-		// 更新成功状态
-		config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
-			Status:           "success",
-			Message:          fmt.Sprintf("增量构建和部署完成，传输了 %d 个文件", result.FilesDeployed),
-			Progress:         100,
-			FilesDeployed:    result.FilesDeployed,
-			BytesTransferred: result.BytesTransferred,
-		})
-
-		// 广播增量构建和部署完成消息
-		utils.BroadcastMultiServerComplete(serverID, server.Name, "deploy", fmt.Sprintf("增量构建和部署完成，传输了 %d 个文件", result.FilesDeployed), result.FilesDeployed)
-
-		// 更新服务器的最后部署时间
-		now := time.Now()
-		server.LastDeployment = &now
-		config.UpdateServerConfig(serverID, server)
-	}()
+	// 启动部署（异步）
+	record := startMultiServerDeployment(c, server, true, true)
+	if record == nil {
+		return
+	}
 
 	c.JSON(200, gin.H{
-		"message": "开始增量构建并部署到 " + server.Name,
+		"message":       "开始增量构建并部署到 " + server.Name,
+		"deployment_id": record.ID,
 	})
 }
 
-// 获取所有服务器状态
+// 获取所有服务器状态，支持参数 group 和 tag 筛选，groups 为按分组汇总的状态
 func GetMultiServerStatuses(c *gin.Context) {
+	var selector config.ServerSelector
+	c.ShouldBindQuery(&selector)
+
 	statuses := config.GetAllServerStatuses()
+	if selector.IsEmpty() {
+		c.JSON(200, gin.H{
+			"statuses": statuses,
+			"groups":   aggregateServerStatuses(config.GetServerConfigs(), statuses),
+		})
+		return
+	}
+
+	servers := config.SelectServers(selector)
+	filtered := make(map[string]config.ServerDeploymentStatus, len(servers))
+	for _, server := range servers {
+		if status, exists := statuses[server.ID]; exists {
+			filtered[server.ID] = status
+		}
+	}
 	c.JSON(200, gin.H{
-		"statuses": statuses,
+		"statuses": filtered,
+		"groups":   aggregateServerStatuses(servers, statuses),
 	})
 }
@@ -0,0 +1,308 @@
+package controller
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// 批量部署默认同时部署的服务器数
+const defaultDeployParallelism = 3
+
+// 部署到所有启用的服务器：只构建一次，然后按并发数同时部署
+// 可以通过 server_ids 指定服务器，或通过 group、tag 按分组和标签选择
+// 属于环境的服务器需要部署环境的构件，指定时返回错误，否则跳过
+func DeployToAllServers(c *gin.Context) {
+	var request struct {
+		Build       bool     `json:"build"`
+		Incremental bool     `json:"incremental"`
+		Parallelism int      `json:"parallelism"`
+		FailFast    bool     `json:"fail_fast"`
+		ServerIDs   []string `json:"server_ids"` // 为空时部署到所有启用的服务器
+		Group       string   `json:"group"`      // 只部署到指定分组的服务器
+		Tag         string   `json:"tag"`        // 只部署到带有指定标签的服务器
+	}
+	// 允许不带请求体
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"error": "请求格式错误"})
+			return
+		}
+	}
+
+	if request.Parallelism < 0 {
+		c.JSON(400, gin.H{"error": "并发数不能为负数"})
+		return
+	}
+	if request.Parallelism == 0 {
+		request.Parallelism = defaultDeployParallelism
+	}
+
+	var servers []config.ServerConfig
+	var environmentBound []string
+	if len(request.ServerIDs) > 0 {
+		for _, serverID := range request.ServerIDs {
+			server, err := config.GetServerConfig(serverID)
+			if err != nil {
+				c.JSON(404, gin.H{"error": "服务器不存在: " + serverID})
+				return
+			}
+			if !server.Enabled {
+				c.JSON(400, gin.H{"error": "服务器已禁用: " + server.Name})
+				return
+			}
+			if err := environmentBoundError(server); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			servers = append(servers, server)
+		}
+	} else {
+		for _, server := range config.GetServerConfigs() {
+			if !server.Enabled {
+				continue
+			}
+			if server.Environment != "" {
+				environmentBound = append(environmentBound, server.Name)
+				continue
+			}
+			servers = append(servers, server)
+		}
+	}
+	if selector := (config.ServerSelector{Group: request.Group, Tag: request.Tag}); !selector.IsEmpty() {
+		var selected []config.ServerConfig
+		for _, server := range servers {
+			if selector.Matches(server) {
+				selected = append(selected, server)
+			}
+		}
+		if len(selected) == 0 {
+			c.JSON(400, gin.H{"error": "没有匹配的已启用服务器: " + selector.String()})
+			return
+		}
+		servers = selected
+	}
+	if len(servers) == 0 {
+		if len(environmentBound) > 0 {
+			c.JSON(400, gin.H{"error": "启用的服务器都属于环境，请通过构建环境部署: " + strings.Join(environmentBound, ", ")})
+			return
+		}
+		c.JSON(400, gin.H{"error": "没有启用的服务器"})
+		return
+	}
+
+	serverIDs := make([]string, len(servers))
+	for i, server := range servers {
+		serverIDs[i] = server.ID
+	}
+	lock, ok := acquireDeployLock(c, "批量"+multiServerDeployLabel(request.Incremental, request.Build), request.Build, serverIDs...)
+	if !ok {
+		return
+	}
+
+	job, records := createDeployJob(c, lock, config.DeployTriggerManual, "", servers, request.Incremental, request.Build, request.Parallelism, request.FailFast)
+	go runDeployJob(job.ID, lock, servers, records, config.GetPublicDir(), request.Incremental, request.Build, request.Parallelism, request.FailFast)
+
+	message := job.Message
+	if len(environmentBound) > 0 {
+		message += "；属于环境的服务器需要通过构建环境部署，已跳过: " + strings.Join(environmentBound, ", ")
+	}
+	snapshot, _ := config.GetDeployJob(job.ID)
+	c.JSON(200, gin.H{
+		"message": message,
+		"job_id":  job.ID,
+		"job":     snapshot,
+	})
+}
+
+// 创建批量部署任务，每个服务器一条部署记录，共享同一个运行ID
+// triggeredBy 为空时使用请求的触发者，lock 为调用方已获取的各服务器的部署锁
+func createDeployJob(c *gin.Context, lock *config.DeployLockHandle, trigger, triggeredBy string, servers []config.ServerConfig, incremental, build bool, parallelism int, failFast bool) (*config.DeployJob, []*config.DeploymentRecord) {
+	job := &config.DeployJob{
+		ID:          config.GenerateDeploymentID(),
+		Status:      "running",
+		Build:       build,
+		Incremental: incremental,
+		Parallelism: parallelism,
+		FailFast:    failFast,
+		Message:     fmt.Sprintf("正在%s到 %d 个服务器", multiServerDeployLabel(incremental, build), len(servers)),
+		StartTime:   time.Now(),
+	}
+
+	records := make([]*config.DeploymentRecord, len(servers))
+	for i, server := range servers {
+		record := startDeploymentRecord(c, trigger, server.ID, server.Name, incremental, build)
+		record.RunID = job.ID
+		if triggeredBy != "" {
+			record.TriggeredBy = triggeredBy
+		}
+		config.SaveDeploymentRecord(*record)
+		records[i] = record
+
+		job.Servers = append(job.Servers, config.DeployJobServer{
+			ServerID:     server.ID,
+			ServerName:   server.Name,
+			State:        config.JobServerPending,
+			DeploymentID: record.ID,
+		})
+	}
+	config.AddDeployJob(job)
+
+	deployments := make(map[string]string, len(records))
+	for _, record := range records {
+		deployments[record.ServerID] = record.ID
+	}
+	lock.Update(func(lock *config.DeployLock) {
+		lock.JobID = job.ID
+		lock.DeploymentID = deployments[lock.ServerID]
+	})
+	return job, records
+}
+
+// 执行批量部署任务，将 publicDir 部署到各服务器，build 时先构建到默认的public目录
+// 构建结束后释放构建锁，每个服务器部署结束后释放该服务器的锁
+func runDeployJob(jobID string, lock *config.DeployLockHandle, servers []config.ServerConfig, records []*config.DeploymentRecord, publicDir string, incremental, build bool, parallelism int, failFast bool) {
+	defer lock.Release()
+	label := multiServerDeployLabel(incremental, build)
+
+	// 1. 构建一次，所有服务器共用构建结果
+	if build {
+		for _, server := range servers {
+			config.UpdateServerDeploymentStatus(server.ID, config.ServerDeploymentStatus{
+				Status:  "building",
+				Message: "正在构建Hugo站点...",
+			})
+			utils.BroadcastMultiServerBuildProgress(server.ID, server.Name, "开始构建Hugo站点...", 0)
+		}
+
+		output, err := exec.Command("hugo", "--source", config.GetHugoProjectPath()).CombinedOutput()
+		lock.Release(config.BuildLockKey)
+		for _, record := range records {
+			record.BuildOutput = string(output)
+		}
+		config.UpdateDeployJob(jobID, func(job *config.DeployJob) {
+			job.BuildOutput = string(output)
+		})
+
+		if err != nil {
+			message := "Hugo构建失败: " + err.Error()
+			for i, server := range servers {
+				config.UpdateServerDeploymentStatus(server.ID, config.ServerDeploymentStatus{
+					Status:  "failed",
+					Message: message,
+				})
+				config.UpdateDeployJobServer(jobID, server.ID, config.JobServerFailed, message)
+				failDeploymentRecord(records[i], message)
+				utils.BroadcastMultiServerError(server.ID, server.Name, "build", message)
+			}
+			finishDeployJob(jobID, message)
+			return
+		}
+		for _, server := range servers {
+			utils.BroadcastMultiServerBuildProgress(server.ID, server.Name, "Hugo构建完成", 100)
+		}
+	}
+
+	// 2. 按并发数部署到各服务器
+	var (
+		wg     sync.WaitGroup
+		failed atomic.Bool
+	)
+	slots := make(chan struct{}, parallelism)
+	for i, server := range servers {
+		slots <- struct{}{}
+
+		if failFast && failed.Load() {
+			<-slots
+			message := "已跳过：其他服务器部署失败"
+			config.UpdateDeployJobServer(jobID, server.ID, config.JobServerSkipped, message)
+			failDeploymentRecord(records[i], message)
+			lock.Release(config.ServerLockKey(server.ID))
+			continue
+		}
+
+		config.UpdateDeployJobServer(jobID, server.ID, config.JobServerRunning, "")
+		config.UpdateServerDeploymentStatus(server.ID, config.ServerDeploymentStatus{
+			Status:   "deploying",
+			Message:  "正在" + label + "到 " + server.Name,
+			CanPause: true,
+			CanStop:  true,
+		})
+
+		wg.Add(1)
+		go func(server config.ServerConfig, record *config.DeploymentRecord) {
+			defer func() {
+				lock.Release(config.ServerLockKey(server.ID))
+				<-slots
+				wg.Done()
+			}()
+
+			// 暂停的服务器可以继续部署，不算失败，也不会使失败即停止跳过其他服务器
+			switch deployBuiltSiteToServer(server, publicDir, incremental, label, record) {
+			case serverDeploySucceeded:
+				config.UpdateDeployJobServer(jobID, server.ID, config.JobServerSuccess, record.Message)
+			case serverDeployPaused:
+				config.UpdateDeployJobServer(jobID, server.ID, config.JobServerPaused, record.Message)
+			default:
+				failed.Store(true)
+				config.UpdateDeployJobServer(jobID, server.ID, config.JobServerFailed, record.Message)
+			}
+		}(server, records[i])
+	}
+	wg.Wait()
+
+	finishDeployJob(jobID, "")
+}
+
+// 结束批量部署任务，根据各服务器结果汇总状态
+func finishDeployJob(jobID, message string) {
+	job, err := config.GetDeployJob(jobID)
+	if err != nil {
+		return
+	}
+
+	status := "partial"
+	switch {
+	case job.Succeeded == job.Total:
+		status = "success"
+	case job.Paused > 0 && job.Succeeded+job.Paused == job.Total:
+		status = "paused"
+	case job.Succeeded == 0 && job.Paused == 0:
+		status = "failed"
+	}
+	if message == "" {
+		message = fmt.Sprintf("部署完成：成功 %d 个，失败 %d 个，跳过 %d 个", job.Succeeded, job.Failed, job.Skipped)
+		if job.Paused > 0 {
+			message += fmt.Sprintf("，暂停 %d 个", job.Paused)
+		}
+	}
+
+	now := time.Now()
+	config.UpdateDeployJob(jobID, func(job *config.DeployJob) {
+		job.Status = status
+		job.Message = message
+		job.EndTime = &now
+	})
+}
+
+// 获取批量部署任务状态
+func GetDeployJob(c *gin.Context) {
+	job, err := config.GetDeployJob(c.Param("job_id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "部署任务不存在"})
+		return
+	}
+	c.JSON(200, gin.H{"job": job})
+}
+
+// 获取最近的批量部署任务
+func GetDeployJobs(c *gin.Context) {
+	c.JSON(200, gin.H{"jobs": config.ListDeployJobs()})
+}
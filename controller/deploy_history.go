@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// 获取部署触发者：优先使用请求头 X-Triggered-By，否则使用客户端IP
+func deploymentTriggeredBy(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	if by := strings.TrimSpace(c.GetHeader("X-Triggered-By")); by != "" {
+		return by
+	}
+	return c.ClientIP()
+}
+
+// 创建并保存一条进行中的部署记录
+func startDeploymentRecord(c *gin.Context, trigger, serverID, serverName string, incremental, build bool) *config.DeploymentRecord {
+	id := config.GenerateDeploymentID()
+	record := &config.DeploymentRecord{
+		ID:          id,
+		RunID:       id,
+		ServerID:    serverID,
+		ServerName:  serverName,
+		Incremental: incremental,
+		Build:       build,
+		Trigger:     trigger,
+		TriggeredBy: deploymentTriggeredBy(c),
+		Status:      "running",
+		StartTime:   time.Now(),
+	}
+	config.SaveDeploymentRecord(*record)
+	return record
+}
+
+// 以失败状态结束部署记录（如构建失败、配置错误）
+func failDeploymentRecord(record *config.DeploymentRecord, message string) {
+	now := time.Now()
+	record.Status = "failed"
+	record.Message = message
+	record.EndTime = &now
+	config.SaveDeploymentRecord(*record)
+}
+
+// 根据部署结果结束部署记录
+func finishDeploymentRecord(record *config.DeploymentRecord, result *utils.DeployResult, err error) {
+	now := time.Now()
+	record.EndTime = &now
+
+	if result != nil {
+		record.Message = result.Message
+		record.FilesUploaded = len(result.UploadedFiles)
+		record.FilesFailed = len(result.FailedFiles)
+		record.FilesDeleted = result.FilesDeleted
+		record.BytesTransferred = result.BytesTransferred
+		record.Release = result.Release
+		record.Snapshot = result.Snapshot
+		record.UploadedFiles = result.UploadedFiles
+		record.FailedFiles = result.FailedFiles
+		record.DeletedFiles = result.DeletedFiles
+		record.Hooks = result.Hooks
+	}
+
+	switch {
+	case errors.Is(err, utils.ErrDeploymentPaused):
+		record.Status = "paused"
+	case err != nil || result == nil || !result.Success:
+		record.Status = "failed"
+		if err != nil && record.Message == "" {
+			record.Message = err.Error()
+		}
+	default:
+		record.Status = "success"
+	}
+
+	config.SaveDeploymentRecord(*record)
+}
+
+// 解析时间参数，支持 RFC3339 和 2006-01-02
+func parseDeploymentTime(value string, endOfDay bool) (*time.Time, bool) {
+	if value == "" {
+		return nil, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, true
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		return &t, true
+	}
+	return nil, false
+}
+
+// 查询部署历史
+// 支持参数: server_id, status, trigger, since, until（RFC3339 或 YYYY-MM-DD）, limit, offset
+func GetDeployments(c *gin.Context) {
+	since, ok := parseDeploymentTime(c.Query("since"), false)
+	if !ok {
+		c.JSON(400, gin.H{"error": "since 时间格式错误，请使用 RFC3339 或 YYYY-MM-DD"})
+		return
+	}
+	until, ok := parseDeploymentTime(c.Query("until"), true)
+	if !ok {
+		c.JSON(400, gin.H{"error": "until 时间格式错误，请使用 RFC3339 或 YYYY-MM-DD"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	records, total := config.ListDeploymentRecords(config.DeploymentFilter{
+		ServerID: c.Query("server_id"),
+		Status:   c.Query("status"),
+		Trigger:  c.Query("trigger"),
+		Since:    since,
+		Until:    until,
+		Limit:    limit,
+		Offset:   offset,
+	})
+
+	c.JSON(200, gin.H{
+		"deployments": records,
+		"total":       total,
+	})
+}
+
+// 获取部署记录详情（包含文件列表和构建输出）
+func GetDeployment(c *gin.Context) {
+	record, err := config.GetDeploymentRecord(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "部署记录不存在"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"deployment": record,
+	})
+}
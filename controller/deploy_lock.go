@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+)
+
+// 获取服务器的部署锁，build 时同时获取public目录的构建锁
+// 已被持有时返回 409 和持有者信息
+func acquireDeployLock(c *gin.Context, operation string, build bool, serverIDs ...string) (*config.DeployLockHandle, bool) {
+	var keys []string
+	if build {
+		keys = append(keys, config.BuildLockKey)
+	}
+	for _, serverID := range serverIDs {
+		keys = append(keys, config.ServerLockKey(serverID))
+	}
+
+	lock := config.NewDeployLock(operation, deploymentTriggeredBy(c))
+	if err := lock.Acquire(keys...); err != nil {
+		respondDeployError(c, 500, err)
+		return nil, false
+	}
+	return lock, true
+}
+
+// 返回错误响应，锁已被持有时返回 409 和持有者的部署任务信息
+func respondDeployError(c *gin.Context, status int, err error) {
+	locked, ok := config.AsDeployLocked(err)
+	if !ok {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"error":  locked.Error(),
+		"locked": true,
+		"lock":   locked.Lock,
+	}
+	if locked.Lock.JobID != "" {
+		if job, err := config.GetDeployJob(locked.Lock.JobID); err == nil {
+			response["job"] = job
+		}
+	}
+	c.JSON(409, response)
+}
+
+// 获取所有部署锁，stale 表示持有锁的程序已退出
+func GetDeployLocks(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"locks": config.ListDeployLocks(),
+	})
+}
+
+// 强制释放部署锁，锁仍被正在运行的操作持有时需要参数 force=true
+func ReleaseDeployLock(c *gin.Context) {
+	key := c.Param("key")
+	lock, err := config.ForceReleaseDeployLock(key, c.Query("force") == "true")
+	if err != nil {
+		if _, ok := config.AsDeployLocked(err); ok {
+			respondDeployError(c, 409, err)
+			return
+		}
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 强制释放过期的服务器锁时，服务器状态可能仍停留在部署中
+	if lock.ServerID != "" {
+		status := config.GetServerDeploymentStatus(lock.ServerID)
+		if lock.Stale && (status.Status == "deploying" || status.Status == "building") {
+			config.UpdateServerDeploymentStatus(lock.ServerID, config.ServerDeploymentStatus{
+				Status:  "idle",
+				Message: "部署锁已释放",
+			})
+		}
+	}
+
+	c.JSON(200, gin.H{
+		"message": "部署锁已释放",
+		"lock":    lock,
+	})
+}
@@ -0,0 +1,503 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// Hugo environment 名称只允许字母、数字、下划线和连字符
+var hugoEnvironmentPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// 环境请求
+type environmentRequest struct {
+	Name            string `json:"name"`
+	HugoEnvironment string `json:"hugo_environment"`
+	BaseURL         string `json:"base_url"`
+}
+
+// 校验请求并转换为环境配置
+func (request environmentRequest) toEnvironment(environmentID string) (config.Environment, error) {
+	environment := config.Environment{
+		Name:            strings.TrimSpace(request.Name),
+		HugoEnvironment: strings.TrimSpace(request.HugoEnvironment),
+		BaseURL:         strings.TrimSpace(request.BaseURL),
+	}
+	if environment.Name == "" {
+		return environment, errors.New("环境名称不能为空")
+	}
+	for _, existing := range config.GetEnvironments() {
+		if existing.ID != environmentID && existing.Name == environment.Name {
+			return environment, errors.New("环境名称已存在: " + environment.Name)
+		}
+	}
+	if environment.HugoEnvironment != "" && !hugoEnvironmentPattern.MatchString(environment.HugoEnvironment) {
+		return environment, errors.New("Hugo环境名称只能包含字母、数字、下划线和连字符")
+	}
+	if environment.BaseURL != "" {
+		baseURL, err := url.Parse(environment.BaseURL)
+		if err != nil || baseURL.Host == "" || (baseURL.Scheme != "http" && baseURL.Scheme != "https") {
+			return environment, errors.New("baseURL格式错误，需要以 http:// 或 https:// 开头")
+		}
+	}
+	return environment, nil
+}
+
+// 获取所有环境（按提升顺序）
+func GetEnvironments(c *gin.Context) {
+	c.JSON(200, gin.H{"environments": config.GetEnvironments()})
+}
+
+// 添加环境
+func AddEnvironment(c *gin.Context) {
+	var request environmentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误"})
+		return
+	}
+	environment, err := request.toEnvironment("")
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := config.AddEnvironment(environment)
+	environment, _ = config.GetEnvironment(id)
+	c.JSON(200, gin.H{
+		"message":     "环境已添加",
+		"environment": environment,
+	})
+}
+
+// 修改环境
+func UpdateEnvironment(c *gin.Context) {
+	environmentID := c.Param("id")
+	if _, err := config.GetEnvironment(environmentID); err != nil {
+		c.JSON(404, gin.H{"error": "环境不存在"})
+		return
+	}
+
+	var request environmentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误"})
+		return
+	}
+	environment, err := request.toEnvironment(environmentID)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.UpdateEnvironment(environmentID, environment); err != nil {
+		c.JSON(404, gin.H{"error": "环境不存在"})
+		return
+	}
+	environment, _ = config.GetEnvironment(environmentID)
+	c.JSON(200, gin.H{
+		"message":     "环境已更新",
+		"environment": environment,
+	})
+}
+
+// 删除环境及其构件
+func DeleteEnvironment(c *gin.Context) {
+	environmentID := c.Param("id")
+	if _, err := config.GetEnvironment(environmentID); err != nil {
+		c.JSON(404, gin.H{"error": "环境不存在"})
+		return
+	}
+	if err := config.DeleteEnvironment(environmentID); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"message": "环境已删除"})
+}
+
+// 调整环境顺序（构件按此顺序提升）
+func ReorderEnvironments(c *gin.Context) {
+	var request struct {
+		EnvironmentIDs []string `json:"environment_ids"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误"})
+		return
+	}
+	if err := config.ReorderEnvironments(request.EnvironmentIDs); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"message":      "环境顺序已更新",
+		"environments": config.GetEnvironments(),
+	})
+}
+
+// 部署构件的请求参数
+type artifactDeployRequest struct {
+	Incremental bool `json:"incremental"`
+	Parallelism int  `json:"parallelism"`
+	FailFast    bool `json:"fail_fast"`
+}
+
+// 读取可选的部署参数，允许不带请求体
+func bindArtifactDeployRequest(c *gin.Context, request interface{}) bool {
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(request); err != nil {
+			c.JSON(400, gin.H{"error": "请求格式错误"})
+			return false
+		}
+	}
+	return true
+}
+
+// 构建环境的站点并保存为构件，可选地部署到环境中的服务器
+func BuildEnvironment(c *gin.Context) {
+	environment, err := config.GetEnvironment(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "环境不存在"})
+		return
+	}
+	var request struct {
+		artifactDeployRequest
+		Deploy bool `json:"deploy"`
+	}
+	if !bindArtifactDeployRequest(c, &request) {
+		return
+	}
+
+	projectPath := config.GetHugoProjectPath()
+	if _, err := os.Stat(projectPath); os.IsNotExist(err) {
+		c.JSON(400, gin.H{"error": "Hugo项目目录不存在: " + projectPath})
+		return
+	}
+
+	// 部署前先确认服务器可用，避免构建后才发现无法部署
+	var (
+		servers []config.ServerConfig
+		lock    *config.DeployLockHandle
+	)
+	if request.Deploy {
+		var status int
+		if servers, lock, status, err = environmentDeployServers(c, environment, "构建并部署环境 "+environment.Name); err != nil {
+			respondDeployError(c, status, err)
+			return
+		}
+	}
+
+	artifact, output, err := buildEnvironmentArtifact(environment)
+	if err != nil {
+		lock.Release()
+		c.JSON(500, gin.H{
+			"error":  err.Error(),
+			"output": output,
+		})
+		return
+	}
+
+	response := gin.H{
+		"message":  fmt.Sprintf("构建完成：%s，%d 个文件", environment.Name, artifact.Files),
+		"artifact": artifact,
+		"output":   output,
+	}
+	if request.Deploy {
+		job := startArtifactDeployJob(c, lock, config.DeployTriggerManual, environment, artifact, servers, request.artifactDeployRequest)
+		response["message"] = fmt.Sprintf("构建完成，正在部署到 %s 的 %d 个服务器", environment.Name, len(servers))
+		response["job_id"] = job.ID
+	}
+	c.JSON(200, response)
+}
+
+// 使用环境的 Hugo environment 和 baseURL 构建到新的构件目录
+func buildEnvironmentArtifact(environment config.Environment) (config.Artifact, string, error) {
+	artifact := config.Artifact{
+		ID:              config.GenerateArtifactID(),
+		Environment:     environment.ID,
+		HugoEnvironment: environment.HugoEnvironment,
+		BaseURL:         environment.BaseURL,
+		CreatedAt:       time.Now(),
+	}
+	siteDir := config.ArtifactSiteDir(artifact.ID)
+
+	args := []string{"--source", config.GetHugoProjectPath(), "--destination", siteDir}
+	if environment.HugoEnvironment != "" {
+		args = append(args, "--environment", environment.HugoEnvironment)
+	}
+	if environment.BaseURL != "" {
+		args = append(args, "--baseURL", environment.BaseURL)
+	}
+
+	utils.BroadcastBuildProgress("正在构建环境 "+environment.Name+"...", 0)
+	output, err := exec.Command("hugo", args...).CombinedOutput()
+	artifact.BuildOutput = string(output)
+	if err != nil {
+		config.DeleteArtifact(artifact.ID)
+		utils.BroadcastError("build", "Hugo构建失败: "+err.Error())
+		return artifact, artifact.BuildOutput, errors.New("Hugo构建失败: " + err.Error())
+	}
+
+	stats, err := utils.ComputeSiteStats(siteDir)
+	if err != nil {
+		config.DeleteArtifact(artifact.ID)
+		return artifact, artifact.BuildOutput, errors.New("读取构建结果失败: " + err.Error())
+	}
+	artifact.Digest, artifact.Files, artifact.Size = stats.Digest, stats.Files, stats.Size
+
+	if err := config.SaveArtifact(artifact); err != nil {
+		config.DeleteArtifact(artifact.ID)
+		return artifact, artifact.BuildOutput, errors.New("保存构件失败: " + err.Error())
+	}
+	utils.BroadcastComplete("build", "环境 "+environment.Name+" 构建完成", 100)
+	return artifact, artifact.BuildOutput, nil
+}
+
+// 环境中启用的服务器并获取它们的部署锁，没有服务器或有服务器已被锁定时返回错误和状态码
+func environmentDeployServers(c *gin.Context, environment config.Environment, operation string) ([]config.ServerConfig, *config.DeployLockHandle, int, error) {
+	var (
+		servers []config.ServerConfig
+		keys    []string
+	)
+	for _, server := range config.GetEnvironmentServers(environment.ID) {
+		if !server.Enabled {
+			continue
+		}
+		servers = append(servers, server)
+		keys = append(keys, config.ServerLockKey(server.ID))
+	}
+	if len(servers) == 0 {
+		return nil, nil, 400, errors.New("环境 " + environment.Name + " 中没有启用的服务器")
+	}
+
+	lock := config.NewDeployLock(operation, deploymentTriggeredBy(c))
+	if err := lock.Acquire(keys...); err != nil {
+		return nil, nil, 409, err
+	}
+	return servers, lock, 200, nil
+}
+
+// 开始将构件部署到环境中的服务器（异步），全部成功后记为环境当前的构件
+func startArtifactDeployJob(c *gin.Context, lock *config.DeployLockHandle, trigger string, environment config.Environment, artifact config.Artifact, servers []config.ServerConfig, request artifactDeployRequest) *config.DeployJob {
+	if request.Parallelism <= 0 {
+		request.Parallelism = defaultDeployParallelism
+	}
+
+	job, records := createDeployJob(c, lock, trigger, "", servers, request.Incremental, false, request.Parallelism, request.FailFast)
+	config.UpdateDeployJob(job.ID, func(job *config.DeployJob) {
+		job.Environment = environment.ID
+		job.Artifact = artifact.ID
+	})
+	for _, record := range records {
+		record.Artifact = artifact.ID
+		config.SaveDeploymentRecord(*record)
+	}
+
+	go func() {
+		runDeployJob(job.ID, lock, servers, records, config.ArtifactSiteDir(artifact.ID), request.Incremental, false, request.Parallelism, request.FailFast)
+		if snapshot, err := config.GetDeployJob(job.ID); err == nil && snapshot.Status == "success" {
+			config.SetEnvironmentArtifact(environment.ID, artifact.ID)
+		}
+	}()
+	return job
+}
+
+// 属于环境的服务器只能部署该环境的构件，public目录没有使用环境的Hugo环境和baseURL构建
+func environmentBoundError(server config.ServerConfig) error {
+	if server.Environment == "" {
+		return nil
+	}
+	name := server.Environment
+	if environment, err := config.GetEnvironment(server.Environment); err == nil {
+		name = environment.Name
+	}
+	return fmt.Errorf("服务器 %s 属于环境 %s，请通过构建环境或部署构件部署", server.Name, name)
+}
+
+// 继续部署时使用的站点目录：中断的是构件部署时继续使用该构件，构件已被删除时返回错误
+func resumeSiteDir(server config.ServerConfig) (string, string, error) {
+	if artifactID := config.UploadQueueArtifact(server.ID); artifactID != "" {
+		siteDir := config.ArtifactSiteDir(artifactID)
+		if _, err := os.Stat(siteDir); err != nil {
+			return "", "", fmt.Errorf("构件 %s 已被删除，无法继续部署，请停止后重新部署", artifactID)
+		}
+		return siteDir, artifactID, nil
+	}
+	if err := environmentBoundError(server); err != nil {
+		return "", "", err
+	}
+	return config.GetPublicDir(), "", nil
+}
+
+// 获取构件列表，支持参数 environment
+func GetArtifacts(c *gin.Context) {
+	c.JSON(200, gin.H{"artifacts": config.ListArtifacts(c.Query("environment"))})
+}
+
+// 获取构件详情（含构建输出）
+func GetArtifact(c *gin.Context) {
+	artifact, err := config.GetArtifact(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "构件不存在"})
+		return
+	}
+	c.JSON(200, gin.H{"artifact": artifact})
+}
+
+// 删除构件
+func DeleteArtifact(c *gin.Context) {
+	if _, err := config.GetArtifact(c.Param("id")); err != nil {
+		c.JSON(404, gin.H{"error": "构件不存在"})
+		return
+	}
+	if err := config.DeleteArtifact(c.Param("id")); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"message": "构件已删除"})
+}
+
+// 将构件重新部署到所属环境（如回到较早的构件）
+func DeployArtifact(c *gin.Context) {
+	artifact, err := config.GetArtifact(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "构件不存在"})
+		return
+	}
+	var request artifactDeployRequest
+	if !bindArtifactDeployRequest(c, &request) {
+		return
+	}
+	environment, err := config.GetEnvironment(artifact.Environment)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "构件所属的环境不存在"})
+		return
+	}
+
+	servers, lock, status, err := environmentDeployServers(c, environment, "部署构件 "+artifact.ID)
+	if err != nil {
+		respondDeployError(c, status, err)
+		return
+	}
+
+	job := startArtifactDeployJob(c, lock, config.DeployTriggerManual, environment, artifact, servers, request)
+	c.JSON(200, gin.H{
+		"message": fmt.Sprintf("正在将构件 %s 部署到 %s 的 %d 个服务器", artifact.ID, environment.Name, len(servers)),
+		"job_id":  job.ID,
+	})
+}
+
+// 构件的构建参数与目标环境不一致的项
+// 站点中的链接按构建时的 baseURL 生成，原样提升到不同地址的环境会指向原环境
+func artifactMismatches(artifact config.Artifact, environment config.Environment) []gin.H {
+	mismatches := []gin.H{}
+	if artifact.BaseURL != environment.BaseURL {
+		mismatches = append(mismatches, gin.H{"field": "base_url", "artifact": artifact.BaseURL, "environment": environment.BaseURL})
+	}
+	if artifact.HugoEnvironment != environment.HugoEnvironment {
+		mismatches = append(mismatches, gin.H{"field": "hugo_environment", "artifact": artifact.HugoEnvironment, "environment": environment.HugoEnvironment})
+	}
+	return mismatches
+}
+
+// 将构件提升到下一个环境：原样复制构件（不重新构建），然后部署到目标环境的服务器
+// 可以通过参数 environment 指定目标环境；构件的 baseURL 或Hugo环境与目标环境不一致时需要参数 force
+func PromoteArtifact(c *gin.Context) {
+	source, err := config.GetArtifact(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "构件不存在"})
+		return
+	}
+	var request struct {
+		artifactDeployRequest
+		Environment string `json:"environment"`
+		Force       bool   `json:"force"` // 构建参数与目标环境不一致时仍然提升
+	}
+	if !bindArtifactDeployRequest(c, &request) {
+		return
+	}
+
+	var target config.Environment
+	if request.Environment != "" {
+		target, err = config.GetEnvironment(request.Environment)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "目标环境不存在"})
+			return
+		}
+	} else {
+		target, err = config.GetNextEnvironment(source.Environment)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "无法确定目标环境: " + err.Error()})
+			return
+		}
+	}
+	if target.ID == source.Environment {
+		c.JSON(400, gin.H{"error": "目标环境与构件所属环境相同"})
+		return
+	}
+	mismatches := artifactMismatches(source, target)
+	if len(mismatches) > 0 && !request.Force {
+		c.JSON(409, gin.H{
+			"error":      fmt.Sprintf("构件的构建参数与环境 %s 不一致，确认后可以强制提升", target.Name),
+			"mismatches": mismatches,
+		})
+		return
+	}
+
+	servers, lock, status, err := environmentDeployServers(c, target, "提升构件到 "+target.Name)
+	if err != nil {
+		respondDeployError(c, status, err)
+		return
+	}
+
+	artifact, err := copyArtifact(source, target)
+	if err != nil {
+		lock.Release()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	job := startArtifactDeployJob(c, lock, config.DeployTriggerPromote, target, artifact, servers, request.artifactDeployRequest)
+	c.JSON(200, gin.H{
+		"message":    fmt.Sprintf("构件已提升到 %s，正在部署到 %d 个服务器", target.Name, len(servers)),
+		"artifact":   artifact,
+		"job_id":     job.ID,
+		"mismatches": mismatches,
+	})
+}
+
+// 复制构件到目标环境，并确认复制后的内容与原构件一致
+func copyArtifact(source config.Artifact, target config.Environment) (config.Artifact, error) {
+	artifact := config.Artifact{
+		ID:              config.GenerateArtifactID(),
+		Environment:     target.ID,
+		HugoEnvironment: source.HugoEnvironment,
+		BaseURL:         source.BaseURL,
+		PromotedFrom:    source.ID,
+		CreatedAt:       time.Now(),
+	}
+	siteDir := config.ArtifactSiteDir(artifact.ID)
+
+	if err := utils.CopySiteDir(config.ArtifactSiteDir(source.ID), siteDir); err != nil {
+		config.DeleteArtifact(artifact.ID)
+		return artifact, errors.New("复制构件失败: " + err.Error())
+	}
+	stats, err := utils.ComputeSiteStats(siteDir)
+	if err != nil || stats.Digest != source.Digest {
+		config.DeleteArtifact(artifact.ID)
+		return artifact, errors.New("复制后的构件与原构件不一致，原构件可能已被修改")
+	}
+	artifact.Digest, artifact.Files, artifact.Size = stats.Digest, stats.Files, stats.Size
+
+	if err := config.SaveArtifact(artifact); err != nil {
+		config.DeleteArtifact(artifact.ID)
+		return artifact, errors.New("保存构件失败: " + err.Error())
+	}
+	return artifact, nil
+}
@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// 主机密钥信息（返回给前端）
+func hostKeyInfo(hostKey, pendingHostKey string) gin.H {
+	return gin.H{
+		"host_key":            hostKey,
+		"fingerprint":         utils.HostKeyFingerprint(hostKey),
+		"pending_host_key":    pendingHostKey,
+		"pending_fingerprint": utils.HostKeyFingerprint(pendingHostKey),
+	}
+}
+
+// 根据连接结果更新服务器的主机密钥
+// 密钥不匹配时记录为待确认密钥；尚未信任任何密钥时固定本次连接看到的密钥
+func recordServerHostKey(server config.ServerConfig, observedHostKey string, err error) {
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		config.SetServerPendingHostKey(server.ID, mismatch.ActualKey)
+		return
+	}
+	if server.HostKey == "" && observedHostKey != "" {
+		config.SetServerHostKey(server.ID, observedHostKey)
+	}
+}
+
+// 根据连接结果更新单服务器SSH配置的主机密钥
+func recordSSHHostKey(sshConfig config.SSHConfig, observedHostKey string, err error) {
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		config.SetSSHPendingHostKey(mismatch.ActualKey)
+		return
+	}
+	if sshConfig.HostKey == "" && observedHostKey != "" {
+		config.SetSSHHostKey(observedHostKey)
+	}
+}
+
+// 主机密钥不匹配时的响应
+func respondHostKeyMismatch(c *gin.Context, mismatch *utils.HostKeyMismatchError, extra gin.H) {
+	response := gin.H{
+		"error":                mismatch.Error(),
+		"host_key_mismatch":    true,
+		"expected_fingerprint": mismatch.Expected,
+		"actual_fingerprint":   mismatch.Actual,
+	}
+	for k, v := range extra {
+		response[k] = v
+	}
+	c.JSON(409, response)
+}
+
+// 获取服务器主机密钥
+func GetMultiServerHostKey(c *gin.Context) {
+	serverID := c.Param("server_id")
+
+	server, err := config.GetServerConfig(serverID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "服务器不存在"})
+		return
+	}
+
+	c.JSON(200, hostKeyInfo(server.HostKey, server.PendingHostKey))
+}
+
+// 确认并信任服务器的新主机密钥
+func AcceptMultiServerHostKey(c *gin.Context) {
+	serverID := c.Param("server_id")
+	var request struct {
+		Fingerprint string `json:"fingerprint"`
+	}
+	c.ShouldBindJSON(&request)
+
+	server, err := config.GetServerConfig(serverID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "服务器不存在"})
+		return
+	}
+
+	if server.PendingHostKey == "" {
+		c.JSON(400, gin.H{"error": "没有待确认的主机密钥，请先测试连接"})
+		return
+	}
+
+	// 前端传入用户核对过的指纹时，确保确认的就是当前待确认的密钥
+	pendingFingerprint := utils.HostKeyFingerprint(server.PendingHostKey)
+	if request.Fingerprint != "" && request.Fingerprint != pendingFingerprint {
+		c.JSON(409, gin.H{"error": "待确认的主机密钥已变化，请重新测试连接后再确认"})
+		return
+	}
+
+	if err := config.SetServerHostKey(serverID, server.PendingHostKey); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message":     "已信任新的主机密钥",
+		"fingerprint": pendingFingerprint,
+	})
+}
+
+// 从known_hosts文件导入服务器主机密钥
+func ImportMultiServerHostKey(c *gin.Context) {
+	serverID := c.Param("server_id")
+	var request struct {
+		Path string `json:"path"` // 为空时使用 ~/.ssh/known_hosts
+	}
+	c.ShouldBindJSON(&request)
+
+	server, err := config.GetServerConfig(serverID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "服务器不存在"})
+		return
+	}
+
+	hostKey, err := utils.LookupKnownHost(request.Path, server.Host, server.Port)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.SetServerHostKey(serverID, hostKey); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message":     "已从known_hosts导入主机密钥",
+		"fingerprint": utils.HostKeyFingerprint(hostKey),
+	})
+}
+
+// 获取单服务器SSH配置的主机密钥
+func GetSSHHostKey(c *gin.Context) {
+	sshConfig := config.GetSSHConfig()
+	c.JSON(200, hostKeyInfo(sshConfig.HostKey, sshConfig.PendingHostKey))
+}
+
+// 确认并信任单服务器SSH配置的新主机密钥
+func AcceptSSHHostKey(c *gin.Context) {
+	var request struct {
+		Fingerprint string `json:"fingerprint"`
+	}
+	c.ShouldBindJSON(&request)
+
+	sshConfig := config.GetSSHConfig()
+	if sshConfig.PendingHostKey == "" {
+		c.JSON(400, gin.H{"error": "没有待确认的主机密钥，请先测试连接"})
+		return
+	}
+
+	pendingFingerprint := utils.HostKeyFingerprint(sshConfig.PendingHostKey)
+	if request.Fingerprint != "" && request.Fingerprint != pendingFingerprint {
+		c.JSON(409, gin.H{"error": "待确认的主机密钥已变化，请重新测试连接后再确认"})
+		return
+	}
+
+	config.SetSSHHostKey(sshConfig.PendingHostKey)
+
+	c.JSON(200, gin.H{
+		"message":     "已信任新的主机密钥",
+		"fingerprint": pendingFingerprint,
+	})
+}
+
+// 从known_hosts文件导入单服务器SSH配置的主机密钥
+func ImportSSHHostKey(c *gin.Context) {
+	var request struct {
+		Path string `json:"path"`
+	}
+	c.ShouldBindJSON(&request)
+
+	sshConfig := config.GetSSHConfig()
+	if sshConfig.Host == "" {
+		c.JSON(400, gin.H{"error": "SSH配置不完整，请先填写服务器地址"})
+		return
+	}
+
+	hostKey, err := utils.LookupKnownHost(request.Path, sshConfig.Host, sshConfig.Port)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	config.SetSSHHostKey(hostKey)
+
+	c.JSON(200, gin.H{
+		"message":     "已从known_hosts导入主机密钥",
+		"fingerprint": utils.HostKeyFingerprint(hostKey),
+	})
+}
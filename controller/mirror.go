@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// 预览镜像删除结果
+func respondMirrorPreview(c *gin.Context, sshConfig config.SSHConfig) {
+	publicDir := config.GetPublicDir()
+	if _, err := os.Stat(publicDir); os.IsNotExist(err) {
+		c.JSON(400, gin.H{"error": "public目录不存在，请先运行Hugo构建"})
+		return
+	}
+
+	plan, err := utils.PreviewMirrorDeletions(sshConfig, publicDir, sshConfig.RemotePath)
+	respondMirrorPlan(c, plan, err, sshConfig.MirrorDeletions)
+}
+
+// 返回镜像删除预览结果
+func respondMirrorPlan(c *gin.Context, plan *utils.MirrorPlan, err error, mirrorDeletions bool) {
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		respondHostKeyMismatch(c, mismatch, nil)
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "预览镜像删除失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"to_delete":        plan.ToDelete,
+		"protected":        plan.Protected,
+		"excluded":         plan.Excluded,
+		"count":            len(plan.ToDelete),
+		"mirror_deletions": mirrorDeletions,
+	})
+}
+
+// 预览单服务器部署的镜像删除（不做任何修改）
+func PreviewMirrorDeletions(c *gin.Context) {
+	sshConfig := config.GetSSHConfig()
+	if sshConfig.Host == "" || sshConfig.Username == "" || sshConfig.RemotePath == "" {
+		c.JSON(400, gin.H{"error": "SSH配置不完整，请先配置SSH连接信息"})
+		return
+	}
+
+	respondMirrorPreview(c, sshConfig)
+}
+
+// 预览指定服务器的镜像删除（不做任何修改）
+func PreviewMultiServerMirrorDeletions(c *gin.Context) {
+	serverID := c.Param("server_id")
+
+	server, err := config.GetServerConfig(serverID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "服务器不存在"})
+		return
+	}
+
+	publicDir := config.GetPublicDir()
+	if _, err := os.Stat(publicDir); os.IsNotExist(err) {
+		c.JSON(400, gin.H{"error": "public目录不存在，请先运行Hugo构建"})
+		return
+	}
+
+	plan, err := utils.PreviewServerMirrorDeletions(server, publicDir)
+	respondMirrorPlan(c, plan, err, server.MirrorDeletions)
+}
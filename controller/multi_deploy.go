@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// 部署操作名称，用于状态和消息
+func multiServerDeployLabel(incremental, build bool) string {
+	switch {
+	case build && incremental:
+		return "增量构建和部署"
+	case build:
+		return "构建和部署"
+	case incremental:
+		return "增量部署"
+	default:
+		return "部署"
+	}
+}
+
+// 开始部署到指定服务器（异步执行），返回部署记录
+// 不包含构建时立即将状态设置为部署中，服务器属于环境时返回 400 和 nil，服务器或public目录已被锁定时返回 409 和 nil
+func startMultiServerDeployment(c *gin.Context, server config.ServerConfig, incremental, build bool) *config.DeploymentRecord {
+	if err := environmentBoundError(server); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return nil
+	}
+
+	lock, ok := acquireDeployLock(c, multiServerDeployLabel(incremental, build), build, server.ID)
+	if !ok {
+		return nil
+	}
+
+	record := startDeploymentRecord(c, config.DeployTriggerManual, server.ID, server.Name, incremental, build)
+	lock.Update(func(lock *config.DeployLock) {
+		lock.DeploymentID = record.ID
+	})
+
+	if !build {
+		action := "部署"
+		if incremental {
+			action = "增量部署"
+		}
+		config.UpdateServerDeploymentStatus(server.ID, config.ServerDeploymentStatus{
+			Status:   "deploying",
+			Message:  "正在" + action + "到 " + server.Name,
+			Progress: 0,
+			CanPause: true,
+			CanStop:  true,
+		})
+		utils.BroadcastMultiServerDeployProgress(server.ID, server.Name, "开始"+action+"到 "+server.Name, 0, 100, 0, "")
+	}
+
+	go runMultiServerDeployment(server, incremental, build, record, lock)
+	return record
+}
+
+// 执行多服务器部署：可选的Hugo构建，然后上传到服务器，并记录部署历史
+// 构建完成后释放构建锁，部署结束后释放服务器锁
+func runMultiServerDeployment(server config.ServerConfig, incremental, build bool, record *config.DeploymentRecord, lock *config.DeployLockHandle) {
+	defer lock.Release()
+
+	serverID := server.ID
+	label := multiServerDeployLabel(incremental, build)
+	action := "部署"
+	if incremental {
+		action = "增量部署"
+	}
+
+	// 1. 构建阶段
+	if build {
+		config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
+			Status:   "building",
+			Message:  "正在构建Hugo站点...",
+			Progress: 0,
+		})
+
+		buildMessage := "开始构建Hugo站点..."
+		if incremental {
+			buildMessage = "开始增量构建Hugo站点..."
+		}
+		utils.BroadcastMultiServerBuildProgress(serverID, server.Name, buildMessage, 0)
+
+		projectPath := config.GetHugoProjectPath()
+		buildCmd := exec.Command("hugo", "--source", projectPath)
+		output, err := buildCmd.CombinedOutput()
+		record.BuildOutput = string(output)
+		lock.Release(config.BuildLockKey)
+
+		if err != nil {
+			config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
+				Status:  "failed",
+				Message: "Hugo构建失败: " + err.Error(),
+			})
+			utils.BroadcastMultiServerError(serverID, server.Name, "build", "Hugo构建失败: "+err.Error())
+			failDeploymentRecord(record, "Hugo构建失败: "+err.Error())
+			return
+		}
+
+		utils.BroadcastMultiServerBuildProgress(serverID, server.Name, "Hugo构建完成", 100)
+
+		// 2. 部署阶段
+		config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
+			Status:   "deploying",
+			Message:  "正在" + action + "到 " + server.Name,
+			Progress: 50,
+		})
+		utils.BroadcastMultiServerDeployProgress(serverID, server.Name, "开始"+action+"到 "+server.Name, 50, 100, 50, "")
+	}
+
+	deployBuiltSiteToServer(server, config.GetPublicDir(), incremental, label, record)
+}
+
+// 部署到单个服务器的结果
+type serverDeployOutcome int
+
+const (
+	serverDeploySucceeded serverDeployOutcome = iota
+	serverDeployFailed
+	serverDeployPaused // 已暂停，保留上传队列，可以继续部署
+)
+
+// 将已构建的站点目录（public目录或构件）部署到服务器，更新服务器状态和部署记录，返回部署结果
+func deployBuiltSiteToServer(server config.ServerConfig, publicDir string, incremental bool, label string, record *config.DeploymentRecord) serverDeployOutcome {
+	serverID := server.ID
+	action := "部署"
+	if incremental {
+		action = "增量部署"
+	}
+
+	// 检查public目录
+	if _, err := os.Stat(publicDir); os.IsNotExist(err) {
+		config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
+			Status:  "failed",
+			Message: "public目录不存在，请先运行Hugo构建",
+		})
+		utils.BroadcastMultiServerError(serverID, server.Name, "deploy", "public目录不存在，请先运行Hugo构建")
+		failDeploymentRecord(record, "public目录不存在，请先运行Hugo构建")
+		return serverDeployFailed
+	}
+
+	// 按服务器类型选择部署目标并执行部署
+	result, err := utils.ExecuteServerDeployment(server, publicDir, incremental)
+	recordServerHostKey(server, result.HostKey, err)
+	finishDeploymentRecord(record, result, err)
+
+	// 暂停时保留上传队列，之后可以从中断处继续
+	if errors.Is(err, utils.ErrDeploymentPaused) {
+		pending := config.GetServerPendingTasksCount(serverID)
+		if pending == 0 {
+			// 上传任务已被停止操作清除
+			config.SetServerDeploymentPaused(serverID, false)
+			config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
+				Status:  "idle",
+				Message: "部署已停止",
+			})
+			return serverDeployFailed
+		}
+		total := len(config.GetServerUploadTasks(serverID))
+		progress := 0
+		if total > 0 {
+			progress = (total - pending) * 100 / total
+		}
+		message := fmt.Sprintf("部署已暂停，剩余 %d 个文件", pending)
+		config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
+			Status:    "paused",
+			Message:   message,
+			Progress:  progress,
+			CanResume: true,
+			CanStop:   true,
+		})
+		utils.BroadcastMultiServerPause(serverID, server.Name, message, progress, total, total-pending)
+		return serverDeployPaused
+	}
+
+	if err != nil || !result.Success {
+		config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
+			Status:  "failed",
+			Message: action + "失败: " + result.Message,
+		})
+		utils.BroadcastMultiServerError(serverID, server.Name, "deploy", action+"失败: "+result.Message)
+		return serverDeployFailed
+	}
+
+	// 部署后验证，失败时按配置记为失败或回滚
+	var smokeTest *config.SmokeTestResult
+	if server.SmokeTest && server.Domain != "" {
+		var (
+			passed     bool
+			rolledBack string
+		)
+		smokeTest, passed, rolledBack = smokeTestDeployedServer(server, publicDir, result, record)
+		if !passed {
+			message := action + "失败: " + record.Message
+			config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
+				Status:         "failed",
+				Message:        message,
+				CurrentRelease: rolledBack,
+				SmokeTest:      smokeTest,
+			})
+			utils.BroadcastMultiServerError(serverID, server.Name, "deploy", message)
+			return serverDeployFailed
+		}
+	}
+
+	// 更新成功状态
+	message := fmt.Sprintf("%s完成，传输了 %d 个文件", label, result.FilesDeployed)
+	if smokeTest != nil {
+		message += "，" + smokeTest.Message
+	}
+	config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
+		Status:           "success",
+		Message:          message,
+		Progress:         100,
+		FilesDeployed:    result.FilesDeployed,
+		BytesTransferred: result.BytesTransferred,
+		Speed:            result.Speed,
+		CurrentRelease:   result.Release,
+		SmokeTest:        smokeTest,
+	})
+
+	// 广播部署完成消息
+	utils.BroadcastMultiServerComplete(serverID, server.Name, "deploy", message, result.FilesDeployed)
+
+	// 更新服务器的最后部署时间
+	config.SetServerLastDeployment(serverID, time.Now())
+	return serverDeploySucceeded
+}
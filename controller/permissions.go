@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// 修复服务器远程路径下所有文件和目录的权限和属组
+func RepairMultiServerPermissions(c *gin.Context) {
+	serverID := c.Param("server_id")
+
+	server, err := config.GetServerConfig(serverID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "服务器不存在"})
+		return
+	}
+
+	if utils.ServerDeployerType(server) != utils.DeployerTypeSSH {
+		c.JSON(400, gin.H{"error": "修复权限仅支持SSH服务器"})
+		return
+	}
+
+	if server.FileMode == "" && server.DirMode == "" && server.FileGroup == "" {
+		c.JSON(400, gin.H{"error": "请先在服务器配置中设置文件权限、目录权限或属组"})
+		return
+	}
+
+	lock, ok := acquireDeployLock(c, "修复权限", false, serverID)
+	if !ok {
+		return
+	}
+	defer lock.Release()
+
+	sshConfig, err := config.ServerToSSHConfigWithJumps(server)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "修复权限失败: " + err.Error()})
+		return
+	}
+
+	result, err := utils.RepairRemotePermissions(sshConfig)
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		respondHostKeyMismatch(c, mismatch, nil)
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "修复权限失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": fmt.Sprintf("权限修复完成：%d 个文件、%d 个目录修改了权限，%d 项修改了属组",
+			result.FilesFixed, result.DirsFixed, result.GroupFixed),
+		"result": result,
+	})
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// 获取指定服务器的版本列表
+func GetMultiServerReleases(c *gin.Context) {
+	serverID := c.Param("server_id")
+
+	server, err := config.GetServerConfig(serverID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "服务器不存在"})
+		return
+	}
+
+	if utils.ServerDeployerType(server) != utils.DeployerTypeSSH {
+		c.JSON(400, gin.H{"error": "版本目录模式仅支持SSH服务器"})
+		return
+	}
+
+	if !server.ReleaseMode {
+		c.JSON(400, gin.H{"error": "该服务器未启用版本目录模式"})
+		return
+	}
+
+	sshConfig, err := config.ServerToSSHConfigWithJumps(server)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "获取版本列表失败: " + err.Error()})
+		return
+	}
+
+	list, err := utils.ListReleases(sshConfig)
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		respondHostKeyMismatch(c, mismatch, nil)
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "获取版本列表失败: " + err.Error()})
+		return
+	}
+
+	config.SetServerCurrentRelease(serverID, list.Current)
+
+	c.JSON(200, gin.H{
+		"releases": list.Releases,
+		"current":  list.Current,
+	})
+}
+
+// 回滚指定服务器到历史版本
+func RollbackMultiServerRelease(c *gin.Context) {
+	serverID := c.Param("server_id")
+	var request struct {
+		Release string `json:"release"` // 为空时回滚到上一个版本
+	}
+	c.ShouldBindJSON(&request)
+
+	server, err := config.GetServerConfig(serverID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "服务器不存在"})
+		return
+	}
+
+	if utils.ServerDeployerType(server) != utils.DeployerTypeSSH {
+		c.JSON(400, gin.H{"error": "版本目录模式仅支持SSH服务器"})
+		return
+	}
+
+	if !server.ReleaseMode {
+		c.JSON(400, gin.H{"error": "该服务器未启用版本目录模式"})
+		return
+	}
+
+	lock, ok := acquireDeployLock(c, "回滚版本", false, serverID)
+	if !ok {
+		return
+	}
+	defer lock.Release()
+
+	sshConfig, err := config.ServerToSSHConfigWithJumps(server)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "回滚失败: " + err.Error()})
+		return
+	}
+
+	release, err := utils.RollbackRelease(sshConfig, request.Release)
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		respondHostKeyMismatch(c, mismatch, nil)
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "回滚失败: " + err.Error()})
+		return
+	}
+
+	config.UpdateServerDeploymentStatus(serverID, config.ServerDeploymentStatus{
+		Status:         "success",
+		Message:        "已回滚到版本 " + release,
+		Progress:       100,
+		CurrentRelease: release,
+	})
+	utils.BroadcastMultiServerComplete(serverID, server.Name, "deploy", "已回滚到版本 "+release, 0)
+
+	c.JSON(200, gin.H{
+		"message": "已回滚到版本 " + release,
+		"current": release,
+	})
+}
@@ -0,0 +1,546 @@
+package controller
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// 调度器检查定时部署是否到期的间隔
+const scheduleCheckInterval = 20 * time.Second
+
+// 文章到期触发失败后的重试：第一次等待1分钟，之后每次加倍，超过最大次数后不再重试本次到期的文章
+const (
+	scheduleRetryDelay = time.Minute
+	maxScheduleRetries = 5
+)
+
+// 定时部署的执行方式
+const (
+	scheduleRunTriggerSchedule = "schedule" // 到期触发
+	scheduleRunTriggerManual   = "manual"   // 立即执行
+)
+
+var (
+	schedulerOnce         sync.Once
+	runningSchedules      = make(map[string]bool) // 正在执行的定时部署，同一个定时部署不会同时执行两次
+	runningSchedulesMutex sync.Mutex
+)
+
+// 定时发布的文章
+type scheduledPost struct {
+	Path        string    `json:"path"` // 相对 content/ 的路径
+	Title       string    `json:"title"`
+	PublishTime time.Time `json:"publish_time"`
+}
+
+// 启动定时部署调度器
+// 程序未运行期间到期的定时部署会在启动后补执行一次
+func StartScheduler() {
+	schedulerOnce.Do(func() {
+		go func() {
+			for {
+				runDueSchedules(time.Now())
+				time.Sleep(scheduleCheckInterval)
+			}
+		}()
+	})
+}
+
+// 执行所有已到期的定时部署，并更新其余定时部署的下一次执行时间
+func runDueSchedules(now time.Time) {
+	for _, schedule := range config.GetSchedules() {
+		if !schedule.Enabled {
+			continue
+		}
+
+		nextRun, nextPost := schedule.NextRun, schedule.NextPost
+		// cron 按保存的时间触发，文章到期时间随文章修改而变化，每次重新计算
+		if schedule.Kind == config.ScheduleKindNextPost || nextRun == nil {
+			nextRun, nextPost = scheduleNextRun(schedule, now)
+		}
+
+		// 文章到期触发在上一次执行结束后再检查，结束前到期的文章会在下一次执行时发布
+		if nextRun != nil && !nextRun.After(now) && !(schedule.Kind == config.ScheduleKindNextPost && isScheduleRunning(schedule.ID)) {
+			go runSchedule(schedule, scheduleRunTriggerSchedule, config.GenerateDeploymentID())
+			continue
+		}
+		if !sameScheduleTime(nextRun, schedule.NextRun) || nextPost != schedule.NextPost {
+			config.SetScheduleNextRun(schedule.ID, nextRun, nextPost)
+		}
+	}
+}
+
+func sameScheduleTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// 计算定时部署的下一次执行时间
+// 文章到期触发时为上次执行（或创建）之后最早发布的文章的时间，同时返回该文章
+func scheduleNextRun(schedule config.Schedule, now time.Time) (*time.Time, string) {
+	switch schedule.Kind {
+	case config.ScheduleKindCron:
+		cron, err := utils.ParseCron(schedule.Cron)
+		if err != nil {
+			return nil, ""
+		}
+		next := cron.Next(now)
+		if next.IsZero() {
+			return nil, ""
+		}
+		return &next, ""
+	case config.ScheduleKindNextPost:
+		posts := findScheduledPosts(scheduleBaseline(schedule))
+		if len(posts) == 0 {
+			return nil, ""
+		}
+		next := posts[0].PublishTime
+		// 上一次执行失败时等到重试时间再执行
+		if schedule.RetryAt != nil && schedule.RetryAt.After(next) {
+			next = *schedule.RetryAt
+		}
+		return &next, posts[0].Path
+	}
+	return nil, ""
+}
+
+// 文章到期触发的起点：此时间之前发布的文章已经在之前成功的执行中部署
+// 跳过或失败的执行不推进起点，到期的文章会在下一次检查时重新执行
+func scheduleBaseline(schedule config.Schedule) time.Time {
+	if schedule.PublishedUntil != nil {
+		return *schedule.PublishedUntil
+	}
+	// 兼容旧配置：没有记录起点时，成功的上一次执行之前的文章已经部署
+	if schedule.LastRun != nil && schedule.LastStatus == "success" {
+		return *schedule.LastRun
+	}
+	return schedule.CreatedAt
+}
+
+// 定时部署是否正在执行
+func isScheduleRunning(scheduleID string) bool {
+	runningSchedulesMutex.Lock()
+	defer runningSchedulesMutex.Unlock()
+	return runningSchedules[scheduleID]
+}
+
+// 执行定时部署：构建一次，然后部署到指定的服务器，并记录执行历史
+func runSchedule(schedule config.Schedule, trigger, runID string) {
+	now := time.Now()
+	run := config.ScheduleRun{
+		ID:           runID,
+		ScheduleID:   schedule.ID,
+		ScheduleName: schedule.Name,
+		Trigger:      trigger,
+		Status:       "running",
+		StartTime:    now,
+	}
+
+	runningSchedulesMutex.Lock()
+	if runningSchedules[schedule.ID] {
+		runningSchedulesMutex.Unlock()
+		// 跳过本次到期的 cron 执行，等待下一次
+		if trigger == scheduleRunTriggerSchedule && schedule.Kind == config.ScheduleKindCron {
+			nextRun, _ := scheduleNextRun(schedule, now)
+			config.SetScheduleNextRun(schedule.ID, nextRun, "")
+		}
+		finishScheduleRun(&run, "skipped", "上一次执行尚未结束，已跳过")
+		return
+	}
+	runningSchedules[schedule.ID] = true
+	runningSchedulesMutex.Unlock()
+	defer func() {
+		runningSchedulesMutex.Lock()
+		delete(runningSchedules, schedule.ID)
+		runningSchedulesMutex.Unlock()
+	}()
+
+	// 本次发布的文章：上次执行之后到现在到期的文章
+	if schedule.Kind == config.ScheduleKindNextPost {
+		for _, post := range findScheduledPosts(scheduleBaseline(schedule)) {
+			if post.PublishTime.After(now) {
+				break
+			}
+			run.Posts = append(run.Posts, post.Path)
+		}
+	}
+
+	// 记录执行时间并计算下一次执行时间，避免 cron 重复触发
+	// 文章到期触发的起点在执行成功后才推进，执行期间不会重复触发
+	config.SetScheduleLastRun(schedule.ID, now)
+	schedule.LastRun = &now
+	nextRun, nextPost := scheduleNextRun(schedule, now)
+	config.SetScheduleNextRun(schedule.ID, nextRun, nextPost)
+	config.SaveScheduleRun(run)
+
+	build := schedule.Build || schedule.Kind == config.ScheduleKindNextPost
+	lock := config.NewDeployLock("定时部署: "+schedule.Name, "定时部署: "+schedule.Name)
+	if build {
+		if err := lock.Acquire(config.BuildLockKey); err != nil {
+			finishScheduleRun(&run, "skipped", err.Error()+"，已跳过"+settleNextPostRun(schedule, now, false, false))
+			return
+		}
+	}
+
+	// 没有可部署的服务器时重试也不会成功，不再重试本次到期的文章
+	servers, skipped := scheduleServers(schedule, lock)
+	if len(servers) == 0 {
+		lock.Release()
+		message := "没有可部署的服务器"
+		if len(skipped) > 0 {
+			message = "服务器正在部署中，已跳过: " + strings.Join(skipped, ", ")
+		}
+		finishScheduleRun(&run, "skipped", message+settleNextPostRun(schedule, now, false, true))
+		return
+	}
+
+	job, records := createDeployJob(nil, lock, config.DeployTriggerSchedule, "定时部署: "+schedule.Name, servers, schedule.Incremental, build, defaultDeployParallelism, false)
+	run.JobID = job.ID
+	config.SaveScheduleRun(run)
+
+	runDeployJob(job.ID, lock, servers, records, config.GetPublicDir(), schedule.Incremental, build, defaultDeployParallelism, false)
+
+	status, message := "failed", "部署任务不存在"
+	if snapshot, err := config.GetDeployJob(job.ID); err == nil {
+		status, message = snapshot.Status, snapshot.Message
+	}
+	if len(skipped) > 0 {
+		message += "；正在部署中已跳过: " + strings.Join(skipped, ", ")
+	}
+	// 全部服务器部署成功后才推进文章到期触发的起点，否则稍后重试
+	message += settleNextPostRun(schedule, now, status == "success" && len(skipped) == 0, false)
+	finishScheduleRun(&run, status, message)
+}
+
+// 文章到期触发执行结束后推进起点或安排重试，返回附加到执行消息的说明
+// 成功或不需要重试（terminal）时推进起点；失败时按次数延后重试，超过最大重试次数后也推进起点
+func settleNextPostRun(schedule config.Schedule, start time.Time, success, terminal bool) string {
+	if schedule.Kind != config.ScheduleKindNextPost {
+		return ""
+	}
+
+	note := ""
+	if terminal {
+		note = "，本次到期的文章不再重试"
+	} else if !success {
+		retries := schedule.RetryCount + 1
+		if retries <= maxScheduleRetries {
+			retryAt := time.Now().Add(scheduleRetryDelay << (retries - 1))
+			config.SetScheduleRetry(schedule.ID, retries, retryAt)
+			schedule.RetryCount, schedule.RetryAt = retries, &retryAt
+			nextRun, nextPost := scheduleNextRun(schedule, time.Now())
+			config.SetScheduleNextRun(schedule.ID, nextRun, nextPost)
+			return fmt.Sprintf("，将于 %s 第 %d 次重试", retryAt.Format("15:04:05"), retries)
+		}
+		note = fmt.Sprintf("，已重试 %d 次，本次到期的文章不再重试", maxScheduleRetries)
+	}
+
+	config.SetSchedulePublishedUntil(schedule.ID, start)
+	schedule.PublishedUntil = &start
+	schedule.RetryCount, schedule.RetryAt = 0, nil
+	nextRun, nextPost := scheduleNextRun(schedule, time.Now())
+	config.SetScheduleNextRun(schedule.ID, nextRun, nextPost)
+	return note
+}
+
+// 结束并保存执行记录，同时更新定时部署的最近结果
+func finishScheduleRun(run *config.ScheduleRun, status, message string) {
+	now := time.Now()
+	run.Status = status
+	run.Message = message
+	run.EndTime = &now
+	config.SaveScheduleRun(*run)
+	config.SetScheduleLastResult(run.ScheduleID, status, message)
+}
+
+// 定时部署的目标服务器：指定的或所有启用的服务器，并获取它们的部署锁
+// 跳过已禁用、已删除、属于环境（需要部署环境的构件）和已被锁定（正在部署中）的服务器
+func scheduleServers(schedule config.Schedule, lock *config.DeployLockHandle) ([]config.ServerConfig, []string) {
+	selected := make(map[string]bool, len(schedule.ServerIDs))
+	for _, serverID := range schedule.ServerIDs {
+		selected[serverID] = true
+	}
+
+	var servers []config.ServerConfig
+	var busy []string
+	for _, server := range config.GetServerConfigs() {
+		if !server.Enabled || server.Environment != "" || (len(selected) > 0 && !selected[server.ID]) {
+			continue
+		}
+		if err := lock.Acquire(config.ServerLockKey(server.ID)); err != nil {
+			busy = append(busy, server.Name)
+			continue
+		}
+		servers = append(servers, server)
+	}
+	return servers, busy
+}
+
+// 查找发布时间晚于 after 的文章（不含草稿），按发布时间排序
+// 与Hugo一样优先使用 publishDate，没有时使用 date
+func findScheduledPosts(after time.Time) []scheduledPost {
+	contentDir := config.GetContentDir()
+	posts := []scheduledPost{}
+
+	filepath.WalkDir(contentDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".md") {
+			return nil
+		}
+		title, publishTime, isDraft, ok := readPostSchedule(path)
+		if !ok || isDraft || !publishTime.After(after) {
+			return nil
+		}
+		rel, _ := filepath.Rel(contentDir, path)
+		posts = append(posts, scheduledPost{
+			Path:        filepath.ToSlash(rel),
+			Title:       title,
+			PublishTime: publishTime,
+		})
+		return nil
+	})
+
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].PublishTime.Before(posts[j].PublishTime)
+	})
+	return posts
+}
+
+// 从文章的 Front Matter（YAML 或 TOML）中读取标题、发布时间和草稿状态
+func readPostSchedule(path string) (title string, publishTime time.Time, isDraft bool, ok bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	var date, publishDate, delimiter string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if delimiter == "" {
+			if line != "---" && line != "+++" {
+				return
+			}
+			delimiter = line
+			continue
+		}
+		if line == delimiter {
+			break
+		}
+
+		separator := ":"
+		if delimiter == "+++" {
+			separator = "="
+		}
+		key, value, found := strings.Cut(line, separator)
+		if !found {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "title":
+			title = value
+		case "date":
+			date = value
+		case "publishdate":
+			publishDate = value
+		case "draft":
+			isDraft, _ = strconv.ParseBool(value)
+		}
+	}
+
+	if publishDate == "" {
+		publishDate = date
+	}
+	if parsed, err := parseArticleDate(publishDate); err == nil {
+		return title, parsed, isDraft, true
+	}
+	return
+}
+
+// 定时部署请求
+type scheduleRequest struct {
+	Name        string   `json:"name"`
+	Kind        string   `json:"kind"`
+	Cron        string   `json:"cron"`
+	ServerIDs   []string `json:"server_ids"`
+	Build       bool     `json:"build"`
+	Incremental bool     `json:"incremental"`
+	Enabled     bool     `json:"enabled"`
+}
+
+// 校验请求并转换为定时部署配置
+func (request scheduleRequest) toSchedule() (config.Schedule, error) {
+	schedule := config.Schedule{
+		Name:        strings.TrimSpace(request.Name),
+		Kind:        request.Kind,
+		Cron:        strings.TrimSpace(request.Cron),
+		ServerIDs:   request.ServerIDs,
+		Build:       request.Build,
+		Incremental: request.Incremental,
+		Enabled:     request.Enabled,
+	}
+	if schedule.Name == "" {
+		return schedule, fmt.Errorf("名称不能为空")
+	}
+
+	switch schedule.Kind {
+	case config.ScheduleKindCron:
+		cron, err := utils.ParseCron(schedule.Cron)
+		if err != nil {
+			return schedule, err
+		}
+		if cron.Next(time.Now()).IsZero() {
+			return schedule, fmt.Errorf("cron表达式永远不会触发: %s", schedule.Cron)
+		}
+	case config.ScheduleKindNextPost:
+		// 需要构建才能发布到期的文章
+		schedule.Cron = ""
+		schedule.Build = true
+	default:
+		return schedule, fmt.Errorf("不支持的触发方式: %s", schedule.Kind)
+	}
+
+	for _, serverID := range schedule.ServerIDs {
+		server, err := config.GetServerConfig(serverID)
+		if err != nil {
+			return schedule, fmt.Errorf("服务器不存在: %s", serverID)
+		}
+		if err := environmentBoundError(server); err != nil {
+			return schedule, err
+		}
+	}
+	return schedule, nil
+}
+
+// 获取所有定时部署
+func GetSchedules(c *gin.Context) {
+	c.JSON(200, gin.H{"schedules": config.GetSchedules()})
+}
+
+// 获取定时部署
+func GetSchedule(c *gin.Context) {
+	schedule, err := config.GetSchedule(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "定时部署不存在"})
+		return
+	}
+	c.JSON(200, gin.H{"schedule": schedule})
+}
+
+// 添加定时部署
+func AddSchedule(c *gin.Context) {
+	var request scheduleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误"})
+		return
+	}
+	schedule, err := request.toSchedule()
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := config.AddSchedule(schedule)
+	schedule, _ = config.GetSchedule(id)
+	nextRun, nextPost := scheduleNextRun(schedule, time.Now())
+	config.SetScheduleNextRun(id, nextRun, nextPost)
+	schedule, _ = config.GetSchedule(id)
+
+	c.JSON(200, gin.H{
+		"message":  "定时部署已添加",
+		"schedule": schedule,
+	})
+}
+
+// 修改定时部署
+func UpdateSchedule(c *gin.Context) {
+	scheduleID := c.Param("id")
+	existing, err := config.GetSchedule(scheduleID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "定时部署不存在"})
+		return
+	}
+
+	var request scheduleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误"})
+		return
+	}
+	schedule, err := request.toSchedule()
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 修改后重新计算下一次执行时间
+	schedule.CreatedAt = existing.CreatedAt
+	schedule.LastRun = existing.LastRun
+	schedule.PublishedUntil = existing.PublishedUntil
+	schedule.NextRun, schedule.NextPost = scheduleNextRun(schedule, time.Now())
+	if err := config.UpdateSchedule(scheduleID, schedule); err != nil {
+		c.JSON(404, gin.H{"error": "定时部署不存在"})
+		return
+	}
+	schedule, _ = config.GetSchedule(scheduleID)
+
+	c.JSON(200, gin.H{
+		"message":  "定时部署已更新",
+		"schedule": schedule,
+	})
+}
+
+// 删除定时部署，执行记录保留
+func DeleteSchedule(c *gin.Context) {
+	if err := config.DeleteSchedule(c.Param("id")); err != nil {
+		c.JSON(404, gin.H{"error": "定时部署不存在"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "定时部署已删除"})
+}
+
+// 立即执行定时部署（异步），返回执行记录ID
+func RunScheduleNow(c *gin.Context) {
+	schedule, err := config.GetSchedule(c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "定时部署不存在"})
+		return
+	}
+
+	runID := config.GenerateDeploymentID()
+	go runSchedule(schedule, scheduleRunTriggerManual, runID)
+
+	c.JSON(200, gin.H{
+		"message": "开始执行定时部署: " + schedule.Name,
+		"run_id":  runID,
+	})
+}
+
+// 查询定时部署的执行记录，支持参数 schedule_id 和 limit
+func GetScheduleRuns(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	c.JSON(200, gin.H{
+		"runs": config.ListScheduleRuns(c.Query("schedule_id"), limit),
+	})
+}
+
+// 列出尚未发布的定时文章（发布时间晚于当前时间）
+func GetScheduledPosts(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"posts": findScheduledPosts(time.Now()),
+	})
+}
@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// 批量测试连接时同时测试的服务器数
+const bulkTestParallelism = 5
+
+// 批量操作中单个服务器的结果
+type serverOperationResult struct {
+	ServerID        string `json:"server_id"`
+	ServerName      string `json:"server_name"`
+	Success         bool   `json:"success"`
+	Message         string `json:"message"`
+	HostKeyMismatch bool   `json:"host_key_mismatch,omitempty"`
+}
+
+// 分组的状态汇总
+type serverGroupSummary struct {
+	Group    string         `json:"group"` // 为空表示未分组
+	Total    int            `json:"total"`
+	Enabled  int            `json:"enabled"`
+	Status   string         `json:"status"`   // 汇总状态: deploying, failed, paused, success, idle
+	Statuses map[string]int `json:"statuses"` // 各部署状态的服务器数
+}
+
+// 读取服务器选择条件（查询参数或请求体中的 group、tag），失败时已返回错误响应
+func bindServerSelector(c *gin.Context) (config.ServerSelector, bool) {
+	var selector config.ServerSelector
+	c.ShouldBindQuery(&selector)
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&selector); err != nil {
+			c.JSON(400, gin.H{"error": "请求格式错误"})
+			return selector, false
+		}
+	}
+	return selector, true
+}
+
+// 选择满足条件的服务器，enabledOnly 时跳过已禁用的服务器，没有服务器时已返回错误响应
+func selectBulkServers(c *gin.Context, enabledOnly bool) (config.ServerSelector, []config.ServerConfig, bool) {
+	selector, ok := bindServerSelector(c)
+	if !ok {
+		return selector, nil, false
+	}
+
+	var servers []config.ServerConfig
+	for _, server := range config.SelectServers(selector) {
+		if enabledOnly && !server.Enabled {
+			continue
+		}
+		servers = append(servers, server)
+	}
+	if len(servers) == 0 {
+		c.JSON(400, gin.H{"error": "没有匹配的服务器: " + selector.String()})
+		return selector, nil, false
+	}
+	return selector, servers, true
+}
+
+// 按分组汇总服务器状态，未分组的服务器排在最后
+func aggregateServerStatuses(servers []config.ServerConfig, statuses map[string]config.ServerDeploymentStatus) []serverGroupSummary {
+	summaries := make(map[string]*serverGroupSummary)
+	for _, server := range servers {
+		summary, exists := summaries[server.Group]
+		if !exists {
+			summary = &serverGroupSummary{Group: server.Group, Statuses: make(map[string]int)}
+			summaries[server.Group] = summary
+		}
+
+		status := statuses[server.ID].Status
+		if status == "" {
+			status = "idle"
+		}
+		summary.Total++
+		if server.Enabled {
+			summary.Enabled++
+		}
+		summary.Statuses[status]++
+	}
+
+	result := make([]serverGroupSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		switch {
+		case summary.Statuses["building"]+summary.Statuses["deploying"] > 0:
+			summary.Status = "deploying"
+		case summary.Statuses["failed"] > 0:
+			summary.Status = "failed"
+		case summary.Statuses["paused"] > 0:
+			summary.Status = "paused"
+		case summary.Statuses["success"] == summary.Total:
+			summary.Status = "success"
+		default:
+			summary.Status = "idle"
+		}
+		result = append(result, *summary)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if (result[i].Group == "") != (result[j].Group == "") {
+			return result[j].Group == ""
+		}
+		return result[i].Group < result[j].Group
+	})
+	return result
+}
+
+// 统计成功的服务器数
+func countSucceeded(results []serverOperationResult) int {
+	succeeded := 0
+	for _, result := range results {
+		if result.Success {
+			succeeded++
+		}
+	}
+	return succeeded
+}
+
+// 批量测试连接：按分组或标签选择启用的服务器，同时测试
+func TestServersBySelector(c *gin.Context) {
+	selector, servers, ok := selectBulkServers(c, true)
+	if !ok {
+		return
+	}
+
+	results := make([]serverOperationResult, len(servers))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, bulkTestParallelism)
+	for i, server := range servers {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, server config.ServerConfig) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			message, err := testMultiServer(server)
+			_, mismatch := utils.AsHostKeyMismatch(err)
+			results[i] = serverOperationResult{
+				ServerID:        server.ID,
+				ServerName:      server.Name,
+				Success:         err == nil,
+				Message:         message,
+				HostKeyMismatch: mismatch,
+			}
+		}(i, server)
+	}
+	wg.Wait()
+
+	succeeded := countSucceeded(results)
+	c.JSON(200, gin.H{
+		"message": fmt.Sprintf("%s：%d 个服务器连接成功，%d 个失败", selector.String(), succeeded, len(results)-succeeded),
+		"results": results,
+	})
+}
+
+// 批量暂停：暂停满足条件的服务器中正在进行的部署
+func PauseServersBySelector(c *gin.Context) {
+	selector, servers, ok := selectBulkServers(c, false)
+	if !ok {
+		return
+	}
+
+	results := []serverOperationResult{}
+	for _, server := range servers {
+		if config.GetServerDeploymentStatus(server.ID).Status != "deploying" {
+			continue
+		}
+		result := serverOperationResult{ServerID: server.ID, ServerName: server.Name, Success: true, Message: "正在暂停部署"}
+		if err := pauseServerDeployment(server); err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		}
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		c.JSON(400, gin.H{"error": selector.String() + " 没有正在进行的部署"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": fmt.Sprintf("正在暂停 %d 个服务器的部署", countSucceeded(results)),
+		"results": results,
+	})
+}
+
+// 批量停止：停止满足条件的服务器中正在进行或已暂停的部署
+func StopServersBySelector(c *gin.Context) {
+	selector, servers, ok := selectBulkServers(c, false)
+	if !ok {
+		return
+	}
+
+	results := []serverOperationResult{}
+	for _, server := range servers {
+		switch config.GetServerDeploymentStatus(server.ID).Status {
+		case "building", "deploying", "paused":
+		default:
+			if config.GetServerPendingTasksCount(server.ID) == 0 {
+				continue
+			}
+		}
+		stopServerDeployment(server)
+		results = append(results, serverOperationResult{ServerID: server.ID, ServerName: server.Name, Success: true, Message: "部署已停止"})
+	}
+	if len(results) == 0 {
+		c.JSON(400, gin.H{"error": selector.String() + " 没有正在进行的部署"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": fmt.Sprintf("已停止 %d 个服务器的部署", len(results)),
+		"results": results,
+	})
+}
@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// 导出服务器配置，凭据用导出时设置的口令加密，不使用本机的主密码
+// 指定 server_ids 时导出这些服务器，否则按分组、标签选择；引用的跳板机会一并导出
+func ExportMultiServerConfigs(c *gin.Context) {
+	var request struct {
+		ServerIDs  []string `json:"server_ids"`
+		Group      string   `json:"group"`
+		Tag        string   `json:"tag"`
+		Passphrase string   `json:"passphrase"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误"})
+		return
+	}
+
+	var servers []config.ServerConfig
+	if len(request.ServerIDs) > 0 {
+		for _, serverID := range request.ServerIDs {
+			server, err := config.GetServerConfig(serverID)
+			if err != nil {
+				c.JSON(404, gin.H{"error": "服务器不存在: " + serverID})
+				return
+			}
+			servers = append(servers, server)
+		}
+	} else {
+		servers = config.SelectServers(config.ServerSelector{Group: request.Group, Tag: request.Tag})
+	}
+	if len(servers) == 0 {
+		c.JSON(400, gin.H{"error": "没有要导出的服务器"})
+		return
+	}
+
+	// 跳板机未被选择时一并导出，导入后跳板机配置仍然有效
+	selected := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		selected[server.ID] = true
+	}
+	for _, server := range servers {
+		chain, err := config.ResolveJumpChain(server)
+		if err != nil {
+			continue
+		}
+		for _, jump := range chain {
+			if !selected[jump.ID] {
+				selected[jump.ID] = true
+				servers = append(servers, jump)
+			}
+		}
+	}
+
+	export, err := config.ExportServerConfigs(servers, request.Passphrase)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := "hugo-manager-servers-" + time.Now().Format("20060102") + ".json"
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.JSON(200, export)
+}
+
+// 判断重复服务器的键：部署到同一地址和路径的服务器视为重复
+func serverDuplicateKey(server config.ServerConfig) string {
+	remotePath := strings.TrimSpace(server.RemotePath)
+	if remotePath != "" {
+		remotePath = path.Clean(strings.ReplaceAll(remotePath, "\\", "/"))
+	}
+
+	deployerType := utils.ServerDeployerType(server)
+	switch deployerType {
+	case utils.DeployerTypeLocal:
+		return deployerType + "|" + remotePath
+	case utils.DeployerTypeS3:
+		endpoint := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(server.S3Endpoint), "https://"), "http://")
+		return deployerType + "|" + strings.ToLower(strings.TrimSuffix(endpoint, "/")) + "/" + server.S3Bucket + "|" + strings.Trim(remotePath, "/.")
+	case utils.DeployerTypeGit:
+		return deployerType + "|" + strings.TrimSpace(server.GitRemote) + "|" + strings.TrimSpace(server.GitBranch)
+	default:
+		return deployerType + "|" + strings.ToLower(strings.TrimSpace(server.Host)) + "|" + remotePath
+	}
+}
+
+// 导入服务器配置：用导出口令解密凭据，再用本机的主密码加密保存
+// 部署到同一地址和路径的服务器视为重复，按 duplicates 跳过（skip，默认）或更新（update）
+func ImportMultiServerConfigs(c *gin.Context) {
+	var request struct {
+		Export         config.ServerExport `json:"export"`
+		Passphrase     string              `json:"passphrase"`      // 导出时设置的口令
+		MasterPassword string              `json:"master_password"` // 本机的主密码，导入的服务器带有凭据时必填
+		Duplicates     string              `json:"duplicates"`      // 重复服务器的处理: skip(默认), update
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误"})
+		return
+	}
+	if request.Duplicates == "" {
+		request.Duplicates = "skip"
+	}
+	if request.Duplicates != "skip" && request.Duplicates != "update" {
+		c.JSON(400, gin.H{"error": "不支持的重复处理方式: " + request.Duplicates})
+		return
+	}
+
+	servers, err := config.DecryptServerExport(request.Export, request.Passphrase)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 凭据用本机的主密码加密，主密码必须与已加密的凭据一致
+	for _, server := range servers {
+		if !server.HasSecretCredentials() {
+			continue
+		}
+		if request.MasterPassword == "" {
+			c.JSON(400, gin.H{"error": "导入的服务器包含凭据，请输入主密码用于加密保存"})
+			return
+		}
+		if err := config.VerifyMasterPassword(request.MasterPassword); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		break
+	}
+
+	existing := make(map[string]config.ServerConfig)
+	for _, server := range config.GetServerConfigs() {
+		existing[serverDuplicateKey(server)] = server
+	}
+
+	// 导出文件中的服务器ID对应的本机服务器ID，用于恢复跳板机配置
+	serverIDs := make(map[string]string)
+	jumpServerIDs := make(map[string]string)
+	seen := make(map[string]bool)
+	imported, updated := []string{}, []string{}
+	skipped, warnings := []gin.H{}, []string{}
+
+	for _, server := range servers {
+		key := serverDuplicateKey(server)
+		if seen[key] {
+			skipped = append(skipped, gin.H{"name": server.Name, "reason": "与导入文件中的其他服务器重复"})
+			continue
+		}
+		seen[key] = true
+
+		exportedID := server.ID
+		duplicate, isDuplicate := existing[key]
+		if isDuplicate && request.Duplicates == "skip" {
+			serverIDs[exportedID] = duplicate.ID
+			skipped = append(skipped, gin.H{"name": server.Name, "reason": "与服务器 " + duplicate.Name + " 重复"})
+			continue
+		}
+
+		config.NormalizeServerLabels(&server)
+		if server.Environment != "" {
+			if _, err := config.GetEnvironment(server.Environment); err != nil {
+				warnings = append(warnings, fmt.Sprintf("服务器 %s 的部署环境不存在，已清除", server.Name))
+				server.Environment = ""
+			}
+		}
+		// 跳板机在所有服务器保存后再设置
+		jumpServerID := server.JumpServerID
+		server.JumpServerID = ""
+		server.PendingHostKey = ""
+		server.LastDeployment = nil
+
+		if err := validateServerConfig(server); err != nil {
+			skipped = append(skipped, gin.H{"name": server.Name, "reason": err.Error()})
+			continue
+		}
+
+		targetID := ""
+		if isDuplicate {
+			targetID = duplicate.ID
+			server.LastDeployment = duplicate.LastDeployment
+			if server.HostKey == "" {
+				server.HostKey = duplicate.HostKey
+			}
+			config.KeepEncryptedCredentials(&server, duplicate)
+		} else {
+			server.ID = config.GenerateServerID()
+		}
+		// 密码、私钥密码和S3密钥与本机添加的服务器一样用主密码加密保存
+		if err := config.SaveServerConfig(targetID, server, request.MasterPassword); err != nil {
+			skipped = append(skipped, gin.H{"name": server.Name, "reason": "保存失败: " + err.Error()})
+			continue
+		}
+
+		if isDuplicate {
+			serverIDs[exportedID] = duplicate.ID
+			updated = append(updated, server.Name)
+		} else {
+			serverIDs[exportedID] = server.ID
+			imported = append(imported, server.Name)
+		}
+		if jumpServerID != "" {
+			jumpServerIDs[serverIDs[exportedID]] = jumpServerID
+		}
+	}
+
+	for serverID, exportedJumpID := range jumpServerIDs {
+		server, _ := config.GetServerConfig(serverID)
+		jumpServerID, ok := serverIDs[exportedJumpID]
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("服务器 %s 的跳板机未包含在导入文件中，已清除", server.Name))
+			continue
+		}
+		server.JumpServerID = jumpServerID
+		if _, err := config.ResolveJumpChain(server); err != nil {
+			warnings = append(warnings, fmt.Sprintf("服务器 %s 的跳板机配置无效（%v），已清除", server.Name, err))
+			continue
+		}
+		config.SetServerJumpServer(serverID, jumpServerID)
+	}
+
+	c.JSON(200, gin.H{
+		"message":  fmt.Sprintf("已导入 %d 个服务器，更新 %d 个，跳过 %d 个", len(imported), len(updated), len(skipped)),
+		"imported": imported,
+		"updated":  updated,
+		"skipped":  skipped,
+		"warnings": warnings,
+	})
+}
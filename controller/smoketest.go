@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// 自动回滚只支持版本目录模式的SSH服务器
+var errSmokeTestRollbackUnsupported = errors.New("只有启用版本目录模式的SSH服务器支持自动回滚")
+
+// 部署成功后进行验证，并按服务器配置的处理方式决定部署是否记为失败
+// 返回验证结果、部署是否仍然成功，以及自动回滚后生效的版本
+func smokeTestDeployedServer(server config.ServerConfig, publicDir string, result *utils.DeployResult, record *config.DeploymentRecord) (*config.SmokeTestResult, bool, string) {
+	utils.BroadcastMultiServerDeployProgress(server.ID, server.Name, "正在进行部署后验证: "+server.Domain, 100, 0, 0, "")
+
+	smokeTest := utils.RunSmokeTest(server, publicDir, result.UploadedFiles)
+	record.SmokeTest = smokeTest
+	passed := smokeTest.Passed
+	rolledBack := ""
+
+	if !smokeTest.Passed {
+		switch server.SmokeTestAction {
+		case config.SmokeTestActionFail:
+			smokeTest.Action = "已将部署记为失败"
+			passed = false
+		case config.SmokeTestActionRollback:
+			release, err := rollbackServerRelease(server)
+			if err != nil {
+				smokeTest.Action = "自动回滚失败: " + err.Error()
+			} else {
+				smokeTest.Action = "已回滚到版本 " + release
+				rolledBack = release
+			}
+			passed = false
+		}
+	}
+
+	if !passed {
+		record.Status = "failed"
+		record.Message = smokeTest.Message + "，" + smokeTest.Action
+	}
+	config.SaveDeploymentRecord(*record)
+	return smokeTest, passed, rolledBack
+}
+
+// 回滚服务器到上一个版本（仅版本目录模式的SSH服务器）
+func rollbackServerRelease(server config.ServerConfig) (string, error) {
+	if utils.ServerDeployerType(server) != utils.DeployerTypeSSH || !server.ReleaseMode {
+		return "", errSmokeTestRollbackUnsupported
+	}
+	sshConfig, err := config.ServerToSSHConfigWithJumps(server)
+	if err != nil {
+		return "", err
+	}
+	return utils.RollbackRelease(sshConfig, "")
+}
+
+// 手动对服务器进行部署后验证（从所有本地页面中抽查）
+func SmokeTestMultiServer(c *gin.Context) {
+	serverID := c.Param("server_id")
+
+	server, err := config.GetServerConfig(serverID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "服务器不存在"})
+		return
+	}
+	if server.Domain == "" {
+		c.JSON(400, gin.H{"error": "请先填写服务器的网站域名"})
+		return
+	}
+
+	smokeTest := utils.RunSmokeTest(server, config.GetPublicDir(), nil)
+
+	// 保留当前部署状态，只更新验证结果
+	status := config.GetServerDeploymentStatus(serverID)
+	status.SmokeTest = smokeTest
+	config.UpdateServerDeploymentStatus(serverID, status)
+
+	c.JSON(200, gin.H{
+		"message":    smokeTest.Message,
+		"smoke_test": smokeTest,
+	})
+}
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// 获取支持快照的服务器及其SSH配置，失败时已返回错误响应
+func snapshotServer(c *gin.Context) (config.ServerConfig, config.SSHConfig, bool) {
+	server, err := config.GetServerConfig(c.Param("server_id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "服务器不存在"})
+		return server, config.SSHConfig{}, false
+	}
+
+	if utils.ServerDeployerType(server) != utils.DeployerTypeSSH {
+		c.JSON(400, gin.H{"error": "快照仅支持SSH服务器"})
+		return server, config.SSHConfig{}, false
+	}
+
+	sshConfig, err := config.ServerToSSHConfigWithJumps(server)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "连接服务器失败: " + err.Error()})
+		return server, config.SSHConfig{}, false
+	}
+	return server, sshConfig, true
+}
+
+// 获取服务器的快照，失败时已返回错误响应
+func serverSnapshot(c *gin.Context, serverID string) (config.Snapshot, bool) {
+	snapshot, err := config.GetSnapshot(serverID, c.Param("snapshot_id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "快照不存在"})
+		return snapshot, false
+	}
+	return snapshot, true
+}
+
+// 获取指定服务器的快照列表
+func GetServerSnapshots(c *gin.Context) {
+	serverID := c.Param("server_id")
+	if _, err := config.GetServerConfig(serverID); err != nil {
+		c.JSON(404, gin.H{"error": "服务器不存在"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"snapshots": config.ListSnapshots(serverID),
+	})
+}
+
+// 立即为指定服务器创建快照
+func CreateServerSnapshot(c *gin.Context) {
+	server, sshConfig, ok := snapshotServer(c)
+	if !ok {
+		return
+	}
+
+	if server.ReleaseMode {
+		c.JSON(400, gin.H{"error": "版本目录模式已保留历史版本，不需要快照"})
+		return
+	}
+
+	lock, ok := acquireDeployLock(c, "创建快照", false, server.ID)
+	if !ok {
+		return
+	}
+	defer lock.Release()
+
+	snapshot, err := utils.CreateServerSnapshot(sshConfig, server.ID)
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		respondHostKeyMismatch(c, mismatch, nil)
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "创建快照失败: " + err.Error()})
+		return
+	}
+	if snapshot == nil {
+		c.JSON(400, gin.H{"error": "远程路径不存在或为空，没有需要快照的内容"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message":  "快照已创建",
+		"snapshot": snapshot,
+	})
+}
+
+// 将快照恢复到服务器
+func RestoreServerSnapshot(c *gin.Context) {
+	server, sshConfig, ok := snapshotServer(c)
+	if !ok {
+		return
+	}
+	snapshot, ok := serverSnapshot(c, server.ID)
+	if !ok {
+		return
+	}
+
+	lock, ok := acquireDeployLock(c, "恢复快照 "+snapshot.ID, false, server.ID)
+	if !ok {
+		return
+	}
+	defer lock.Release()
+
+	err := utils.RestoreSnapshot(sshConfig, snapshot)
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		respondHostKeyMismatch(c, mismatch, nil)
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "已恢复快照 " + snapshot.ID
+	config.UpdateServerDeploymentStatus(server.ID, config.ServerDeploymentStatus{
+		Status:   "success",
+		Message:  message,
+		Progress: 100,
+	})
+	utils.BroadcastMultiServerComplete(server.ID, server.Name, "deploy", message, 0)
+
+	c.JSON(200, gin.H{
+		"message": message,
+	})
+}
+
+// 下载快照（tar.gz）
+func DownloadServerSnapshot(c *gin.Context) {
+	server, sshConfig, ok := snapshotServer(c)
+	if !ok {
+		return
+	}
+	snapshot, ok := serverSnapshot(c, server.ID)
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.tar.gz", server.ID, snapshot.ID)
+	if snapshot.Location != config.SnapshotModeRemote {
+		archive := config.SnapshotArchivePath(server.ID, snapshot.ID)
+		if _, err := os.Stat(archive); err != nil {
+			c.JSON(404, gin.H{"error": "快照文件不存在"})
+			return
+		}
+		c.FileAttachment(archive, filename)
+		return
+	}
+
+	// 服务器上的快照直接转发，开始写入后无法再返回错误响应
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if snapshot.Size > 0 {
+		c.Header("Content-Length", fmt.Sprintf("%d", snapshot.Size))
+	}
+	if err := utils.StreamRemoteSnapshot(sshConfig, snapshot, c.Writer); err != nil {
+		fmt.Printf("下载快照失败: %v\n", err)
+		if !c.Writer.Written() {
+			c.Header("Content-Disposition", "")
+			c.Header("Content-Length", "")
+			c.JSON(500, gin.H{"error": "下载快照失败: " + err.Error()})
+		}
+	}
+}
+
+// 删除快照
+func DeleteServerSnapshot(c *gin.Context) {
+	server, sshConfig, ok := snapshotServer(c)
+	if !ok {
+		return
+	}
+	snapshot, ok := serverSnapshot(c, server.ID)
+	if !ok {
+		return
+	}
+
+	err := utils.DeleteSnapshot(sshConfig, snapshot)
+	if mismatch, ok := utils.AsHostKeyMismatch(err); ok {
+		respondHostKeyMismatch(c, mismatch, nil)
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "删除快照失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": "快照已删除",
+	})
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"hugo-manager-go/config"
+	"hugo-manager-go/utils"
+)
+
+// 列出SSH客户端配置文件（默认 ~/.ssh/config）中可以导入的主机
+func GetSSHConfigHosts(c *gin.Context) {
+	hosts, err := utils.ParseSSHConfigFile(c.Query("path"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 标记已存在同名服务器的主机
+	existing := make(map[string]bool)
+	for _, server := range config.GetServerConfigs() {
+		existing[server.Name] = true
+	}
+	result := make([]gin.H, 0, len(hosts))
+	for _, host := range hosts {
+		result = append(result, gin.H{
+			"alias":         host.Alias,
+			"host_name":     host.HostName,
+			"port":          host.Port,
+			"user":          host.User,
+			"identity_file": host.IdentityFile,
+			"proxy_jump":    host.ProxyJump,
+			"exists":        existing[host.Alias],
+		})
+	}
+
+	c.JSON(200, gin.H{
+		"path":  c.Query("path"),
+		"hosts": result,
+	})
+}
+
+// 将SSH客户端配置文件中的主机导入为服务器配置
+// 以 Host 别名作为服务器名称，已存在同名服务器的主机会被跳过
+func ImportSSHConfigHosts(c *gin.Context) {
+	var request struct {
+		Path       string   `json:"path"`        // 为空时使用 ~/.ssh/config
+		Hosts      []string `json:"hosts"`       // 要导入的 Host 别名，为空时导入全部
+		RemotePath string   `json:"remote_path"` // 导入后服务器的远程部署路径
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误"})
+		return
+	}
+
+	hosts, err := utils.ParseSSHConfigFile(request.Path)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	selected := make(map[string]bool, len(request.Hosts))
+	for _, alias := range request.Hosts {
+		selected[alias] = true
+	}
+	existing := make(map[string]bool)
+	for _, server := range config.GetServerConfigs() {
+		existing[server.Name] = true
+	}
+	hostsByAlias := make(map[string]utils.SSHConfigHost, len(hosts))
+	for _, host := range hosts {
+		hostsByAlias[host.Alias] = host
+	}
+
+	imported, skipped := []string{}, []string{}
+	for _, host := range hosts {
+		if len(selected) > 0 && !selected[host.Alias] {
+			continue
+		}
+		if existing[host.Alias] || host.User == "" {
+			skipped = append(skipped, host.Alias)
+			continue
+		}
+
+		server := config.ServerConfig{
+			Name:       host.Alias,
+			Host:       host.HostName,
+			Port:       host.Port,
+			Username:   host.User,
+			KeyPath:    host.IdentityFile,
+			UseAgent:   host.IdentityFile == "", // 没有指定私钥时与 ssh 一样使用SSH代理
+			RemotePath: request.RemotePath,
+			Enabled:    request.RemotePath != "", // 未填写远程路径时需要编辑后再启用
+		}
+		// known_hosts 中已有该主机时直接信任，否则在首次测试连接时记录
+		if hostKey, err := utils.LookupKnownHost("", host.HostName, host.Port); err == nil {
+			server.HostKey = hostKey
+		}
+
+		config.AddServerConfig(server)
+		existing[host.Alias] = true
+		imported = append(imported, host.Alias)
+	}
+
+	// ProxyJump 引用的主机已作为服务器存在时，设置为跳板机
+	serverIDs := make(map[string]string)
+	for _, server := range config.GetServerConfigs() {
+		serverIDs[server.Name] = server.ID
+	}
+	for _, alias := range imported {
+		host := hostsByAlias[alias]
+		if jumpServerID, ok := serverIDs[host.ProxyJump]; ok && host.ProxyJump != alias {
+			config.SetServerJumpServer(serverIDs[alias], jumpServerID)
+		}
+	}
+
+	c.JSON(200, gin.H{
+		"message":  fmt.Sprintf("已导入 %d 个服务器，跳过 %d 个", len(imported), len(skipped)),
+		"imported": imported,
+		"skipped":  skipped,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"html/template"
+	"hugo-manager-go/config"
 	"hugo-manager-go/controller"
 	"hugo-manager-go/utils"
 	"net"
@@ -196,6 +197,9 @@ func Start() {
 	r.POST("/api/encrypt-credentials", controller.EncryptPlaintextCredentials)
 	r.POST("/api/update-master-password", controller.UpdateMasterPassword)
 	r.POST("/api/test-ssh", controller.TestSSHConnection)
+	r.GET("/api/ssh-host-key", controller.GetSSHHostKey)
+	r.POST("/api/ssh-host-key/accept", controller.AcceptSSHHostKey)
+	r.POST("/api/ssh-host-key/import", controller.ImportSSHHostKey)
 	r.POST("/api/build-hugo", controller.BuildHugo)
 	r.POST("/api/deploy", controller.DeployToServer)
 	r.POST("/api/incremental-deploy", controller.IncrementalDeployToServer)
@@ -204,6 +208,7 @@ func Start() {
 	r.POST("/api/pause-deployment", controller.PauseDeployment)
 	r.POST("/api/resume-deployment", controller.ResumeDeployment)
 	r.GET("/api/deployment-status", controller.GetDeploymentStatus)
+	r.GET("/api/mirror-preview", controller.PreviewMirrorDeletions)
 
 	// 多服务器部署相关路由
 	r.GET("/api/multi-deploy/servers", controller.GetMultiServerConfigs)
@@ -212,14 +217,67 @@ func Start() {
 	r.PUT("/api/multi-deploy/server/:server_id", controller.UpdateMultiServerConfig)
 	r.DELETE("/api/multi-deploy/server/:server_id", controller.DeleteMultiServerConfig)
 	r.POST("/api/multi-deploy/test/:server_id", controller.TestMultiServerConnection)
+	r.GET("/api/multi-deploy/host-key/:server_id", controller.GetMultiServerHostKey)
+	r.POST("/api/multi-deploy/host-key/:server_id/accept", controller.AcceptMultiServerHostKey)
+	r.POST("/api/multi-deploy/host-key/:server_id/import", controller.ImportMultiServerHostKey)
+	r.GET("/api/multi-deploy/mirror-preview/:server_id", controller.PreviewMultiServerMirrorDeletions)
+	r.GET("/api/multi-deploy/releases/:server_id", controller.GetMultiServerReleases)
+	r.POST("/api/multi-deploy/rollback/:server_id", controller.RollbackMultiServerRelease)
+	r.POST("/api/multi-deploy/smoke-test/:server_id", controller.SmokeTestMultiServer)
+	r.POST("/api/multi-deploy/repair-permissions/:server_id", controller.RepairMultiServerPermissions)
+	r.GET("/api/multi-deploy/snapshots/:server_id", controller.GetServerSnapshots)
+	r.POST("/api/multi-deploy/snapshots/:server_id", controller.CreateServerSnapshot)
+	r.POST("/api/multi-deploy/snapshots/:server_id/:snapshot_id/restore", controller.RestoreServerSnapshot)
+	r.GET("/api/multi-deploy/snapshots/:server_id/:snapshot_id/download", controller.DownloadServerSnapshot)
+	r.DELETE("/api/multi-deploy/snapshots/:server_id/:snapshot_id", controller.DeleteServerSnapshot)
 	r.POST("/api/multi-deploy/deploy/:server_id", controller.DeployToMultiServer)
 	r.POST("/api/multi-deploy/incremental-deploy/:server_id", controller.IncrementalDeployToMultiServer)
 	r.POST("/api/multi-deploy/build-deploy/:server_id", controller.BuildAndDeployToMultiServer)
 	r.POST("/api/multi-deploy/incremental-build-deploy/:server_id", controller.IncrementalBuildAndDeployToMultiServer)
 	r.POST("/api/multi-deploy/pause/:server_id", controller.PauseMultiServerDeployment)
 	r.POST("/api/multi-deploy/resume/:server_id", controller.ResumeMultiServerDeployment)
+	r.GET("/api/multi-deploy/resumable", controller.GetResumableDeployments)
 	r.POST("/api/multi-deploy/stop/:server_id", controller.StopMultiServerDeployment)
 	r.GET("/api/multi-deploy/statuses", controller.GetMultiServerStatuses)
+	r.POST("/api/multi-deploy/deploy-all", controller.DeployToAllServers)
+	r.POST("/api/multi-deploy/test-all", controller.TestServersBySelector)
+	r.POST("/api/multi-deploy/pause-all", controller.PauseServersBySelector)
+	r.POST("/api/multi-deploy/stop-all", controller.StopServersBySelector)
+	r.GET("/api/multi-deploy/jobs", controller.GetDeployJobs)
+	r.GET("/api/multi-deploy/jobs/:job_id", controller.GetDeployJob)
+	r.GET("/api/multi-deploy/locks", controller.GetDeployLocks)
+	r.DELETE("/api/multi-deploy/locks/:key", controller.ReleaseDeployLock)
+	r.GET("/api/multi-deploy/ssh-config-hosts", controller.GetSSHConfigHosts)
+	r.POST("/api/multi-deploy/import-ssh-config", controller.ImportSSHConfigHosts)
+	r.POST("/api/multi-deploy/export", controller.ExportMultiServerConfigs)
+	r.POST("/api/multi-deploy/import", controller.ImportMultiServerConfigs)
+
+	// 定时部署相关路由
+	r.GET("/api/schedules", controller.GetSchedules)
+	r.POST("/api/schedules", controller.AddSchedule)
+	r.GET("/api/schedules/runs", controller.GetScheduleRuns)
+	r.GET("/api/schedules/posts", controller.GetScheduledPosts)
+	r.GET("/api/schedules/:id", controller.GetSchedule)
+	r.PUT("/api/schedules/:id", controller.UpdateSchedule)
+	r.DELETE("/api/schedules/:id", controller.DeleteSchedule)
+	r.POST("/api/schedules/:id/run", controller.RunScheduleNow)
+
+	// 部署环境和构件相关路由
+	r.GET("/api/environments", controller.GetEnvironments)
+	r.POST("/api/environments", controller.AddEnvironment)
+	r.PUT("/api/environments/order", controller.ReorderEnvironments)
+	r.PUT("/api/environments/:id", controller.UpdateEnvironment)
+	r.DELETE("/api/environments/:id", controller.DeleteEnvironment)
+	r.POST("/api/environments/:id/build", controller.BuildEnvironment)
+	r.GET("/api/artifacts", controller.GetArtifacts)
+	r.GET("/api/artifacts/:id", controller.GetArtifact)
+	r.DELETE("/api/artifacts/:id", controller.DeleteArtifact)
+	r.POST("/api/artifacts/:id/deploy", controller.DeployArtifact)
+	r.POST("/api/artifacts/:id/promote", controller.PromoteArtifact)
+
+	// 部署历史相关路由
+	r.GET("/api/deployments", controller.GetDeployments)
+	r.GET("/api/deployments/:id", controller.GetDeployment)
 
 	// Hugo serve相关路由
 	r.POST("/api/hugo-serve/start", controller.StartHugoServe)
@@ -326,6 +384,19 @@ func Start() {
 		return
 	}
 
+	// 释放上次退出时遗留的部署锁
+	for _, lock := range config.ReleaseStaleDeployLocks() {
+		fmt.Printf("已释放上次运行遗留的部署锁: %s（%s，进程 %d）\n", lock.Key, lock.Operation, lock.PID)
+	}
+
+	// 检查上次退出时中断的部署，可以在部署页面继续
+	if resumable := config.RecoverInterruptedDeployments(); len(resumable) > 0 {
+		fmt.Printf("发现 %d 个未完成的部署，可以在部署页面继续\n", len(resumable))
+	}
+
+	// 启动定时部署调度器
+	controller.StartScheduler()
+
 	address := ":" + strconv.Itoa(port)
 	url := fmt.Sprintf("http://localhost:%d", port)
 	fmt.Printf("Hugo Manager 正在启动，访问地址: %s\n", url)
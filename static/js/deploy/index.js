@@ -6,6 +6,12 @@
         
         // 模态框实例
         let serverConfigModal;
+        let sshConfigImportModal;
+        let serverExportModal;
+        let serverImportModal;
+        let scheduleModal;
+        let environmentModal;
+        let snapshotModal;
         
         // 全局构建状态
         let isBuilt = false;
@@ -39,6 +45,9 @@
             // 初始加载服务器列表
             loadServerList();
             
+            // 检查上次退出时中断的部署
+            checkResumableDeployments();
+            
             // 定时刷新服务器状态
             setInterval(refreshServerStatuses, 5000);
             
@@ -104,6 +113,12 @@
         function handleProgressUpdate(data) {
             console.log('收到进度更新:', data);
             
+            // 部署命令的输出只写入日志，不影响进度显示
+            if (data.type === 'hook') {
+                addToLog(`[${data.server_name || '系统'}] ${data.message}`, 'info');
+                return;
+            }
+            
             // 如果有服务器ID，更新对应服务器的状态
             if (data.server_id) {
                 updateServerRowFromWebSocket(data.server_id, data);
@@ -248,148 +263,1617 @@
             document.getElementById('serverId').value = '';
             document.getElementById('serverPort').value = '22';
             document.getElementById('serverEnabled').checked = true;
+            setSavedSecretPlaceholders(false);
             
             // 重置认证方式显示
             document.getElementById('passwordAuth').style.display = 'block';
             document.getElementById('keyAuth').style.display = 'none';
             document.getElementById('authPassword').checked = true;
+            updateServerTypeFields();
+            loadJumpServerOptions('', '');
+            loadEnvironmentOptions('');
+            loadHostKeyInfo('');
             
             serverConfigModal.show();
         }
         
+        // 编辑服务器时密码和密钥不回显，提示留空表示沿用
+        function setSavedSecretPlaceholders(editing) {
+            const placeholder = editing ? '留空表示沿用已保存的值' : '';
+            ['serverPassword', 'serverKeyPassphrase', 'serverS3AccessKey', 'serverS3SecretKey'].forEach(id => {
+                document.getElementById(id).placeholder = placeholder;
+            });
+            if (!editing) {
+                document.getElementById('serverUsername').placeholder = '';
+            }
+        }
+        
         // 显示配置服务器模态框
         function showConfigModal(serverId) {
             fetch('/api/multi-deploy/server/' + serverId)
                 .then(response => response.json())
                 .then(data => {
                     if (data.error) {
-                        alert('获取服务器配置失败: ' + data.error);
+                        alert('获取服务器配置失败: ' + data.error);
+                        return;
+                    }
+                    
+                    const server = data.server;
+                    document.getElementById('serverId').value = server.id;
+                    document.getElementById('serverName').value = server.name;
+                    document.getElementById('serverDomain').value = server.domain || '';
+                    document.getElementById('serverGroup').value = server.group || '';
+                    document.getElementById('serverTags').value = (server.tags || []).join(', ');
+                    document.getElementById('serverSmokeTest').checked = !!server.smoke_test;
+                    document.getElementById('serverSmokeTestAction').value = server.smoke_test_action || 'warn';
+                    document.getElementById('serverSmokeTestSamples').value = server.smoke_test_samples || '';
+                    document.getElementById('serverType').value = server.type || 'ssh';
+                    document.getElementById('serverS3Endpoint').value = server.s3_endpoint || '';
+                    document.getElementById('serverS3Bucket').value = server.s3_bucket || '';
+                    document.getElementById('serverS3Region').value = server.s3_region || '';
+                    // 密码和密钥不回显，留空表示沿用
+                    document.getElementById('serverS3AccessKey').value = '';
+                    document.getElementById('serverS3SecretKey').value = '';
+                    setSavedSecretPlaceholders(true);
+                    document.getElementById('serverGitRemote').value = server.git_remote || '';
+                    document.getElementById('serverGitBranch').value = server.git_branch || '';
+                    document.getElementById('serverHost').value = server.host;
+                    document.getElementById('serverPort').value = server.port;
+                    // 加密保存的用户名不回显，留空表示沿用
+                    document.getElementById('serverUsername').value = server.username || '';
+                    document.getElementById('serverMasterPassword').value = '';
+                    document.getElementById('serverUsername').placeholder = server.encrypted_username ? '已加密保存，留空沿用' : '';
+                    document.getElementById('serverPassword').value = '';
+                    document.getElementById('serverKeyPath').value = server.key_path || '';
+                    document.getElementById('serverKeyPassphrase').value = '';
+                    document.getElementById('serverUseAgent').checked = !!server.use_agent;
+                    document.getElementById('serverRemotePath').value = server.remote_path;
+                    document.getElementById('serverTransferMode').value = server.transfer_mode || 'auto';
+                    document.getElementById('serverUploadWorkers').value = server.upload_workers || '';
+                    document.getElementById('serverBandwidthLimit').value = server.bandwidth_limit ? Math.round(server.bandwidth_limit / 1024) : '';
+                    document.getElementById('serverFileMode').value = server.file_mode || '';
+                    document.getElementById('serverDirMode').value = server.dir_mode || '';
+                    document.getElementById('serverFileGroup').value = server.file_group || '';
+                    document.getElementById('serverMirrorDeletions').checked = !!server.mirror_deletions;
+                    document.getElementById('serverProtectedPaths').value = (server.protected_paths || []).join('\n');
+                    document.getElementById('serverExcludePatterns').value = (server.exclude_patterns || []).join('\n');
+                    document.getElementById('serverIncludePatterns').value = (server.include_patterns || []).join('\n');
+                    document.getElementById('serverReleaseMode').checked = !!server.release_mode;
+                    document.getElementById('serverKeepReleases').value = server.keep_releases || '';
+                    document.getElementById('serverSnapshotMode').value = server.snapshot_mode || '';
+                    document.getElementById('serverKeepSnapshots').value = server.keep_snapshots || '';
+                    const hooks = (server.pre_deploy_hooks || []).concat(server.post_deploy_hooks || []);
+                    document.getElementById('serverPreDeployHooks').value = (server.pre_deploy_hooks || []).map(hook => hook.command).join('\n');
+                    document.getElementById('serverPostDeployHooks').value = (server.post_deploy_hooks || []).map(hook => hook.command).join('\n');
+                    document.getElementById('serverHookTimeout').value = hooks.length > 0 && hooks[0].timeout ? hooks[0].timeout : '';
+                    document.getElementById('serverEnabled').checked = server.enabled;
+                    
+                    if (server.key_path) {
+                        document.getElementById('authKey').checked = true;
+                        document.getElementById('keyAuth').style.display = 'block';
+                        document.getElementById('passwordAuth').style.display = 'none';
+                    } else {
+                        document.getElementById('authPassword').checked = true;
+                        document.getElementById('passwordAuth').style.display = 'block';
+                        document.getElementById('keyAuth').style.display = 'none';
+                    }
+                    
+                    updateServerTypeFields();
+                    loadJumpServerOptions(server.id, server.jump_server_id || '');
+                    loadEnvironmentOptions(server.environment || '');
+                    loadHostKeyInfo(server.id);
+                    serverConfigModal.show();
+                })
+                .catch(error => {
+                    alert('获取服务器配置失败: ' + error.message);
+                });
+        }
+        
+        // 加载可作为跳板机的SSH服务器
+        function loadJumpServerOptions(serverId, selectedId) {
+            const select = document.getElementById('serverJumpServer');
+            while (select.options.length > 1) {
+                select.remove(1);
+            }
+            
+            fetch('/api/multi-deploy/servers')
+                .then(response => response.json())
+                .then(data => {
+                    (data.servers || []).forEach(server => {
+                        if (server.id === serverId || (server.type && server.type !== 'ssh')) {
+                            return;
+                        }
+                        select.add(new Option(server.name + ' (' + server.host + ':' + server.port + ')', server.id));
+                    });
+                    select.value = selectedId;
+                })
+                .catch(error => {
+                    console.error('加载跳板机列表失败:', error);
+                });
+        }
+        
+        // 显示从SSH配置导入模态框
+        function showSSHConfigImportModal() {
+            if (!sshConfigImportModal) {
+                sshConfigImportModal = new bootstrap.Modal(document.getElementById('sshConfigImportModal'));
+            }
+            loadSSHConfigHosts();
+            sshConfigImportModal.show();
+        }
+        
+        // 读取SSH配置文件中的主机
+        function loadSSHConfigHosts() {
+            const path = document.getElementById('sshConfigPath').value.trim();
+            const container = document.getElementById('sshConfigHosts');
+            container.innerHTML = '<div class="text-muted">正在读取...</div>';
+            
+            fetch('/api/multi-deploy/ssh-config-hosts?path=' + encodeURIComponent(path))
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        container.innerHTML = '<div class="text-danger"></div>';
+                        container.firstChild.textContent = data.error;
+                        return;
+                    }
+                    if (data.hosts.length === 0) {
+                        container.innerHTML = '<div class="text-muted">配置文件中没有可导入的主机</div>';
+                        return;
+                    }
+                    
+                    container.innerHTML = '';
+                    data.hosts.forEach((host, index) => {
+                        const item = document.createElement('div');
+                        item.className = 'form-check';
+                        
+                        const checkbox = document.createElement('input');
+                        checkbox.className = 'form-check-input';
+                        checkbox.type = 'checkbox';
+                        checkbox.id = 'sshImportHost' + index;
+                        checkbox.value = host.alias;
+                        checkbox.checked = !host.exists;
+                        checkbox.disabled = host.exists;
+                        
+                        const label = document.createElement('label');
+                        label.className = 'form-check-label';
+                        label.htmlFor = checkbox.id;
+                        label.textContent = host.alias + ' (' + host.user + '@' + host.host_name + ':' + host.port + ')' +
+                            (host.identity_file ? ' - ' + host.identity_file : ' - SSH代理') +
+                            (host.exists ? ' - 已存在' : '');
+                        
+                        item.appendChild(checkbox);
+                        item.appendChild(label);
+                        container.appendChild(item);
+                    });
+                })
+                .catch(error => {
+                    container.innerHTML = '<div class="text-danger"></div>';
+                    container.firstChild.textContent = '读取失败: ' + error.message;
+                });
+        }
+        
+        // 导入选中的主机
+        function importSSHConfigHosts() {
+            const hosts = Array.from(document.querySelectorAll('#sshConfigHosts input:checked')).map(input => input.value);
+            if (hosts.length === 0) {
+                alert('请选择要导入的主机');
+                return;
+            }
+            
+            fetch('/api/multi-deploy/import-ssh-config', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({
+                    path: document.getElementById('sshConfigPath').value.trim(),
+                    hosts: hosts,
+                    remote_path: document.getElementById('sshImportRemotePath').value.trim()
+                })
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.error) {
+                    alert('导入失败: ' + data.error);
+                    return;
+                }
+                
+                alert(data.message);
+                sshConfigImportModal.hide();
+                loadServerList(); // 重新加载服务器列表
+            })
+            .catch(error => {
+                alert('导入失败: ' + error.message);
+            });
+        }
+        
+        // 显示导出服务器配置模态框
+        function showServerExportModal() {
+            if (!serverExportModal) {
+                serverExportModal = new bootstrap.Modal(document.getElementById('serverExportModal'));
+            }
+            document.getElementById('serverExportAll').checked = false;
+            document.getElementById('serverExportPassphrase').value = '';
+            document.getElementById('serverExportPassphraseConfirm').value = '';
+            
+            const container = document.getElementById('serverExportList');
+            container.innerHTML = '<div class="text-muted">正在加载...</div>';
+            fetch('/api/multi-deploy/servers')
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        container.innerHTML = '<div class="text-danger"></div>';
+                        container.firstChild.textContent = data.error;
+                        return;
+                    }
+                    if (data.servers.length === 0) {
+                        container.innerHTML = '<div class="text-muted">还没有服务器配置</div>';
+                        return;
+                    }
+                    
+                    container.innerHTML = '';
+                    data.servers.forEach((server, index) => {
+                        const item = document.createElement('div');
+                        item.className = 'form-check';
+                        
+                        const checkbox = document.createElement('input');
+                        checkbox.className = 'form-check-input';
+                        checkbox.type = 'checkbox';
+                        checkbox.id = 'serverExport' + index;
+                        checkbox.value = server.id;
+                        
+                        const label = document.createElement('label');
+                        label.className = 'form-check-label';
+                        label.htmlFor = checkbox.id;
+                        label.textContent = server.name + (server.host ? ' (' + server.host + ')' : '') +
+                            (server.group ? ' - ' + server.group : '');
+                        
+                        item.appendChild(checkbox);
+                        item.appendChild(label);
+                        container.appendChild(item);
+                    });
+                })
+                .catch(error => {
+                    container.innerHTML = '<div class="text-danger"></div>';
+                    container.firstChild.textContent = '加载失败: ' + error.message;
+                });
+            serverExportModal.show();
+        }
+        
+        // 全选或取消全选要导出的服务器
+        function toggleServerExportAll(checked) {
+            document.querySelectorAll('#serverExportList input[type="checkbox"]').forEach(input => {
+                input.checked = checked;
+            });
+        }
+        
+        // 导出选中的服务器配置并下载
+        function exportServerConfigs() {
+            const serverIds = Array.from(document.querySelectorAll('#serverExportList input:checked')).map(input => input.value);
+            if (serverIds.length === 0) {
+                alert('请选择要导出的服务器');
+                return;
+            }
+            const passphrase = document.getElementById('serverExportPassphrase').value;
+            if (!passphrase) {
+                alert('请设置导出口令');
+                return;
+            }
+            if (passphrase !== document.getElementById('serverExportPassphraseConfirm').value) {
+                alert('两次输入的口令不一致');
+                return;
+            }
+            
+            let filename = 'hugo-manager-servers.json';
+            fetch('/api/multi-deploy/export', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({
+                    server_ids: serverIds,
+                    passphrase: passphrase
+                })
+            })
+            .then(response => {
+                const match = /filename="([^"]+)"/.exec(response.headers.get('Content-Disposition') || '');
+                if (match) {
+                    filename = match[1];
+                }
+                return response.json();
+            })
+            .then(data => {
+                if (data.error) {
+                    alert('导出失败: ' + data.error);
+                    return;
+                }
+                
+                const blob = new Blob([JSON.stringify(data, null, 2)], { type: 'application/json' });
+                const link = document.createElement('a');
+                link.href = URL.createObjectURL(blob);
+                link.download = filename;
+                document.body.appendChild(link);
+                link.click();
+                document.body.removeChild(link);
+                URL.revokeObjectURL(link.href);
+                
+                showNotification('已导出 ' + data.servers.length + ' 个服务器配置', 'success');
+                serverExportModal.hide();
+            })
+            .catch(error => {
+                alert('导出失败: ' + error.message);
+            });
+        }
+        
+        // 显示导入服务器配置模态框
+        function showServerImportModal() {
+            if (!serverImportModal) {
+                serverImportModal = new bootstrap.Modal(document.getElementById('serverImportModal'));
+            }
+            document.getElementById('serverImportFile').value = '';
+            document.getElementById('serverImportPassphrase').value = '';
+            document.getElementById('serverImportMasterPassword').value = '';
+            document.getElementById('serverImportResult').innerHTML = '';
+            serverImportModal.show();
+        }
+        
+        // 读取导出文件并导入服务器配置
+        function importServerConfigs() {
+            const file = document.getElementById('serverImportFile').files[0];
+            if (!file) {
+                alert('请选择导出文件');
+                return;
+            }
+            const passphrase = document.getElementById('serverImportPassphrase').value;
+            if (!passphrase) {
+                alert('请输入导出口令');
+                return;
+            }
+            
+            const reader = new FileReader();
+            reader.onload = () => {
+                let exported;
+                try {
+                    exported = JSON.parse(reader.result);
+                } catch (error) {
+                    alert('导出文件格式错误');
+                    return;
+                }
+                
+                fetch('/api/multi-deploy/import', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json',
+                    },
+                    body: JSON.stringify({
+                        export: exported,
+                        passphrase: passphrase,
+                        master_password: document.getElementById('serverImportMasterPassword').value,
+                        duplicates: document.getElementById('serverImportDuplicates').value
+                    })
+                })
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert('导入失败: ' + data.error);
+                        return;
+                    }
+                    
+                    renderServerImportResult(data);
+                    addToLog('导入服务器配置: ' + data.message);
+                    loadServerList(); // 重新加载服务器列表
+                })
+                .catch(error => {
+                    alert('导入失败: ' + error.message);
+                });
+            };
+            reader.onerror = () => alert('读取文件失败');
+            reader.readAsText(file);
+        }
+        
+        // 显示导入结果：跳过的服务器和原因、警告
+        function renderServerImportResult(data) {
+            const container = document.getElementById('serverImportResult');
+            container.innerHTML = '';
+            
+            const summary = document.createElement('div');
+            summary.className = 'alert alert-success';
+            summary.textContent = data.message;
+            container.appendChild(summary);
+            
+            const lines = data.skipped.map(item => '跳过 ' + item.name + ': ' + item.reason).concat(data.warnings);
+            if (lines.length > 0) {
+                const list = document.createElement('ul');
+                list.className = 'small text-warning mb-0';
+                lines.forEach(line => {
+                    const item = document.createElement('li');
+                    item.textContent = line;
+                    list.appendChild(item);
+                });
+                container.appendChild(list);
+            }
+        }
+        
+        // 加载部署环境选项
+        function loadEnvironmentOptions(selectedId) {
+            const select = document.getElementById('serverEnvironment');
+            while (select.options.length > 1) {
+                select.remove(1);
+            }
+            
+            fetch('/api/environments')
+                .then(response => response.json())
+                .then(data => {
+                    (data.environments || []).forEach(environment => {
+                        select.add(new Option(environment.name, environment.id));
+                    });
+                    select.value = selectedId;
+                })
+                .catch(error => {
+                    console.error('加载环境列表失败:', error);
+                });
+        }
+        
+        // 显示部署环境模态框
+        function showEnvironmentModal() {
+            if (!environmentModal) {
+                environmentModal = new bootstrap.Modal(document.getElementById('environmentModal'));
+                document.getElementById('environmentForm').addEventListener('submit', function(e) {
+                    e.preventDefault();
+                    saveEnvironment();
+                });
+            }
+            resetEnvironmentForm();
+            loadEnvironments();
+            environmentModal.show();
+        }
+        
+        // 格式化文件大小
+        function formatArtifactSize(bytes) {
+            if (bytes >= 1024 * 1024) {
+                return (bytes / 1024 / 1024).toFixed(1) + ' MB';
+            }
+            return (bytes / 1024).toFixed(1) + ' KB';
+        }
+        
+        // 加载环境和构件列表
+        function loadEnvironments() {
+            const tbody = document.getElementById('environmentTableBody');
+            
+            Promise.all([
+                fetch('/api/environments').then(response => response.json()),
+                fetch('/api/multi-deploy/servers').then(response => response.json()),
+                fetch('/api/artifacts').then(response => response.json())
+            ])
+            .then(([data, serverData, artifactData]) => {
+                const environments = data.environments || [];
+                const environmentNames = {};
+                environments.forEach(environment => environmentNames[environment.id] = environment.name);
+                
+                tbody.innerHTML = '';
+                if (environments.length === 0) {
+                    tbody.innerHTML = '<tr><td colspan="6" class="text-muted text-center">暂无部署环境</td></tr>';
+                }
+                environments.forEach((environment, index) => {
+                    const servers = (serverData.servers || []).filter(server => server.environment === environment.id);
+                    const row = document.createElement('tr');
+                    [
+                        (index + 1) + '. ' + environment.name,
+                        environment.hugo_environment || '-',
+                        environment.base_url || '-',
+                        servers.length > 0 ? servers.map(server => server.name + (server.enabled ? '' : '（已禁用）')).join(', ') : '-',
+                        environment.current_artifact || '-'
+                    ].forEach(text => {
+                        const cell = document.createElement('td');
+                        cell.textContent = text;
+                        row.appendChild(cell);
+                    });
+                    
+                    const actions = document.createElement('td');
+                    actions.className = 'text-nowrap';
+                    actions.innerHTML = `
+                        <button class="btn btn-sm btn-outline-secondary" onclick="buildEnvironment('${environment.id}', false)" title="构建"><i class="bi bi-hammer"></i></button>
+                        <button class="btn btn-sm btn-outline-success" onclick="buildEnvironment('${environment.id}', true)" title="构建并部署"><i class="bi bi-rocket-takeoff"></i></button>
+                        <button class="btn btn-sm btn-outline-secondary" onclick="moveEnvironment('${environment.id}', -1)" title="上移" ${index === 0 ? 'disabled' : ''}><i class="bi bi-arrow-up"></i></button>
+                        <button class="btn btn-sm btn-outline-primary" onclick="editEnvironment('${environment.id}')" title="编辑"><i class="bi bi-pencil"></i></button>
+                        <button class="btn btn-sm btn-outline-danger" onclick="deleteEnvironment('${environment.id}')" title="删除"><i class="bi bi-trash"></i></button>
+                    `;
+                    row.appendChild(actions);
+                    tbody.appendChild(row);
+                });
+                
+                updateArtifactTable(artifactData.artifacts || [], environments, environmentNames);
+            })
+            .catch(error => {
+                tbody.innerHTML = '<tr><td colspan="6" class="text-danger"></td></tr>';
+                tbody.querySelector('td').textContent = '加载环境失败: ' + error.message;
+            });
+        }
+        
+        // 更新构件列表
+        function updateArtifactTable(artifacts, environments, environmentNames) {
+            const tbody = document.getElementById('artifactTableBody');
+            tbody.innerHTML = '';
+            if (artifacts.length === 0) {
+                tbody.innerHTML = '<tr><td colspan="6" class="text-muted text-center">暂无构件</td></tr>';
+                return;
+            }
+            
+            const current = environments.map(environment => environment.current_artifact);
+            artifacts.forEach(artifact => {
+                const index = environments.findIndex(environment => environment.id === artifact.environment);
+                const next = index >= 0 ? environments[index + 1] : null;
+                
+                const row = document.createElement('tr');
+                [
+                    artifact.id + (current.includes(artifact.id) ? '（当前）' : ''),
+                    environmentNames[artifact.environment] || artifact.environment,
+                    artifact.files + ' / ' + formatArtifactSize(artifact.size),
+                    artifact.promoted_from ? '提升自 ' + artifact.promoted_from : '构建' + (artifact.base_url ? '（' + artifact.base_url + '）' : ''),
+                    new Date(artifact.created_at).toLocaleString()
+                ].forEach(text => {
+                    const cell = document.createElement('td');
+                    cell.textContent = text;
+                    row.appendChild(cell);
+                });
+                
+                const actions = document.createElement('td');
+                actions.className = 'text-nowrap';
+                actions.innerHTML = `
+                    <button class="btn btn-sm btn-outline-primary" onclick="deployArtifact('${artifact.id}')" title="部署到所属环境"><i class="bi bi-upload"></i></button>
+                    <button class="btn btn-sm btn-outline-success" onclick="promoteArtifact('${artifact.id}')" title="提升到下一个环境" ${next ? '' : 'disabled'}><i class="bi bi-box-arrow-up-right"></i></button>
+                    <button class="btn btn-sm btn-outline-danger" onclick="deleteArtifact('${artifact.id}')" title="删除"><i class="bi bi-trash"></i></button>
+                `;
+                if (next) {
+                    actions.children[1].title = '提升到 ' + next.name;
+                }
+                row.appendChild(actions);
+                tbody.appendChild(row);
+            });
+        }
+        
+        // 重置环境表单
+        function resetEnvironmentForm() {
+            document.getElementById('environmentForm').reset();
+            document.getElementById('environmentId').value = '';
+            document.getElementById('environmentFormTitle').textContent = '添加环境';
+        }
+        
+        // 编辑环境
+        function editEnvironment(environmentId) {
+            fetch('/api/environments')
+                .then(response => response.json())
+                .then(data => {
+                    const environment = (data.environments || []).find(environment => environment.id === environmentId);
+                    if (!environment) {
+                        alert('环境不存在');
+                        return;
+                    }
+                    document.getElementById('environmentId').value = environment.id;
+                    document.getElementById('environmentFormTitle').textContent = '编辑环境';
+                    document.getElementById('environmentName').value = environment.name;
+                    document.getElementById('environmentHugoEnv').value = environment.hugo_environment || '';
+                    document.getElementById('environmentBaseURL').value = environment.base_url || '';
+                });
+        }
+        
+        // 保存环境
+        function saveEnvironment() {
+            const environmentId = document.getElementById('environmentId').value;
+            const environment = {
+                name: document.getElementById('environmentName').value.trim(),
+                hugo_environment: document.getElementById('environmentHugoEnv').value.trim(),
+                base_url: document.getElementById('environmentBaseURL').value.trim()
+            };
+            
+            fetch(environmentId ? '/api/environments/' + environmentId : '/api/environments', {
+                method: environmentId ? 'PUT' : 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify(environment)
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.error) {
+                    alert('保存失败: ' + data.error);
+                    return;
+                }
+                showNotification(data.message, 'success');
+                resetEnvironmentForm();
+                loadEnvironments();
+            })
+            .catch(error => {
+                alert('保存失败: ' + error.message);
+            });
+        }
+        
+        // 删除环境
+        function deleteEnvironment(environmentId) {
+            if (!confirm('确定要删除这个环境吗？环境的构件也会被删除。')) {
+                return;
+            }
+            
+            fetch('/api/environments/' + environmentId, { method: 'DELETE' })
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert('删除失败: ' + data.error);
+                        return;
+                    }
+                    showNotification(data.message, 'success');
+                    loadEnvironments();
+                })
+                .catch(error => {
+                    alert('删除失败: ' + error.message);
+                });
+        }
+        
+        // 调整环境顺序
+        function moveEnvironment(environmentId, offset) {
+            fetch('/api/environments')
+                .then(response => response.json())
+                .then(data => {
+                    const ids = (data.environments || []).map(environment => environment.id);
+                    const index = ids.indexOf(environmentId);
+                    const target = index + offset;
+                    if (index < 0 || target < 0 || target >= ids.length) {
+                        return;
+                    }
+                    [ids[index], ids[target]] = [ids[target], ids[index]];
+                    
+                    return fetch('/api/environments/order', {
+                        method: 'PUT',
+                        headers: {
+                            'Content-Type': 'application/json',
+                        },
+                        body: JSON.stringify({ environment_ids: ids })
+                    })
+                    .then(response => response.json())
+                    .then(data => {
+                        if (data.error) {
+                            alert('调整顺序失败: ' + data.error);
+                            return;
+                        }
+                        loadEnvironments();
+                    });
+                })
+                .catch(error => {
+                    alert('调整顺序失败: ' + error.message);
+                });
+        }
+        
+        // 构建环境，可选地部署到环境中的服务器
+        function buildEnvironment(environmentId, deploy) {
+            addToLog(deploy ? '开始构建并部署环境...' : '开始构建环境...', 'info');
+            
+            fetch('/api/environments/' + environmentId + '/build', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({ deploy: deploy, incremental: true })
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.error) {
+                    addToLog('ERROR: ' + data.error, 'error');
+                    alert('构建失败: ' + data.error);
+                    return;
+                }
+                addToLog(data.message, 'success');
+                showNotification(data.message, 'success');
+                loadEnvironments();
+            })
+            .catch(error => {
+                alert('构建失败: ' + error.message);
+            });
+        }
+        
+        // 构件的构建参数与目标环境不一致的项
+        function formatArtifactMismatches(mismatches) {
+            const names = { base_url: 'baseURL', hugo_environment: 'Hugo环境' };
+            return mismatches.map(m =>
+                names[m.field] + ': 构件 ' + (m.artifact || '（默认）') + '，目标环境 ' + (m.environment || '（默认）')
+            ).join('\n');
+        }
+        
+        // 执行构件操作（部署或提升），force 为 true 时忽略构建参数与目标环境的差异
+        function runArtifactAction(artifactId, action, label, force) {
+            fetch('/api/artifacts/' + artifactId + '/' + action, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({ incremental: true, force: !!force })
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.error && data.mismatches && data.mismatches.length > 0) {
+                    if (confirm(data.error + '\n\n' + formatArtifactMismatches(data.mismatches) + '\n\n站点中的链接将指向构建时的地址，确定要强制提升吗？')) {
+                        runArtifactAction(artifactId, action, label, true);
+                    }
+                    return;
+                }
+                if (data.error) {
+                    addToLog('ERROR: ' + label + '失败 - ' + data.error, 'error');
+                    alert(label + '失败: ' + data.error);
+                    return;
+                }
+                if (data.mismatches && data.mismatches.length > 0) {
+                    addToLog('WARNING: 构件的构建参数与目标环境不一致\n' + formatArtifactMismatches(data.mismatches), 'warning');
+                }
+                addToLog(data.message, 'info');
+                showNotification(data.message, 'info');
+                loadEnvironments();
+            })
+            .catch(error => {
+                alert(label + '失败: ' + error.message);
+            });
+        }
+        
+        // 将构件部署到所属环境
+        function deployArtifact(artifactId) {
+            if (confirm('确定要将构件 ' + artifactId + ' 部署到所属环境吗？')) {
+                runArtifactAction(artifactId, 'deploy', '部署构件');
+            }
+        }
+        
+        // 将构件提升到下一个环境
+        function promoteArtifact(artifactId) {
+            if (confirm('确定要将构件 ' + artifactId + ' 原样提升到下一个环境并部署吗？')) {
+                runArtifactAction(artifactId, 'promote', '提升构件');
+            }
+        }
+        
+        // 删除构件
+        function deleteArtifact(artifactId) {
+            if (!confirm('确定要删除构件 ' + artifactId + ' 吗？')) {
+                return;
+            }
+            
+            fetch('/api/artifacts/' + artifactId, { method: 'DELETE' })
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert('删除失败: ' + data.error);
+                        return;
+                    }
+                    showNotification(data.message, 'success');
+                    loadEnvironments();
+                })
+                .catch(error => {
+                    alert('删除失败: ' + error.message);
+                });
+        }
+        
+        // 显示定时部署模态框
+        function showScheduleModal() {
+            if (!scheduleModal) {
+                scheduleModal = new bootstrap.Modal(document.getElementById('scheduleModal'));
+                document.getElementById('scheduleForm').addEventListener('submit', function(e) {
+                    e.preventDefault();
+                    saveSchedule();
+                });
+            }
+            resetScheduleForm();
+            loadSchedules();
+            scheduleModal.show();
+        }
+        
+        // 定时部署执行状态文本
+        function getScheduleStatusText(status) {
+            const statusMap = {
+                'running': '执行中',
+                'success': '成功',
+                'partial': '部分成功',
+                'failed': '失败',
+                'skipped': '已跳过',
+                'paused': '已暂停'
+            };
+            return statusMap[status] || status || '-';
+        }
+        
+        // 格式化时间，为空时显示 -
+        function formatScheduleTime(time) {
+            return time ? new Date(time).toLocaleString() : '-';
+        }
+        
+        // 加载定时部署列表、执行记录和待发布的定时文章
+        function loadSchedules() {
+            const tbody = document.getElementById('scheduleTableBody');
+            
+            Promise.all([
+                fetch('/api/schedules').then(response => response.json()),
+                fetch('/api/multi-deploy/servers').then(response => response.json())
+            ])
+            .then(([data, serverData]) => {
+                const serverNames = {};
+                (serverData.servers || []).forEach(server => serverNames[server.id] = server.name);
+                
+                tbody.innerHTML = '';
+                if (!data.schedules || data.schedules.length === 0) {
+                    tbody.innerHTML = '<tr><td colspan="6" class="text-muted text-center">暂无定时部署</td></tr>';
+                    return;
+                }
+                
+                data.schedules.forEach(schedule => {
+                    const row = document.createElement('tr');
+                    const cells = [
+                        schedule.name + (schedule.enabled ? '' : '（已禁用）'),
+                        schedule.kind === 'cron' ? 'cron: ' + schedule.cron : '定时文章到期' + (schedule.next_post ? '（' + schedule.next_post + '）' : ''),
+                        schedule.server_ids && schedule.server_ids.length > 0 ?
+                            schedule.server_ids.map(id => serverNames[id] || id).join(', ') : '所有启用的服务器',
+                        schedule.enabled ? formatScheduleTime(schedule.next_run) : '-',
+                        schedule.last_run ? formatScheduleTime(schedule.last_run) + ' ' + getScheduleStatusText(schedule.last_status) : '-'
+                    ];
+                    cells.forEach((text, index) => {
+                        const cell = document.createElement('td');
+                        cell.textContent = text;
+                        if (index === 4 && schedule.last_message) {
+                            cell.title = schedule.last_message;
+                        }
+                        row.appendChild(cell);
+                    });
+                    
+                    const actions = document.createElement('td');
+                    actions.className = 'text-nowrap';
+                    actions.innerHTML = `
+                        <button class="btn btn-sm btn-outline-success" onclick="runScheduleNow('${schedule.id}')" title="立即执行"><i class="bi bi-play-fill"></i></button>
+                        <button class="btn btn-sm btn-outline-primary" onclick="editSchedule('${schedule.id}')" title="编辑"><i class="bi bi-pencil"></i></button>
+                        <button class="btn btn-sm btn-outline-danger" onclick="deleteSchedule('${schedule.id}')" title="删除"><i class="bi bi-trash"></i></button>
+                    `;
+                    row.appendChild(actions);
+                    tbody.appendChild(row);
+                });
+            })
+            .catch(error => {
+                tbody.innerHTML = '<tr><td colspan="6" class="text-danger"></td></tr>';
+                tbody.querySelector('td').textContent = '加载定时部署失败: ' + error.message;
+            });
+            
+            loadScheduleRuns();
+            loadScheduledPosts();
+        }
+        
+        // 加载定时部署的执行记录
+        function loadScheduleRuns() {
+            const container = document.getElementById('scheduleRuns');
+            fetch('/api/schedules/runs?limit=20')
+                .then(response => response.json())
+                .then(data => {
+                    container.innerHTML = '';
+                    if (!data.runs || data.runs.length === 0) {
+                        container.innerHTML = '<div class="text-muted">暂无执行记录</div>';
+                        return;
+                    }
+                    data.runs.forEach(run => {
+                        const item = document.createElement('div');
+                        item.className = 'border-bottom py-1' + (run.status === 'failed' ? ' text-danger' : '');
+                        item.textContent = formatScheduleTime(run.start_time) + ' ' + run.schedule_name +
+                            (run.trigger === 'manual' ? '（立即执行）' : '') + ' - ' +
+                            getScheduleStatusText(run.status) + (run.message ? '：' + run.message : '');
+                        container.appendChild(item);
+                    });
+                })
+                .catch(error => {
+                    container.textContent = '加载执行记录失败: ' + error.message;
+                });
+        }
+        
+        // 加载待发布的定时文章
+        function loadScheduledPosts() {
+            const container = document.getElementById('scheduledPosts');
+            fetch('/api/schedules/posts')
+                .then(response => response.json())
+                .then(data => {
+                    container.innerHTML = '';
+                    if (!data.posts || data.posts.length === 0) {
+                        container.innerHTML = '<div class="text-muted">没有待发布的定时文章</div>';
+                        return;
+                    }
+                    data.posts.forEach(post => {
+                        const item = document.createElement('div');
+                        item.className = 'border-bottom py-1';
+                        item.textContent = formatScheduleTime(post.publish_time) + ' ' + (post.title || post.path);
+                        item.title = post.path;
+                        container.appendChild(item);
+                    });
+                })
+                .catch(error => {
+                    container.textContent = '加载定时文章失败: ' + error.message;
+                });
+        }
+        
+        // 按触发方式显示对应的配置项
+        function updateScheduleKindFields() {
+            const isCron = document.getElementById('scheduleKind').value === 'cron';
+            document.getElementById('scheduleCronField').style.display = isCron ? '' : 'none';
+            // 文章到期触发时总是构建
+            const build = document.getElementById('scheduleBuild');
+            build.disabled = !isCron;
+            if (!isCron) {
+                build.checked = true;
+            }
+        }
+        
+        // 加载服务器选项
+        function loadScheduleServerOptions(selectedIds) {
+            const container = document.getElementById('scheduleServers');
+            fetch('/api/multi-deploy/servers')
+                .then(response => response.json())
+                .then(data => {
+                    container.innerHTML = '';
+                    (data.servers || []).forEach(server => {
+                        const item = document.createElement('div');
+                        item.className = 'form-check form-check-inline';
+                        
+                        const checkbox = document.createElement('input');
+                        checkbox.className = 'form-check-input';
+                        checkbox.type = 'checkbox';
+                        checkbox.id = 'scheduleServer_' + server.id;
+                        checkbox.value = server.id;
+                        checkbox.checked = selectedIds.includes(server.id);
+                        
+                        const label = document.createElement('label');
+                        label.className = 'form-check-label';
+                        label.htmlFor = checkbox.id;
+                        label.textContent = server.name + (server.enabled ? '' : '（已禁用）');
+                        
+                        item.appendChild(checkbox);
+                        item.appendChild(label);
+                        container.appendChild(item);
+                    });
+                });
+        }
+        
+        // 重置定时部署表单
+        function resetScheduleForm() {
+            document.getElementById('scheduleForm').reset();
+            document.getElementById('scheduleId').value = '';
+            document.getElementById('scheduleFormTitle').textContent = '添加定时部署';
+            updateScheduleKindFields();
+            loadScheduleServerOptions([]);
+        }
+        
+        // 编辑定时部署
+        function editSchedule(scheduleId) {
+            fetch('/api/schedules/' + scheduleId)
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert('加载定时部署失败: ' + data.error);
+                        return;
+                    }
+                    const schedule = data.schedule;
+                    document.getElementById('scheduleId').value = schedule.id;
+                    document.getElementById('scheduleFormTitle').textContent = '编辑定时部署';
+                    document.getElementById('scheduleName').value = schedule.name;
+                    document.getElementById('scheduleKind').value = schedule.kind;
+                    document.getElementById('scheduleCron').value = schedule.cron || '';
+                    document.getElementById('scheduleBuild').checked = schedule.build;
+                    document.getElementById('scheduleIncremental').checked = schedule.incremental;
+                    document.getElementById('scheduleEnabled').checked = schedule.enabled;
+                    updateScheduleKindFields();
+                    loadScheduleServerOptions(schedule.server_ids || []);
+                })
+                .catch(error => {
+                    alert('加载定时部署失败: ' + error.message);
+                });
+        }
+        
+        // 保存定时部署
+        function saveSchedule() {
+            const scheduleId = document.getElementById('scheduleId').value;
+            const schedule = {
+                name: document.getElementById('scheduleName').value.trim(),
+                kind: document.getElementById('scheduleKind').value,
+                cron: document.getElementById('scheduleCron').value.trim(),
+                server_ids: Array.from(document.querySelectorAll('#scheduleServers input:checked')).map(input => input.value),
+                build: document.getElementById('scheduleBuild').checked,
+                incremental: document.getElementById('scheduleIncremental').checked,
+                enabled: document.getElementById('scheduleEnabled').checked
+            };
+            
+            fetch(scheduleId ? '/api/schedules/' + scheduleId : '/api/schedules', {
+                method: scheduleId ? 'PUT' : 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify(schedule)
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.error) {
+                    alert('保存失败: ' + data.error);
+                    return;
+                }
+                showNotification(data.message, 'success');
+                resetScheduleForm();
+                loadSchedules();
+            })
+            .catch(error => {
+                alert('保存失败: ' + error.message);
+            });
+        }
+        
+        // 删除定时部署
+        function deleteSchedule(scheduleId) {
+            if (!confirm('确定要删除这个定时部署吗？')) {
+                return;
+            }
+            
+            fetch('/api/schedules/' + scheduleId, { method: 'DELETE' })
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert('删除失败: ' + data.error);
+                        return;
+                    }
+                    showNotification(data.message, 'success');
+                    loadSchedules();
+                })
+                .catch(error => {
+                    alert('删除失败: ' + error.message);
+                });
+        }
+        
+        // 立即执行定时部署
+        function runScheduleNow(scheduleId) {
+            fetch('/api/schedules/' + scheduleId + '/run', { method: 'POST' })
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert('执行失败: ' + data.error);
+                        return;
+                    }
+                    addToLog(data.message, 'info');
+                    showNotification(data.message, 'info');
+                    setTimeout(loadSchedules, 1000);
+                })
+                .catch(error => {
+                    alert('执行失败: ' + error.message);
+                });
+        }
+        
+        // 根据部署目标类型显示对应的配置项
+        function updateServerTypeFields() {
+            const type = document.getElementById('serverType').value;
+            document.querySelectorAll('#serverConfigForm .ssh-only').forEach(el => {
+                el.style.display = type === 'ssh' ? '' : 'none';
+            });
+            document.querySelectorAll('#serverConfigForm .s3-only').forEach(el => {
+                el.style.display = type === 's3' ? '' : 'none';
+            });
+            document.querySelectorAll('#serverConfigForm .git-only').forEach(el => {
+                el.style.display = type === 'git' ? '' : 'none';
+            });
+            // 并发上传数和带宽限制适用于SSH、本地目录和对象存储
+            document.querySelectorAll('#serverConfigForm .transfer-limits').forEach(el => {
+                el.style.display = type === 'git' ? 'none' : '';
+            });
+            // Git目标不使用远程路径
+            document.querySelector('#serverConfigForm .path-field').style.display = type === 'git' ? 'none' : '';
+            
+            const placeholders = {
+                ssh: '/var/www/html',
+                local: '/mnt/www/site',
+                s3: 'site/',
+                git: ''
+            };
+            document.getElementById('serverRemotePath').placeholder = placeholders[type];
+        }
+        
+        // 服务器列表中显示的部署目标
+        function serverTargetLabel(server) {
+            if (server.type === 'local') {
+                return server.remote_path;
+            }
+            if (server.type === 's3') {
+                return 's3://' + server.s3_bucket + '/' + (server.remote_path || '');
+            }
+            if (server.type === 'git') {
+                return server.git_remote + '#' + (server.git_branch || 'gh-pages');
+            }
+            return server.host + ':' + server.port;
+        }
+        
+        // 保存服务器配置
+        function saveServerConfig() {
+            const formData = new FormData(document.getElementById('serverConfigForm'));
+            const serverData = {
+                name: formData.get('name'),
+                domain: formData.get('domain'),
+                environment: formData.get('environment'),
+                group: formData.get('group').trim(),
+                tags: formData.get('tags').split(',').map(tag => tag.trim()).filter(tag => tag !== ''),
+                smoke_test: formData.get('smoke_test') === 'on',
+                smoke_test_action: formData.get('smoke_test_action'),
+                smoke_test_samples: parseInt(formData.get('smoke_test_samples')) || 0,
+                type: formData.get('type'),
+                s3_endpoint: formData.get('s3_endpoint'),
+                s3_bucket: formData.get('s3_bucket'),
+                s3_region: formData.get('s3_region'),
+                s3_access_key: formData.get('s3_access_key'),
+                s3_secret_key: formData.get('s3_secret_key'),
+                git_remote: formData.get('git_remote'),
+                git_branch: formData.get('git_branch'),
+                host: formData.get('host'),
+                port: parseInt(formData.get('port')),
+                username: formData.get('username'),
+                remote_path: formData.get('remote_path'),
+                transfer_mode: formData.get('transfer_mode'),
+                upload_workers: parseInt(formData.get('upload_workers')) || 0,
+                bandwidth_limit: (parseInt(formData.get('bandwidth_limit')) || 0) * 1024,
+                file_mode: formData.get('file_mode').trim(),
+                dir_mode: formData.get('dir_mode').trim(),
+                file_group: formData.get('file_group').trim(),
+                mirror_deletions: formData.get('mirror_deletions') === 'on',
+                release_mode: formData.get('release_mode') === 'on',
+                keep_releases: parseInt(formData.get('keep_releases')) || 0,
+                snapshot_mode: formData.get('snapshot_mode'),
+                keep_snapshots: parseInt(formData.get('keep_snapshots')) || 0,
+                enabled: formData.get('enabled') === 'on'
+            };
+            
+            // 受保护路径为空时使用服务端默认值
+            const protectedPaths = formData.get('protected_paths').split('\n')
+                .map(line => line.trim())
+                .filter(line => line !== '');
+            if (protectedPaths.length > 0) {
+                serverData.protected_paths = protectedPaths;
+            }
+            
+            // 部署排除和包含规则，保留注释行以外的每一行
+            const parsePatterns = text => text.split('\n')
+                .map(line => line.trim())
+                .filter(line => line !== '' && !line.startsWith('#'));
+            serverData.exclude_patterns = parsePatterns(formData.get('exclude_patterns'));
+            serverData.include_patterns = parsePatterns(formData.get('include_patterns'));
+            
+            // 部署前后命令：每行一条，共用同一个超时
+            const hookTimeout = parseInt(formData.get('hook_timeout')) || 0;
+            const parseHooks = text => text.split('\n')
+                .map(line => line.trim())
+                .filter(line => line !== '')
+                .map(command => ({ command: command, timeout: hookTimeout }));
+            serverData.pre_deploy_hooks = parseHooks(formData.get('pre_deploy_hooks'));
+            serverData.post_deploy_hooks = parseHooks(formData.get('post_deploy_hooks'));
+            
+            const authMethod = formData.get('auth_method');
+            if (authMethod === 'password') {
+                serverData.password = formData.get('password');
+            } else {
+                serverData.key_path = formData.get('key_path');
+                serverData.key_passphrase = formData.get('key_passphrase');
+            }
+            serverData.use_agent = formData.get('use_agent') === 'on';
+            serverData.jump_server_id = formData.get('jump_server_id');
+            serverData.master_password = formData.get('master_password');
+            
+            const serverId = formData.get('server_id');
+            const url = serverId ? '/api/multi-deploy/server/' + serverId : '/api/multi-deploy/server';
+            const method = serverId ? 'PUT' : 'POST';
+            
+            fetch(url, {
+                method: method,
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify(serverData)
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.error) {
+                    alert('保存失败: ' + data.error);
+                    return;
+                }
+                
+                alert(data.message);
+                serverConfigModal.hide();
+                loadServerList(); // 重新加载服务器列表
+            })
+            .catch(error => {
+                alert('保存失败: ' + error.message);
+            });
+        }
+        
+        // 删除服务器
+        function deleteServer(serverId) {
+            if (!confirm('确定要删除这个服务器配置吗？')) {
+                return;
+            }
+            
+            fetch('/api/multi-deploy/server/' + serverId, {
+                method: 'DELETE'
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.error) {
+                    alert('删除失败: ' + data.error);
+                    return;
+                }
+                
+                alert(data.message);
+                loadServerList(); // 重新加载服务器列表
+            })
+            .catch(error => {
+                alert('删除失败: ' + error.message);
+            });
+        }
+        
+        // 测试连接
+        function testConnection(serverId) {
+            const btn = event.target.closest('button');
+            btn.disabled = true;
+            btn.innerHTML = '<i class="bi bi-hourglass-split"></i>';
+            
+            fetch('/api/multi-deploy/test/' + serverId, {
+                method: 'POST'
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.host_key_mismatch) {
+                    confirmHostKeyChange(serverId, data);
+                } else if (data.error) {
+                    alert('连接测试失败: ' + data.error);
+                } else {
+                    alert('连接测试成功');
+                }
+            })
+            .catch(error => {
+                alert('连接测试失败: ' + error.message);
+            })
+            .finally(() => {
+                btn.disabled = false;
+                btn.innerHTML = '<i class="bi bi-wifi"></i>';
+            });
+        }
+        
+        // 主机密钥变化时由用户核对并确认
+        function confirmHostKeyChange(serverId, data) {
+            const confirmMsg = '警告：服务器的主机密钥已变化，可能存在中间人攻击！\n\n' +
+                '已信任的指纹: ' + data.expected_fingerprint + '\n' +
+                '当前服务器指纹: ' + data.actual_fingerprint + '\n\n' +
+                '请通过其他渠道核对新指纹。确定要信任新的主机密钥吗？';
+            if (!confirm(confirmMsg)) {
+                addToLog('WARNING: 主机密钥不匹配，已拒绝连接', 'warning');
+                return;
+            }
+            
+            fetch('/api/multi-deploy/host-key/' + serverId + '/accept', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ fingerprint: data.actual_fingerprint })
+            })
+            .then(response => response.json())
+            .then(result => {
+                if (result.error) {
+                    alert('信任主机密钥失败: ' + result.error);
+                    return;
+                }
+                addToLog('INFO: 已信任新的主机密钥 ' + result.fingerprint, 'info');
+                showNotification(result.message, 'success');
+            })
+            .catch(error => {
+                alert('信任主机密钥失败: ' + error.message);
+            });
+        }
+        
+        // 回滚到上一个版本
+        function rollbackServer(serverId) {
+            if (!confirm('确定要将网站回滚到上一个版本吗？')) {
+                return;
+            }
+            
+            fetch('/api/multi-deploy/rollback/' + serverId, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({})
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.host_key_mismatch) {
+                    confirmHostKeyChange(serverId, data);
+                    return;
+                }
+                if (data.locked) {
+                    handleDeployLocked(data);
+                    return;
+                }
+                if (data.error) {
+                    alert('回滚失败: ' + data.error);
+                    return;
+                }
+                showNotification(data.message, 'success');
+                refreshServerStatuses();
+            })
+            .catch(error => {
+                alert('回滚失败: ' + error.message);
+            });
+        }
+        
+        // 显示服务器的快照列表
+        function showSnapshotModal(serverId) {
+            if (!snapshotModal) {
+                snapshotModal = new bootstrap.Modal(document.getElementById('snapshotModal'));
+            }
+            const name = document.querySelector('#server-row-' + serverId + ' strong');
+            document.getElementById('snapshotServerId').value = serverId;
+            document.getElementById('snapshotServerName').textContent = name ? name.textContent : serverId;
+            loadSnapshots();
+            snapshotModal.show();
+        }
+        
+        // 加载快照列表
+        function loadSnapshots() {
+            const serverId = document.getElementById('snapshotServerId').value;
+            const tbody = document.getElementById('snapshotTableBody');
+            
+            fetch('/api/multi-deploy/snapshots/' + serverId)
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        tbody.innerHTML = '';
+                        alert('加载快照失败: ' + data.error);
                         return;
                     }
                     
-                    const server = data.server;
-                    document.getElementById('serverId').value = server.id;
-                    document.getElementById('serverName').value = server.name;
-                    document.getElementById('serverDomain').value = server.domain || '';
-                    document.getElementById('serverHost').value = server.host;
-                    document.getElementById('serverPort').value = server.port;
-                    document.getElementById('serverUsername').value = server.username;
-                    document.getElementById('serverPassword').value = '';
-                    document.getElementById('serverKeyPath').value = server.key_path || '';
-                    document.getElementById('serverRemotePath').value = server.remote_path;
-                    document.getElementById('serverEnabled').checked = server.enabled;
-                    
-                    if (server.key_path) {
-                        document.getElementById('authKey').checked = true;
-                        document.getElementById('keyAuth').style.display = 'block';
-                        document.getElementById('passwordAuth').style.display = 'none';
-                    } else {
-                        document.getElementById('authPassword').checked = true;
-                        document.getElementById('passwordAuth').style.display = 'block';
-                        document.getElementById('keyAuth').style.display = 'none';
+                    const snapshots = data.snapshots || [];
+                    tbody.innerHTML = '';
+                    if (snapshots.length === 0) {
+                        tbody.innerHTML = '<tr><td colspan="5" class="text-muted text-center">暂无快照</td></tr>';
+                        return;
                     }
                     
-                    serverConfigModal.show();
+                    snapshots.forEach(snapshot => {
+                        const row = document.createElement('tr');
+                        [
+                            snapshot.id + (snapshot.manual ? '（手动）' : ''),
+                            snapshot.location === 'local' ? '本地' : snapshot.remote_file,
+                            formatArtifactSize(snapshot.size),
+                            new Date(snapshot.created_at).toLocaleString()
+                        ].forEach(text => {
+                            const cell = document.createElement('td');
+                            cell.textContent = text;
+                            row.appendChild(cell);
+                        });
+                        
+                        const actions = document.createElement('td');
+                        actions.className = 'text-nowrap';
+                        actions.innerHTML = `
+                            <button class="btn btn-sm btn-outline-warning" onclick="restoreSnapshot('${snapshot.id}')" title="恢复"><i class="bi bi-arrow-counterclockwise"></i></button>
+                            <a class="btn btn-sm btn-outline-primary" href="/api/multi-deploy/snapshots/${serverId}/${snapshot.id}/download" title="下载"><i class="bi bi-download"></i></a>
+                            <button class="btn btn-sm btn-outline-danger" onclick="deleteSnapshot('${snapshot.id}')" title="删除"><i class="bi bi-trash"></i></button>
+                        `;
+                        row.appendChild(actions);
+                        tbody.appendChild(row);
+                    });
                 })
                 .catch(error => {
-                    alert('获取服务器配置失败: ' + error.message);
+                    console.error('加载快照失败:', error);
                 });
         }
         
-        // 保存服务器配置
-        function saveServerConfig() {
-            const formData = new FormData(document.getElementById('serverConfigForm'));
-            const serverData = {
-                name: formData.get('name'),
-                domain: formData.get('domain'),
-                host: formData.get('host'),
-                port: parseInt(formData.get('port')),
-                username: formData.get('username'),
-                remote_path: formData.get('remote_path'),
-                enabled: formData.get('enabled') === 'on'
-            };
-            
-            const authMethod = formData.get('auth_method');
-            if (authMethod === 'password') {
-                serverData.password = formData.get('password');
-            } else {
-                serverData.key_path = formData.get('key_path');
+        // 立即创建快照
+        function createSnapshot() {
+            const serverId = document.getElementById('snapshotServerId').value;
+            addToLog('INFO: 正在创建快照...', 'info');
+            fetch('/api/multi-deploy/snapshots/' + serverId, {
+                method: 'POST'
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.host_key_mismatch) {
+                    confirmHostKeyChange(serverId, data);
+                    return;
+                }
+                if (data.locked) {
+                    handleDeployLocked(data);
+                    return;
+                }
+                if (data.error) {
+                    addToLog('ERROR: ' + data.error, 'error');
+                    alert('创建快照失败: ' + data.error);
+                    return;
+                }
+                addToLog('SUCCESS: 快照已创建 ' + data.snapshot.id, 'success');
+                showNotification(data.message, 'success');
+                loadSnapshots();
+            })
+            .catch(error => {
+                alert('创建快照失败: ' + error.message);
+            });
+        }
+        
+        // 用快照替换远程路径的内容
+        function restoreSnapshot(snapshotId) {
+            const serverId = document.getElementById('snapshotServerId').value;
+            if (!confirm('确定要用快照 ' + snapshotId + ' 替换远程路径下的所有文件吗？')) {
+                return;
             }
             
-            const serverId = formData.get('server_id');
-            const url = serverId ? '/api/multi-deploy/server/' + serverId : '/api/multi-deploy/server';
-            const method = serverId ? 'PUT' : 'POST';
-            
-            fetch(url, {
-                method: method,
-                headers: {
-                    'Content-Type': 'application/json',
-                },
-                body: JSON.stringify(serverData)
+            addToLog('INFO: 正在恢复快照 ' + snapshotId + '...', 'info');
+            fetch('/api/multi-deploy/snapshots/' + serverId + '/' + snapshotId + '/restore', {
+                method: 'POST'
             })
             .then(response => response.json())
             .then(data => {
+                if (data.host_key_mismatch) {
+                    confirmHostKeyChange(serverId, data);
+                    return;
+                }
+                if (data.locked) {
+                    handleDeployLocked(data);
+                    return;
+                }
                 if (data.error) {
-                    alert('保存失败: ' + data.error);
+                    addToLog('ERROR: ' + data.error, 'error');
+                    alert('恢复快照失败: ' + data.error);
                     return;
                 }
-                
-                alert(data.message);
-                serverConfigModal.hide();
-                loadServerList(); // 重新加载服务器列表
+                addToLog('SUCCESS: ' + data.message, 'success');
+                showNotification(data.message, 'success');
+                refreshServerStatuses();
             })
             .catch(error => {
-                alert('保存失败: ' + error.message);
+                alert('恢复快照失败: ' + error.message);
             });
         }
         
-        // 删除服务器
-        function deleteServer(serverId) {
-            if (!confirm('确定要删除这个服务器配置吗？')) {
+        // 删除快照
+        function deleteSnapshot(snapshotId) {
+            const serverId = document.getElementById('snapshotServerId').value;
+            if (!confirm('确定要删除快照 ' + snapshotId + ' 吗？')) {
                 return;
             }
             
-            fetch('/api/multi-deploy/server/' + serverId, {
+            fetch('/api/multi-deploy/snapshots/' + serverId + '/' + snapshotId, {
                 method: 'DELETE'
             })
             .then(response => response.json())
             .then(data => {
+                if (data.host_key_mismatch) {
+                    confirmHostKeyChange(serverId, data);
+                    return;
+                }
                 if (data.error) {
-                    alert('删除失败: ' + data.error);
+                    alert('删除快照失败: ' + data.error);
                     return;
                 }
-                
-                alert(data.message);
-                loadServerList(); // 重新加载服务器列表
+                showNotification(data.message, 'success');
+                loadSnapshots();
             })
             .catch(error => {
-                alert('删除失败: ' + error.message);
+                alert('删除快照失败: ' + error.message);
             });
         }
         
-        // 测试连接
-        function testConnection(serverId) {
-            const btn = event.target.closest('button');
-            btn.disabled = true;
-            btn.innerHTML = '<i class="bi bi-hourglass-split"></i>';
+        // 修复远程文件和目录的权限和属组
+        function repairServerPermissions(serverId) {
+            if (!confirm('确定要将远程路径下所有文件和目录的权限修复为服务器配置的值吗？')) {
+                return;
+            }
             
-            fetch('/api/multi-deploy/test/' + serverId, {
+            addToLog('INFO: 正在修复远程文件权限...', 'info');
+            fetch('/api/multi-deploy/repair-permissions/' + serverId, {
                 method: 'POST'
             })
             .then(response => response.json())
             .then(data => {
+                if (data.host_key_mismatch) {
+                    confirmHostKeyChange(serverId, data);
+                    return;
+                }
+                if (data.locked) {
+                    handleDeployLocked(data);
+                    return;
+                }
                 if (data.error) {
-                    alert('连接测试失败: ' + data.error);
-                } else {
-                    alert('连接测试成功');
+                    addToLog('ERROR: ' + data.error, 'error');
+                    alert('修复权限失败: ' + data.error);
+                    return;
                 }
+                addToLog('SUCCESS: ' + data.message, 'success');
+                showNotification(data.message, 'success');
             })
             .catch(error => {
-                alert('连接测试失败: ' + error.message);
+                alert('修复权限失败: ' + error.message);
+            });
+        }
+        
+        // 预览镜像删除（不会删除任何文件）
+        function previewMirrorDeletions() {
+            const serverId = document.getElementById('serverId').value;
+            if (!serverId) {
+                alert('请先保存服务器配置');
+                return;
+            }
+            
+            fetch('/api/multi-deploy/mirror-preview/' + serverId)
+                .then(response => response.json())
+                .then(data => {
+                    if (data.host_key_mismatch) {
+                        confirmHostKeyChange(serverId, data);
+                        return;
+                    }
+                    if (data.error) {
+                        alert('预览失败: ' + data.error);
+                        return;
+                    }
+                    
+                    const maxShown = 50;
+                    let message = '没有需要删除的远程文件';
+                    if (data.count > 0) {
+                        message = '镜像删除将删除以下 ' + data.count + ' 个远程文件：\n\n' +
+                            data.to_delete.slice(0, maxShown).join('\n');
+                        if (data.count > maxShown) {
+                            message += '\n... 以及其他 ' + (data.count - maxShown) + ' 个文件';
+                        }
+                    }
+                    if (data.protected.length > 0) {
+                        message += '\n\n受保护或被排除而保留的文件: ' + data.protected.length + ' 个';
+                    }
+                    if (data.excluded.length > 0) {
+                        message += '\n\n排除规则跳过、不会上传的本地文件: ' + data.excluded.length + ' 个\n' +
+                            data.excluded.slice(0, 10).join('\n');
+                        if (data.excluded.length > 10) {
+                            message += '\n...';
+                        }
+                    }
+                    alert(message);
+                })
+                .catch(error => {
+                    alert('预览失败: ' + error.message);
+                });
+        }
+        
+        // 加载服务器主机密钥信息
+        function loadHostKeyInfo(serverId) {
+            const fingerprintEl = document.getElementById('serverHostKeyFingerprint');
+            if (!serverId) {
+                fingerprintEl.value = '';
+                return;
+            }
+            
+            fetch('/api/multi-deploy/host-key/' + serverId)
+                .then(response => response.json())
+                .then(data => {
+                    fingerprintEl.value = data.fingerprint || '';
+                })
+                .catch(error => {
+                    console.error('获取主机密钥失败:', error);
+                });
+        }
+        
+        // 从known_hosts导入主机密钥
+        function importKnownHost() {
+            const serverId = document.getElementById('serverId').value;
+            if (!serverId) {
+                alert('请先保存服务器配置');
+                return;
+            }
+            
+            const path = prompt('known_hosts 文件路径（留空使用 ~/.ssh/known_hosts）', '');
+            if (path === null) {
+                return;
+            }
+            
+            fetch('/api/multi-deploy/host-key/' + serverId + '/import', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ path: path })
             })
-            .finally(() => {
-                btn.disabled = false;
-                btn.innerHTML = '<i class="bi bi-wifi"></i>';
+            .then(response => response.json())
+            .then(data => {
+                if (data.error) {
+                    alert('导入失败: ' + data.error);
+                    return;
+                }
+                document.getElementById('serverHostKeyFingerprint').value = data.fingerprint;
+                showNotification(data.message, 'success');
+            })
+            .catch(error => {
+                alert('导入失败: ' + error.message);
             });
         }
         
@@ -417,6 +1901,24 @@
             updateServerAction(serverId, 'resume', '正在继续...');
         }
         
+        // 检查程序退出时中断的部署，询问是否继续
+        function checkResumableDeployments() {
+            fetch('/api/multi-deploy/resumable')
+                .then(response => response.json())
+                .then(data => {
+                    const interrupted = (data.deployments || []).filter(d => d.server_id && d.interrupted);
+                    if (interrupted.length === 0) return;
+                    
+                    const list = interrupted.map(d => `- ${d.server_name}（剩余 ${d.pending}/${d.total} 个文件）`).join('\n');
+                    if (confirm(`发现 ${interrupted.length} 个在程序退出时中断的部署：\n${list}\n\n是否现在继续上传？`)) {
+                        interrupted.forEach(d => resumeDeployment(d.server_id));
+                    }
+                })
+                .catch(error => {
+                    console.error('检查中断的部署失败:', error);
+                });
+        }
+        
         // 停止部署
         function stopDeployment(serverId) {
             updateServerAction(serverId, 'stop', '正在停止...');
@@ -429,6 +1931,10 @@
             })
             .then(response => response.json())
             .then(data => {
+                if (data.locked) {
+                    handleDeployLocked(data, () => updateServerAction(serverId, action, message));
+                    return;
+                }
                 if (data.error) {
                     alert(action + '失败: ' + data.error);
                     return;
@@ -449,6 +1955,47 @@
             });
         }
         
+        // 服务器或构建已被锁定：显示持有者信息，持有锁的程序已退出时可以强制释放后重试
+        function handleDeployLocked(data, retry) {
+            const lock = data.lock || {};
+            let details = '操作：' + (lock.operation || '-');
+            if (lock.acquired_at) details += '\n开始时间：' + new Date(lock.acquired_at).toLocaleString();
+            if (lock.triggered_by) details += '\n触发者：' + lock.triggered_by;
+            if (data.job) {
+                details += '\n批量部署任务：' + data.job.id + '（' + data.job.message + '）';
+            } else if (lock.deployment_id) {
+                details += '\n部署记录：' + lock.deployment_id;
+            }
+            
+            if (!lock.stale) {
+                alert(data.error + '\n\n' + details);
+                return;
+            }
+            if (!confirm(data.error + '\n\n' + details + '\n\n确定要强制释放该锁吗？')) {
+                return;
+            }
+            
+            fetch('/api/multi-deploy/locks/' + encodeURIComponent(lock.key), {
+                method: 'DELETE'
+            })
+            .then(response => response.json())
+            .then(result => {
+                if (result.error) {
+                    alert('释放部署锁失败: ' + result.error);
+                    return;
+                }
+                showNotification(result.message, 'success');
+                if (retry) {
+                    retry();
+                } else {
+                    refreshServerStatuses();
+                }
+            })
+            .catch(error => {
+                alert('释放部署锁失败: ' + error.message);
+            });
+        }
+        
         // 更新部署控制按钮显示状态
         function updateDeployControlButtons(serverId, action) {
             const row = document.getElementById('server-row-' + serverId);
@@ -499,6 +2046,7 @@
                     } else {
                         showEmptyServerTable();
                     }
+                    updateBatchSelector(data.groups || [], data.tags || []);
                 })
                 .catch(error => {
                     console.error('加载服务器列表失败:', error);
@@ -530,6 +2078,7 @@
                         <div>
                             <strong>${server.name}</strong>
                             ${!server.enabled ? '<span class="badge bg-secondary ms-2">已禁用</span>' : ''}
+                            ${serverLabelsHtml(server)}
                         </div>
                     </div>
                 </td>
@@ -542,11 +2091,12 @@
                     }
                 </td>
                 <td>
-                    <code>${server.host}:${server.port}</code>
+                    <code>${serverTargetLabel(server)}</code>
                 </td>
                 <td>
                     <span class="badge status-badge bg-secondary">空闲</span>
                     <div class="small text-muted mt-1">等待部署</div>
+                    ${server.release_mode ? '<div class="small text-muted release-info"></div>' : ''}
                 </td>
                 <td>
                     <div class="progress-container">
@@ -604,6 +2154,33 @@
                                 <i class="bi bi-stop-fill"></i>
                             </button>
                         </div>
+                        
+                        ${server.release_mode ? `
+                        <!-- 回滚按钮 -->
+                        <button class="btn btn-sm btn-outline-warning" 
+                                onclick="rollbackServer('${server.id}')" 
+                                title="回滚到上一个版本">
+                            <i class="bi bi-arrow-counterclockwise"></i>
+                        </button>
+                        ` : ''}
+                        
+                        ${!server.release_mode ? `
+                        <!-- 快照按钮 -->
+                        <button class="btn btn-sm btn-outline-secondary" 
+                                onclick="showSnapshotModal('${server.id}')" 
+                                title="快照">
+                            <i class="bi bi-archive"></i>
+                        </button>
+                        ` : ''}
+                        
+                        ${server.file_mode || server.dir_mode || server.file_group ? `
+                        <!-- 修复权限按钮 -->
+                        <button class="btn btn-sm btn-outline-secondary" 
+                                onclick="repairServerPermissions('${server.id}')" 
+                                title="修复远程文件权限">
+                            <i class="bi bi-shield-check"></i>
+                        </button>
+                        ` : ''}
                         ` : ''}
                         
                         <!-- 删除按钮 -->
@@ -642,8 +2219,10 @@
                     if (data.statuses) {
                         Object.keys(data.statuses).forEach(serverId => {
                             updateServerRow(serverId, data.statuses[serverId]);
+                            updateButtonsBasedOnStatus(serverId, data.statuses[serverId].status);
                         });
                     }
+                    renderGroupSummary(data.groups || []);
                 })
                 .catch(error => {
                     console.error('刷新状态失败:', error);
@@ -658,11 +2237,17 @@
             const statusIcon = row.querySelector('.status-icon');
             const statusBadge = row.querySelector('.status-badge');
             const progressContainer = row.querySelector('.progress-container');
+            const releaseInfo = row.querySelector('.release-info');
             
             if (statusIcon) {
                 statusIcon.className = 'status-icon status-' + status.status;
             }
             
+            if (releaseInfo) {
+                releaseInfo.innerHTML = status.current_release ?
+                    `<i class="bi bi-tag"></i> ${status.current_release}` : '';
+            }
+            
             if (statusBadge) {
                 statusBadge.className = 'badge status-badge bg-' + 
                     (status.status === 'success' ? 'success' : 
@@ -804,41 +2389,145 @@
                 return;
             }
             
-            const servers = getEnabledServers();
-            if (servers.length === 0) {
-                showNotification('没有启用的服务器', 'warning');
+            const selector = getBatchSelector();
+            const action = isIncremental ? '增量部署' : '全量部署';
+            if (!confirm(`确定要对${batchSelectorLabel()}进行${action}吗？`)) {
                 return;
             }
             
-            const action = isIncremental ? '增量部署' : '全量部署';
-            const confirmMsg = `确定要对 ${servers.length} 个服务器进行${action}吗？`;
-            
-            if (!confirm(confirmMsg)) {
+            fetch('/api/multi-deploy/deploy-all', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(Object.assign({ incremental: isIncremental }, selector))
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.locked) {
+                    handleDeployLocked(data, () => deployAllServers(isIncremental));
+                    return;
+                }
+                if (data.error) {
+                    alert('批量' + action + '失败: ' + data.error);
+                    return;
+                }
+                
+                addToLog(`INFO: ${data.message}`, 'info');
+                showNotification(`开始批量${action}`, 'info');
+                (data.job && data.job.servers || []).forEach(server => {
+                    updateDeployControlButtons(server.server_id, isIncremental ? 'incremental-deploy' : 'deploy');
+                });
+            })
+            .catch(error => {
+                alert('批量' + action + '失败: ' + error.message);
+            });
+        }
+        
+        // 批量测试连接、暂停或停止所选范围内的服务器
+        function bulkServerAction(action) {
+            const names = { 'test-all': '测试连接', 'pause-all': '暂停部署', 'stop-all': '停止部署' };
+            if (action === 'stop-all' && !confirm(`确定要停止${batchSelectorLabel()}正在进行的部署吗？`)) {
                 return;
             }
             
-            addToLog(`INFO: 开始批量${action}到 ${servers.length} 个服务器`, 'info');
-            showNotification(`开始批量${action}`, 'info');
-            
-            // 同时向所有服务器发起部署
-            servers.forEach(server => {
-                deployServer(server.id, isIncremental, false); // false = 不显示确认对话框
+            addToLog(`INFO: 正在对${batchSelectorLabel()}${names[action]}...`, 'info');
+            fetch('/api/multi-deploy/' + action, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(getBatchSelector())
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.error) {
+                    alert(names[action] + '失败: ' + data.error);
+                    return;
+                }
+                
+                (data.results || []).forEach(result => {
+                    addToLog(`${result.success ? 'SUCCESS' : 'ERROR'}: ${result.server_name} - ${result.message}`, result.success ? 'success' : 'error');
+                    if (action === 'stop-all') {
+                        updateDeployControlButtons(result.server_id, 'stop');
+                    }
+                });
+                showNotification(data.message, 'info');
+                refreshServerStatuses();
+            })
+            .catch(error => {
+                alert(names[action] + '失败: ' + error.message);
             });
         }
         
-        // 获取启用的服务器列表
-        function getEnabledServers() {
-            // 从当前显示的表格中获取启用的服务器
-            const enabledServers = [];
-            document.querySelectorAll('.server-row').forEach(row => {
-                const serverId = row.id.replace('server-row-', '');
-                const isDisabled = row.querySelector('.badge.bg-secondary');
-                if (!isDisabled) {
-                    const serverName = row.querySelector('strong').textContent;
-                    enabledServers.push({ id: serverId, name: serverName });
-                }
+        // 批量操作的服务器范围：选择框的值为 group:<分组> 或 tag:<标签>，为空表示所有服务器
+        function getBatchSelector() {
+            const value = document.getElementById('batchSelector').value;
+            if (value.startsWith('group:')) {
+                return { group: value.substring(6) };
+            }
+            if (value.startsWith('tag:')) {
+                return { tag: value.substring(4) };
+            }
+            return {};
+        }
+        
+        // 批量操作范围的说明
+        function batchSelectorLabel() {
+            const selector = getBatchSelector();
+            if (selector.group) return `分组 ${selector.group} 中的服务器`;
+            if (selector.tag) return `标签为 ${selector.tag} 的服务器`;
+            return '所有启用的服务器';
+        }
+        
+        // 更新批量操作范围选项和分组输入提示
+        function updateBatchSelector(groups, tags) {
+            const select = document.getElementById('batchSelector');
+            const current = select.value;
+            while (select.options.length > 1) {
+                select.remove(1);
+            }
+            select.querySelectorAll('optgroup').forEach(group => group.remove());
+            
+            [['分组', 'group', groups], ['标签', 'tag', tags]].forEach(([label, prefix, values]) => {
+                if (values.length === 0) return;
+                const optgroup = document.createElement('optgroup');
+                optgroup.label = label;
+                values.forEach(value => optgroup.appendChild(new Option(value, prefix + ':' + value)));
+                select.appendChild(optgroup);
             });
-            return enabledServers;
+            select.value = current;
+            if (select.value !== current) {
+                select.value = '';
+            }
+            
+            document.getElementById('serverGroupOptions').innerHTML = groups
+                .map(group => `<option value="${group}"></option>`).join('');
+        }
+        
+        // 服务器的分组和标签
+        function serverLabelsHtml(server) {
+            const tags = server.tags || [];
+            if (!server.group && tags.length === 0) return '';
+            return `<div class="server-labels">
+                ${server.group ? `<span class="badge bg-info text-dark"><i class="bi bi-collection"></i> ${server.group}</span>` : ''}
+                ${tags.map(tag => `<span class="badge bg-light text-dark border">${tag}</span>`).join(' ')}
+            </div>`;
+        }
+        
+        // 显示按分组汇总的部署状态（只有一个未分组的汇总时不显示）
+        function renderGroupSummary(groups) {
+            const container = document.getElementById('groupStatusSummary');
+            if (!container) return;
+            if (groups.length === 0 || (groups.length === 1 && !groups[0].group)) {
+                container.innerHTML = '';
+                return;
+            }
+            
+            const colors = { deploying: 'bg-primary', failed: 'bg-danger', paused: 'bg-warning text-dark', success: 'bg-success', idle: 'bg-light text-dark border' };
+            container.innerHTML = groups.map(group => {
+                const details = Object.keys(group.statuses).map(status => `${status}: ${group.statuses[status]}`).join(', ');
+                const success = group.statuses.success || 0;
+                return `<span class="badge ${colors[group.status] || colors.idle}" title="${details}">
+                    ${group.group || '未分组'} ${success}/${group.total}
+                </span>`;
+            }).join('');
         }
         
         // Hugo Serve 控制函数
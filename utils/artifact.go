@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// 站点目录的统计信息
+type SiteStats struct {
+	Digest string `json:"digest"` // 所有文件相对路径和内容哈希的SHA-256
+	Files  int    `json:"files"`
+	Size   int64  `json:"size"`
+}
+
+// 计算站点目录的摘要：相同的文件和内容得到相同的摘要，用于确认提升的构件与原构件一致
+func ComputeSiteStats(dir string) (SiteStats, error) {
+	var stats SiteStats
+	hashes := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		hash, err := HashFile(path)
+		if err != nil {
+			return err
+		}
+		hashes[filepath.ToSlash(relPath)] = hash
+		stats.Files++
+		stats.Size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	paths := make([]string, 0, len(hashes))
+	for relPath := range hashes {
+		paths = append(paths, relPath)
+	}
+	sort.Strings(paths)
+
+	digest := sha256.New()
+	for _, relPath := range paths {
+		fmt.Fprintf(digest, "%s %s\n", hashes[relPath], relPath)
+	}
+	stats.Digest = hex.EncodeToString(digest.Sum(nil))
+	return stats, nil
+}
+
+// 复制站点目录，保留文件权限，目标目录不能已存在
+func CopySiteDir(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("目标目录已存在: %s", dst)
+	}
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relPath)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode().IsRegular():
+			return copySiteFile(path, target, info.Mode().Perm())
+		default:
+			// Hugo 输出中不会有符号链接等特殊文件
+			return nil
+		}
+	})
+}
+
+func copySiteFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
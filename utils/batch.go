@@ -0,0 +1,260 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"hugo-manager-go/config"
+)
+
+// 批量传输每批的文件数和字节数上限，暂停在批次之间生效
+const (
+	batchMaxFiles = 500
+	batchMaxBytes = 32 * 1024 * 1024
+)
+
+// 检查远程是否可以解压gzip压缩的tar流
+func (c *SSHClient) remoteSupportsTar() bool {
+	_, err := c.runRemoteCommand("command -v tar >/dev/null 2>&1 && command -v gzip >/dev/null 2>&1")
+	return err == nil
+}
+
+// 将任务分为多个批次；文件名包含换行符的文件无法校验、大文件需要分块续传，
+// 这些文件单独返回走逐个上传
+func splitBatches(tasks []FileTask) (batches [][]FileTask, single []FileTask) {
+	var (
+		batch     []FileTask
+		batchSize int64
+	)
+	for _, task := range tasks {
+		if strings.ContainsAny(task.RelPath, "\n\r") || task.Size >= chunkedUploadThreshold {
+			single = append(single, task)
+			continue
+		}
+		if len(batch) > 0 && (len(batch) >= batchMaxFiles || batchSize+task.Size > batchMaxBytes) {
+			batches = append(batches, batch)
+			batch, batchSize = nil, 0
+		}
+		batch = append(batch, task)
+		batchSize += task.Size
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches, single
+}
+
+// 将一批文件写成gzip压缩的tar流
+func writeBatchArchive(w io.Writer, batch []FileTask) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, task := range batch {
+		if err := addFileToArchive(tw, task); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addFileToArchive(tw *tar.Writer, task FileTask) error {
+	file, err := os.Open(task.LocalFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = task.RelPath
+	header.Uid, header.Gid = 0, 0
+	header.Uname, header.Gname = "", ""
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// 以tar流上传一批文件并在远程解压
+func (c *SSHClient) uploadBatch(remotePath string, batch []FileTask) error {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	var stderr strings.Builder
+	session.Stderr = &stderr
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("创建stdin管道失败: %v", err)
+	}
+
+	// -o: 不还原文件属主，解压后属于登录用户
+	cmd := fmt.Sprintf("mkdir -p %s && tar -xzo -f - -C %s", shellQuote(remotePath), shellQuote(remotePath))
+	if err := session.Start(cmd); err != nil {
+		return fmt.Errorf("启动解压命令失败: %v", err)
+	}
+
+	// 压缩在单独的goroutine中进行，通过管道限速写入远程
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeBatchArchive(writer, batch))
+	}()
+
+	_, err = io.Copy(stdin, newThrottledReader(reader, c.limiter, c.meter))
+	reader.Close()
+	stdin.Close()
+	waitErr := session.Wait()
+	if err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		if stderr.Len() > 0 {
+			return fmt.Errorf("批量上传失败: %v, 错误输出: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("批量上传失败: %v", err)
+	}
+	return nil
+}
+
+// 校验一批文件的远程大小，返回大小不一致或不存在的文件
+func (c *SSHClient) verifyBatch(remotePath string, batch []FileTask) ([]FileTask, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	var input strings.Builder
+	for _, task := range batch {
+		input.WriteString(task.RelPath)
+		input.WriteByte('\n')
+	}
+	session.Stdin = strings.NewReader(input.String())
+
+	cmd := fmt.Sprintf(`cd %s && while IFS= read -r f; do if [ -f "$f" ]; then wc -c < "$f"; else echo -1; fi; done`,
+		shellQuote(remotePath))
+	output, err := session.Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("校验上传结果失败: %v", err)
+	}
+
+	sizes := strings.Fields(string(output))
+	if len(sizes) != len(batch) {
+		return nil, fmt.Errorf("校验上传结果失败: 期望 %d 个结果，实际 %d 个", len(batch), len(sizes))
+	}
+
+	var mismatched []FileTask
+	for i, task := range batch {
+		size, err := strconv.ParseInt(sizes[i], 10, 64)
+		if err != nil || size != task.Size {
+			mismatched = append(mismatched, task)
+		}
+	}
+	return mismatched, nil
+}
+
+// 批量传输：每批文件打包为gzip压缩的tar流，远程解压后校验
+// 远程没有tar时退回逐个上传；某一批失败或校验不一致的文件也会改为逐个上传
+func (c *SSHClient) transferFilesBatched(ctx context.Context, remotePath string, tasks []FileTask, serverID, serverName string) error {
+	if !c.remoteSupportsTar() {
+		fmt.Println("远程服务器没有tar命令，改用逐个上传")
+		return c.transferFilesConcurrentlyWithServer(ctx, tasks, serverID, serverName)
+	}
+
+	batches, fallback := splitBatches(tasks)
+	totalTasks := len(tasks)
+	completed := 0
+
+	for i, batch := range batches {
+		// 暂停在批次之间生效，已完成的批次不会重新上传
+		if config.IsServerDeploymentPaused(c.serverID) {
+			if serverID != "" && serverName != "" {
+				BroadcastMultiServerPause(serverID, serverName, "上传已暂停", completed*100/totalTasks, totalTasks, completed)
+			} else {
+				BroadcastPause("上传已暂停", completed*100/totalTasks, totalTasks, completed)
+			}
+			return ErrDeploymentPaused
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		BroadcastTransferProgress(serverID, serverName,
+			fmt.Sprintf("正在批量上传第 %d/%d 批 (%d 个文件)", i+1, len(batches), len(batch)),
+			completed*100/totalTasks, totalTasks, completed, "", "")
+
+		if err := c.uploadBatch(remotePath, batch); err != nil {
+			fmt.Printf("第 %d 批上传失败，改为逐个上传: %v\n", i+1, err)
+			fallback = append(fallback, batch...)
+			continue
+		}
+
+		if err := c.applyBatchPermissions(remotePath, batch); err != nil {
+			fmt.Printf("第 %d 批设置权限失败，改为逐个上传: %v\n", i+1, err)
+			fallback = append(fallback, batch...)
+			continue
+		}
+
+		mismatched, err := c.verifyBatch(remotePath, batch)
+		if err != nil {
+			fmt.Printf("第 %d 批校验失败，改为逐个上传: %v\n", i+1, err)
+			fallback = append(fallback, batch...)
+			continue
+		}
+
+		failed := make(map[string]bool, len(mismatched))
+		for _, task := range mismatched {
+			fmt.Printf("批量上传校验不一致，改为逐个上传: %s\n", task.RelPath)
+			failed[task.RemoteFile] = true
+		}
+		fallback = append(fallback, mismatched...)
+
+		var uploaded []FileTask
+		for _, task := range batch {
+			if !failed[task.RemoteFile] {
+				uploaded = append(uploaded, task)
+			}
+		}
+		c.markTasksCompleted(uploaded)
+		completed += len(uploaded)
+
+		speed := c.meter.sample()
+		if serverID != "" {
+			config.SetServerDeploymentSpeed(serverID, speed)
+		}
+		BroadcastTransferProgress(serverID, serverName,
+			fmt.Sprintf("已完成 %d/%d 文件", completed, totalTasks),
+			completed*100/totalTasks, totalTasks, completed, "", speed)
+	}
+
+	if len(fallback) == 0 {
+		if serverID != "" && serverName != "" {
+			BroadcastMultiServerComplete(serverID, serverName, "deploy", fmt.Sprintf("上传完成: 成功 %d 个", completed), totalTasks)
+		} else {
+			BroadcastComplete("deploy", fmt.Sprintf("上传完成: 成功 %d 个", completed), totalTasks)
+		}
+		return nil
+	}
+	return c.transferFilesConcurrentlyWithServer(ctx, fallback, serverID, serverName)
+}
@@ -0,0 +1,263 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"hugo-manager-go/config"
+)
+
+// 大文件分块上传：每上传完一块就在上传任务中记录已确认的偏移，
+// 连接中断或暂停后继续部署时从该偏移处接着上传，而不是重新发送整个文件
+const (
+	chunkedUploadThreshold = 16 * 1024 * 1024 // 达到此大小的文件分块上传
+	uploadChunkSize        = 4 * 1024 * 1024  // 每块的大小
+)
+
+// 查找文件对应的上传任务记录
+func (c *SSHClient) findUploadTask(task FileTask) (config.UploadTask, bool) {
+	for _, uploadTask := range config.GetServerUploadTasks(c.serverID) {
+		if uploadTask.LocalFile == task.LocalFile && uploadTask.RemoteFile == task.RemoteFile {
+			return uploadTask, true
+		}
+	}
+	return config.UploadTask{}, false
+}
+
+// 远程文件的大小，文件不存在时返回 -1
+func (c *SSHClient) remoteFileSize(backend, remoteFile string) (int64, error) {
+	if backend == TransferModeSFTP {
+		info, err := c.sftpClient.Stat(remoteFile)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return -1, nil
+			}
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+
+	output, err := c.runRemoteCommand(fmt.Sprintf("if [ -f %s ]; then wc -c < %s; else echo -1; fi",
+		shellQuote(remoteFile), shellQuote(remoteFile)))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+}
+
+// 将远程临时文件截断到已确认的偏移（不存在时创建）
+func (c *SSHClient) truncateRemoteFile(backend, remoteFile string, offset int64) error {
+	if backend == TransferModeSFTP {
+		file, err := c.sftpClient.OpenFile(remoteFile, os.O_WRONLY|os.O_CREATE)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return file.Truncate(offset)
+	}
+
+	cmd := fmt.Sprintf(": > %s", shellQuote(remoteFile))
+	if offset > 0 {
+		cmd = fmt.Sprintf("dd if=/dev/null of=%s bs=1 seek=%d 2>/dev/null", shellQuote(remoteFile), offset)
+	}
+	_, err := c.runRemoteCommand(cmd)
+	return err
+}
+
+// 将本地文件 [offset, offset+size) 的内容追加写入远程临时文件
+func (c *SSHClient) writeChunk(backend, remoteFile string, localFile *os.File, offset, size int64) error {
+	chunk := newThrottledReader(io.NewSectionReader(localFile, offset, size), c.limiter, c.meter)
+
+	if backend == TransferModeSFTP {
+		file, err := c.sftpClient.OpenFile(remoteFile, os.O_WRONLY)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return err
+		}
+		written, err := io.Copy(file, chunk)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil && written != size {
+			err = fmt.Errorf("期望写入 %d 字节，实际写入 %d 字节", size, written)
+		}
+		return err
+	}
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	var stderr strings.Builder
+	session.Stderr = &stderr
+	session.Stdin = chunk
+
+	if err := session.Run(fmt.Sprintf("cat >> %s", shellQuote(remoteFile))); err != nil {
+		if stderr.Len() > 0 {
+			return c.analyzeUploadError(err, stderr.String(), remoteFile)
+		}
+		return err
+	}
+	return nil
+}
+
+// 计算远程文件的SHA-256：优先使用远程命令，不能执行命令时通过SFTP读回计算
+func (c *SSHClient) remoteChecksum(backend, remoteFile string) (string, error) {
+	quoted := shellQuote(remoteFile)
+	output, err := c.runRemoteCommand(fmt.Sprintf(
+		"sha256sum %s 2>/dev/null || shasum -a 256 %s 2>/dev/null || openssl dgst -sha256 -r %s", quoted, quoted, quoted))
+	if err == nil {
+		if fields := strings.Fields(output); len(fields) > 0 && len(fields[0]) == sha256.Size*2 {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+
+	if backend != TransferModeSFTP {
+		if err == nil {
+			err = errors.New("无法解析校验和")
+		}
+		return "", fmt.Errorf("远程无法计算校验和: %v", err)
+	}
+
+	file, err := c.sftpClient.Open(remoteFile)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// 分块上传大文件，完成后用SHA-256校验，再原子替换目标文件
+func (c *SSHClient) uploadChunked(task FileTask) error {
+	backend := TransferModeShell
+	if task.Backend == TransferModeSFTP && c.sftpClient != nil {
+		backend = TransferModeSFTP
+	}
+
+	localFile, err := os.Open(task.LocalFile)
+	if err != nil {
+		return err
+	}
+	defer localFile.Close()
+
+	localInfo, err := localFile.Stat()
+	if err != nil {
+		return err
+	}
+	localHash, err := HashFile(task.LocalFile)
+	if err != nil {
+		return err
+	}
+
+	remoteFile := strings.ReplaceAll(task.RemoteFile, "\\", "/")
+	remoteDir := path.Dir(remoteFile)
+	tmpFile := path.Join(remoteDir, "."+path.Base(remoteFile)+".uploading")
+
+	if err := c.makeRemoteDirectory(backend, remoteDir); err != nil {
+		return fmt.Errorf("无法创建远程目录 %s: %v", remoteDir, err)
+	}
+
+	// 本地文件与上次上传时相同，才能从记录的偏移继续
+	record, tracked := c.findUploadTask(task)
+	var offset int64
+	if tracked && record.Hash == localHash {
+		offset = record.Offset
+	}
+	// 以远程临时文件的实际大小校正偏移
+	remoteSize, err := c.remoteFileSize(backend, tmpFile)
+	if err != nil {
+		return fmt.Errorf("读取远程临时文件失败: %v", err)
+	}
+	if remoteSize < offset {
+		offset = max(remoteSize, 0)
+	}
+	if offset > localInfo.Size() {
+		offset = 0
+	}
+	if offset > 0 {
+		fmt.Printf("从 %d 字节处继续上传: %s\n", offset, task.RemoteFile)
+	}
+
+	if err := c.truncateRemoteFile(backend, tmpFile, offset); err != nil {
+		return c.analyzeUploadError(err, err.Error(), remoteFile)
+	}
+
+	for offset < localInfo.Size() {
+		if config.IsServerDeploymentPaused(c.serverID) {
+			return fmt.Errorf("%w，已上传 %d/%d 字节", ErrDeploymentPaused, offset, localInfo.Size())
+		}
+
+		size := min(int64(uploadChunkSize), localInfo.Size()-offset)
+		if err := c.writeChunk(backend, tmpFile, localFile, offset, size); err != nil {
+			return fmt.Errorf("上传分块失败（偏移 %d）: %v", offset, err)
+		}
+		offset += size
+
+		if tracked {
+			config.SetUploadTaskOffset(c.serverID, record.ID, offset, localHash)
+		}
+	}
+
+	// 校验整个文件的内容，不一致时丢弃临时文件，下次从头上传
+	remoteHash, err := c.remoteChecksum(backend, tmpFile)
+	if err != nil {
+		return fmt.Errorf("文件上传验证失败: %v", err)
+	}
+	if remoteHash != localHash {
+		c.runRemoteCommand("rm -f " + shellQuote(tmpFile))
+		if tracked {
+			config.SetUploadTaskOffset(c.serverID, record.ID, 0, "")
+		}
+		return fmt.Errorf("文件上传验证失败: 校验和不一致，期望 %s，实际 %s", localHash, remoteHash)
+	}
+
+	if backend == TransferModeSFTP {
+		if err := c.sftpClient.Chmod(tmpFile, c.remoteFileMode(localInfo.Mode())); err != nil && c.fileMode != 0 {
+			return fmt.Errorf("设置文件权限失败: %v", err)
+		}
+		if c.fileGroup != "" {
+			if _, err := c.runRemoteCommand(fmt.Sprintf("chgrp %s -- %s", shellQuote(c.fileGroup), shellQuote(tmpFile))); err != nil {
+				return fmt.Errorf("设置文件属组失败: %v", err)
+			}
+		}
+		c.sftpClient.Chtimes(tmpFile, localInfo.ModTime(), localInfo.ModTime())
+		if err := c.sftpClient.PosixRename(tmpFile, remoteFile); err != nil {
+			c.sftpClient.Remove(remoteFile)
+			if err := c.sftpClient.Rename(tmpFile, remoteFile); err != nil {
+				return c.analyzeUploadError(err, err.Error(), remoteFile)
+			}
+		}
+		return nil
+	}
+
+	cmd := fmt.Sprintf("chmod %o %s; ", localInfo.Mode().Perm(), shellQuote(tmpFile))
+	if c.hasPermissionPolicy() {
+		// 配置了权限策略时，设置失败即上传失败
+		cmd = fmt.Sprintf("chmod %o %s && ", c.remoteFileMode(localInfo.Mode()), shellQuote(tmpFile))
+		if c.fileGroup != "" {
+			cmd += fmt.Sprintf("chgrp %s -- %s && ", shellQuote(c.fileGroup), shellQuote(tmpFile))
+		}
+	}
+	cmd += fmt.Sprintf("mv -f %s %s", shellQuote(tmpFile), shellQuote(remoteFile))
+	if _, err := c.runRemoteCommand(cmd); err != nil {
+		return c.analyzeUploadError(err, err.Error(), remoteFile)
+	}
+	return c.setFileAttributes(remoteFile, localInfo.ModTime())
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"字段数不足", "0 0 * *"},
+		{"字段数过多", "0 0 * * * *"},
+		{"分钟超出范围", "60 * * * *"},
+		{"日期为0", "0 0 0 * *"},
+		{"星期超出范围", "0 0 * * 8"},
+		{"范围颠倒", "0 0 * * 5-1"},
+		{"步长为0", "*/0 * * * *"},
+		{"无效的名称", "0 0 * foo *"},
+		{"未知的简写", "@every5m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCron(tt.expr); err == nil {
+				t.Errorf("ParseCron(%q) 应返回错误", tt.expr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2026-01-01 是星期四
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name  string
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{"每15分钟", "*/15 * * * *", at(1, 1, 10, 7), at(1, 1, 10, 15)},
+		{"不包含起点本身", "0 12 * * *", at(1, 1, 12, 0), at(1, 2, 12, 0)},
+		{"跳到下一小时", "30 * * * *", at(1, 1, 10, 45), at(1, 1, 11, 30)},
+		{"简写", "@monthly", at(1, 15, 0, 0), at(2, 1, 0, 0)},
+		{"月份和星期的名称", "0 0 * feb mon", at(1, 1, 0, 0), at(2, 2, 0, 0)},
+		{"跨年", "0 0 1 1 *", at(1, 1, 0, 0), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+
+		// 日和周都有限制时满足其一即触发
+		{"只限制日期", "0 9 13 * *", at(1, 1, 10, 0), at(1, 13, 9, 0)},
+		{"只限制星期", "0 9 * * 5", at(1, 1, 10, 0), at(1, 2, 9, 0)},
+		{"日期或星期：星期先到", "0 9 1 * 1", at(1, 1, 10, 0), at(1, 5, 9, 0)},
+		{"日期或星期：日期先到", "0 9 1 * 1", at(1, 26, 10, 0), at(2, 1, 9, 0)},
+		{"日期和星期都是?", "0 9 ? * ?", at(1, 1, 10, 0), at(1, 2, 9, 0)},
+
+		// 日期字段的 */n 是限制，不等同于 *
+		{"日期步长", "0 0 */10 * *", at(1, 1, 0, 0), at(1, 11, 0, 0)},
+		{"日期步长到月末", "0 0 */10 * *", at(1, 21, 0, 0), at(1, 31, 0, 0)},
+		{"日期步长或星期", "0 0 */10 * 1", at(1, 1, 0, 0), at(1, 5, 0, 0)},
+		{"日期范围步长", "0 0 5-20/5 * *", at(1, 15, 0, 0), at(1, 20, 0, 0)},
+		{"日期单值步长", "0 0 25/3 * *", at(1, 28, 0, 0), at(1, 31, 0, 0)},
+
+		// 星期中的7与0都表示星期日
+		{"7表示星期日", "0 0 * * 7", at(1, 1, 0, 0), at(1, 4, 0, 0)},
+		{"0表示星期日", "0 0 * * 0", at(1, 1, 0, 0), at(1, 4, 0, 0)},
+		{"范围包含7", "0 0 * * 6-7", at(1, 3, 12, 0), at(1, 4, 0, 0)},
+		{"7与日期", "0 0 20 * 7", at(1, 4, 12, 0), at(1, 11, 0, 0)},
+
+		{"永远不会触发", "0 0 30 2 *", at(1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) 返回错误: %v", tt.expr, err)
+			}
+			if got := schedule.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("ParseCron(%q).Next(%v) = %v，期望 %v", tt.expr, tt.after, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hugo-manager-go/config"
+)
+
+// 部署目标类型
+const (
+	DeployerTypeSSH   = "ssh"   // 通过SSH/SFTP上传到服务器（默认）
+	DeployerTypeLocal = "local" // 复制到本地或已挂载的目录
+	DeployerTypeS3    = "s3"    // 上传到S3兼容的对象存储
+	DeployerTypeGit   = "git"   // 提交到Git仓库的分支（如 gh-pages）
+)
+
+// 单次部署的基础超时时间，限速或有大文件分块上传时按站点大小延长
+const deployTimeout = 5 * time.Minute
+
+// 估算大文件分块上传时间使用的最低传输速率（字节/秒）
+const minDeployRate = 256 * 1024
+
+// ErrDeploymentPaused 部署因暂停而中断，上传队列已保留，可以继续部署
+var ErrDeploymentPaused = errors.New("上传已暂停")
+
+// Deployer 部署目标
+// ServerConfig.RemotePath 对SSH目标是远程目录，对本地目标是目标目录，对S3目标是对象键前缀；
+// Git目标使用 GitRemote 和 GitBranch
+type Deployer interface {
+	// 测试目标是否可连接、可写入
+	TestConnection(ctx context.Context) error
+	// 将本地目录部署到目标
+	Deploy(ctx context.Context, localPath string, incremental bool) (*DeployResult, error)
+	// 预览镜像删除（不做任何修改）
+	PreviewMirrorDeletions(ctx context.Context, localPath string) (*MirrorPlan, error)
+}
+
+// 获取服务器的部署目标类型，未设置时为SSH
+func ServerDeployerType(server config.ServerConfig) string {
+	if server.Type == "" {
+		return DeployerTypeSSH
+	}
+	return strings.ToLower(server.Type)
+}
+
+// 校验部署目标类型
+func ValidateDeployerType(deployerType string) error {
+	switch strings.ToLower(deployerType) {
+	case "", DeployerTypeSSH, DeployerTypeLocal, DeployerTypeS3, DeployerTypeGit:
+		return nil
+	default:
+		return fmt.Errorf("不支持的部署目标类型: %s", deployerType)
+	}
+}
+
+// 根据服务器配置创建部署目标
+func NewDeployer(server config.ServerConfig) (Deployer, error) {
+	switch ServerDeployerType(server) {
+	case DeployerTypeSSH:
+		sshConfig, err := config.ServerToSSHConfigWithJumps(server)
+		if err != nil {
+			return nil, err
+		}
+		client, err := NewSSHClient(sshConfig)
+		if err != nil {
+			return nil, err
+		}
+		return &sshDeployer{server: server, client: client}, nil
+	case DeployerTypeLocal:
+		target, err := newLocalTarget(server.RemotePath)
+		if err != nil {
+			return nil, err
+		}
+		return &storageDeployer{server: server, target: target}, nil
+	case DeployerTypeS3:
+		target, err := newS3Target(config.WithDecryptedCredentials(server))
+		if err != nil {
+			return nil, err
+		}
+		return &storageDeployer{server: server, target: target}, nil
+	case DeployerTypeGit:
+		return newGitDeployer(server)
+	default:
+		return nil, fmt.Errorf("不支持的部署目标类型: %s", server.Type)
+	}
+}
+
+// 获取部署目标本次连接看到的主机公钥（仅SSH目标）
+func deployerHostKey(deployer Deployer) string {
+	if d, ok := deployer.(*sshDeployer); ok {
+		return d.client.HostKey()
+	}
+	return ""
+}
+
+// SSH部署目标
+type sshDeployer struct {
+	server config.ServerConfig
+	client *SSHClient
+}
+
+func (d *sshDeployer) TestConnection(ctx context.Context) error {
+	return d.client.TestConnection(ctx)
+}
+
+func (d *sshDeployer) Deploy(ctx context.Context, localPath string, incremental bool) (*DeployResult, error) {
+	result, err := d.client.ExecuteRsyncWithServer(ctx, localPath, d.server.RemotePath, incremental, d.server.ID, d.server.Name)
+	if result != nil {
+		result.HostKey = d.client.HostKey()
+	}
+	return result, err
+}
+
+func (d *sshDeployer) PreviewMirrorDeletions(ctx context.Context, localPath string) (*MirrorPlan, error) {
+	return d.client.previewMirrorDeletions(ctx, localPath, d.server.RemotePath)
+}
+
+// 广播部署进度，有服务器信息时使用多服务器消息
+func broadcastDeployProgress(serverID, serverName, message string, progress, total, current int, currentFile string) {
+	if serverID != "" && serverName != "" {
+		BroadcastMultiServerDeployProgress(serverID, serverName, message, progress, total, current, currentFile)
+	} else {
+		BroadcastDeployProgress(message, progress, total, current, currentFile)
+	}
+}
+
+// 便捷函数：部署到指定服务器（按服务器类型选择部署目标）
+func ExecuteServerDeployment(server config.ServerConfig, localPath string, incremental bool) (*DeployResult, error) {
+	deployer, err := NewDeployer(server)
+	if err != nil {
+		return &DeployResult{
+			Success: false,
+			Message: fmt.Sprintf("创建部署目标失败: %v", err),
+		}, err
+	}
+
+	timeout := deployTimeoutFor(localPath, server.BandwidthLimit) + totalHookTimeout(server.PreDeployHooks, server.PostDeployHooks)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return deployer.Deploy(ctx, localPath, incremental)
+}
+
+// 部署的超时：基础时间加上按站点大小估算的传输时间
+// 限速时按限速传输整个站点的时间（留出一倍余量），否则按最低传输速率计算分块上传的大文件
+func deployTimeoutFor(localPath string, bandwidthLimit int64) time.Duration {
+	var total, large int64
+	filepath.Walk(localPath, func(_ string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		total += info.Size()
+		if info.Size() >= chunkedUploadThreshold {
+			large += info.Size()
+		}
+		return nil
+	})
+
+	timeout := deployTimeout
+	if bandwidthLimit > 0 {
+		timeout += time.Duration(total*2/bandwidthLimit) * time.Second
+	} else if large > 0 {
+		timeout += time.Duration(large/minDeployRate) * time.Second
+	}
+	return timeout
+}
+
+// 便捷函数：测试服务器连接，返回SSH服务器出示的主机公钥
+func TestServerConnection(server config.ServerConfig) (string, error) {
+	deployer, err := NewDeployer(server)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	err = deployer.TestConnection(ctx)
+	return deployerHostKey(deployer), err
+}
+
+// 便捷函数：预览指定服务器的镜像删除
+func PreviewServerMirrorDeletions(server config.ServerConfig, localPath string) (*MirrorPlan, error) {
+	deployer, err := NewDeployer(server)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	return deployer.PreviewMirrorDeletions(ctx, localPath)
+}
@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"hugo-manager-go/config"
+)
+
+// Hugo项目根目录下的部署排除规则文件
+const DeployIgnoreFileName = ".deployignore"
+
+// 一条排除规则
+type ignoreRule struct {
+	pattern string
+	negate  bool // ! 开头：重新包含
+	dirOnly bool // / 结尾：只匹配目录
+	regex   *regexp.Regexp
+}
+
+// DeployIgnore 部署排除规则，语法与 .gitignore 相同：
+// 不含 / 的规则匹配任意层级的文件名或目录名，含 / 的规则相对站点根目录匹配，
+// * 和 ? 不匹配 /，** 匹配任意层级，/ 结尾只匹配目录，! 开头重新包含之前排除的路径。
+// 与 .gitignore 不同的是，被排除目录下的文件也可以用 ! 重新包含，
+// 以便服务器的包含规则覆盖项目的排除规则。
+type DeployIgnore struct {
+	rules      []ignoreRule
+	hasNegated bool
+}
+
+// 解析一条规则，空行和注释返回 nil
+func parseIgnoreRule(line string) (*ignoreRule, error) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	rule := &ignoreRule{pattern: line}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return nil, fmt.Errorf("无效的规则 %s", rule.pattern)
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr, err := globToRegexp(line)
+	if err != nil {
+		return nil, fmt.Errorf("无效的规则 %s", rule.pattern)
+	}
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+	rule.regex, err = regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("无效的规则 %s", rule.pattern)
+	}
+	return rule, nil
+}
+
+// 将通配符转换为正则表达式
+func globToRegexp(glob string) (string, error) {
+	var expr strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch ch := glob[i]; ch {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// **/ 匹配零个或多个目录
+					i++
+					expr.WriteString("(?:.*/)?")
+				} else {
+					expr.WriteString(".*")
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("未闭合的 [")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	return expr.String(), nil
+}
+
+// 追加规则，errPrefix 用于标明规则来源
+func (d *DeployIgnore) addRules(lines []string, negateAll bool, errPrefix string) error {
+	for _, line := range lines {
+		if negateAll {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			line = "!" + strings.TrimPrefix(line, "!")
+		}
+		rule, err := parseIgnoreRule(line)
+		if err != nil {
+			return fmt.Errorf("%s%v", errPrefix, err)
+		}
+		if rule == nil {
+			continue
+		}
+		d.rules = append(d.rules, *rule)
+		if rule.negate {
+			d.hasNegated = true
+		}
+	}
+	return nil
+}
+
+// 校验服务器的排除和包含规则
+func ValidateDeployPatterns(patterns []string) error {
+	var ignore DeployIgnore
+	return ignore.addRules(patterns, false, "")
+}
+
+// 加载部署排除规则：项目的 .deployignore，然后是服务器的排除规则，
+// 最后是服务器的包含规则（重新包含被前面规则排除的路径）
+func LoadDeployIgnore(excludePatterns, includePatterns []string) (*DeployIgnore, error) {
+	ignore := &DeployIgnore{}
+
+	ignoreFile := filepath.Join(config.GetHugoProjectPath(), DeployIgnoreFileName)
+	file, err := os.Open(ignoreFile)
+	if err == nil {
+		var lines []string
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("读取%s失败: %v", DeployIgnoreFileName, err)
+		}
+		if err := ignore.addRules(lines, false, DeployIgnoreFileName+": "); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取%s失败: %v", DeployIgnoreFileName, err)
+	}
+
+	if err := ignore.addRules(excludePatterns, false, "服务器排除规则: "); err != nil {
+		return nil, err
+	}
+	if err := ignore.addRules(includePatterns, true, "服务器包含规则: "); err != nil {
+		return nil, err
+	}
+	return ignore, nil
+}
+
+// 加载服务器的部署排除规则
+func loadServerDeployIgnore(server config.ServerConfig) (*DeployIgnore, error) {
+	return LoadDeployIgnore(server.ExcludePatterns, server.IncludePatterns)
+}
+
+// 判断相对站点根目录的路径是否被排除
+// 规则按顺序匹配路径本身及其各级上级目录，最后一条匹配的规则决定结果
+func (d *DeployIgnore) Match(relPath string, isDir bool) bool {
+	if d == nil || len(d.rules) == 0 {
+		return false
+	}
+	relPath = strings.Trim(filepath.ToSlash(relPath), "/")
+	if relPath == "" {
+		return false
+	}
+
+	// 各级上级目录
+	var candidates []string
+	for i := 0; i < len(relPath); i++ {
+		if relPath[i] == '/' {
+			candidates = append(candidates, relPath[:i])
+		}
+	}
+
+	excluded := false
+	for _, rule := range d.rules {
+		matched := (!rule.dirOnly || isDir) && rule.regex.MatchString(relPath)
+		for _, dir := range candidates {
+			if matched {
+				break
+			}
+			matched = rule.regex.MatchString(dir)
+		}
+		if matched {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
+
+// 遍历本地目录时能否跳过整个被排除的目录：有重新包含规则时不能跳过
+func (d *DeployIgnore) skipDir(relPath string) bool {
+	return d != nil && !d.hasNegated && d.Match(relPath, true)
+}
@@ -0,0 +1,202 @@
+package utils
+
+import "testing"
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob    string
+		want    string
+		wantErr bool
+	}{
+		{glob: "*.js", want: `[^/]*\.js`},
+		{glob: "file?.txt", want: `file[^/]\.txt`},
+		{glob: "**/drafts", want: `(?:.*/)?drafts`},
+		{glob: "docs/**/index.html", want: `docs/(?:.*/)?index\.html`},
+		{glob: "assets/**", want: `assets/.*`},
+		{glob: "[abc].css", want: `[abc]\.css`},
+		{glob: "[!abc].css", want: `[^abc]\.css`},
+		{glob: `\*.txt`, want: `\*\.txt`},
+		{glob: "[abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.glob, func(t *testing.T) {
+			got, err := globToRegexp(tt.glob)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("globToRegexp(%q) 应返回错误", tt.glob)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("globToRegexp(%q) 返回错误: %v", tt.glob, err)
+			}
+			if got != tt.want {
+				t.Errorf("globToRegexp(%q) = %q，期望 %q", tt.glob, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeployIgnoreMatch(t *testing.T) {
+	type check struct {
+		path     string
+		isDir    bool
+		excluded bool
+	}
+	tests := []struct {
+		name    string
+		exclude []string
+		include []string // 服务器的包含规则
+		checks  []check
+	}{
+		{
+			name:    "不含/的规则匹配任意层级",
+			exclude: []string{"*.map"},
+			checks: []check{
+				{"app.js.map", false, true},
+				{"js/vendor/app.js.map", false, true},
+				{"app.js", false, false},
+			},
+		},
+		{
+			name:    "含/的规则相对根目录",
+			exclude: []string{"/robots.txt", "drafts/*.html"},
+			checks: []check{
+				{"robots.txt", false, true},
+				{"blog/robots.txt", false, false},
+				{"drafts/a.html", false, true},
+				{"blog/drafts/a.html", false, false},
+				{"drafts/sub/a.html", false, false},
+			},
+		},
+		{
+			name:    "**/匹配零个或多个目录",
+			exclude: []string{"**/tmp", "docs/**/index.html"},
+			checks: []check{
+				{"tmp", true, true},
+				{"a/b/tmp", true, true},
+				{"a/b/tmp/file.txt", false, true},
+				{"docs/index.html", false, true},
+				{"docs/a/b/index.html", false, true},
+				{"other/index.html", false, false},
+				{"docs/a/about.html", false, false},
+			},
+		},
+		{
+			name:    "/**匹配目录下的所有内容",
+			exclude: []string{"assets/**"},
+			checks: []check{
+				{"assets/css/site.css", false, true},
+				{"assets", true, false},
+				{"blog/assets/site.css", false, false},
+			},
+		},
+		{
+			name:    "/结尾只匹配目录",
+			exclude: []string{"cache/"},
+			checks: []check{
+				{"cache", true, true},
+				{"cache", false, false},
+				{"cache/page.html", false, true},
+				{"blog/cache", true, true},
+				{"blog/cache/page.html", false, true},
+				{"cache.html", false, false},
+			},
+		},
+		{
+			name:    "!重新包含之前排除的文件",
+			exclude: []string{"*.html", "!index.html"},
+			checks: []check{
+				{"about.html", false, true},
+				{"index.html", false, false},
+				{"blog/index.html", false, false},
+			},
+		},
+		{
+			name:    "!重新包含被排除目录下的文件",
+			exclude: []string{"private/", "!private/keep.html"},
+			checks: []check{
+				{"private", true, true},
+				{"private/secret.html", false, true},
+				{"private/keep.html", false, false},
+			},
+		},
+		{
+			name:    "服务器的包含规则覆盖排除规则",
+			exclude: []string{"drafts/"},
+			include: []string{"drafts/public/**"},
+			checks: []check{
+				{"drafts/wip.html", false, true},
+				{"drafts/public/post.html", false, false},
+				{"drafts/public/img/a.png", false, false},
+			},
+		},
+		{
+			name:    "最后一条匹配的规则决定结果",
+			exclude: []string{"*.txt", "!*.txt", "secret.txt"},
+			checks: []check{
+				{"notes.txt", false, false},
+				{"secret.txt", false, true},
+			},
+		},
+		{
+			name:    "转义和注释",
+			exclude: []string{"# 注释", "", `\#hash`, `\!bang`},
+			checks: []check{
+				{"#hash", false, true},
+				{"!bang", false, true},
+				{"注释", false, false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ignore := &DeployIgnore{}
+			if err := ignore.addRules(tt.exclude, false, ""); err != nil {
+				t.Fatalf("排除规则错误: %v", err)
+			}
+			if err := ignore.addRules(tt.include, true, ""); err != nil {
+				t.Fatalf("包含规则错误: %v", err)
+			}
+			for _, c := range tt.checks {
+				if got := ignore.Match(c.path, c.isDir); got != c.excluded {
+					t.Errorf("Match(%q, %v) = %v，期望 %v", c.path, c.isDir, got, c.excluded)
+				}
+			}
+		})
+	}
+}
+
+func TestDeployIgnoreSkipDir(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		dir   string
+		want  bool
+	}{
+		{"被排除的目录", []string{"private/"}, "private", true},
+		{"未被排除的目录", []string{"private/"}, "public", false},
+		{"有重新包含规则时不能跳过", []string{"private/", "!private/keep.html"}, "private", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ignore := &DeployIgnore{}
+			if err := ignore.addRules(tt.rules, false, ""); err != nil {
+				t.Fatalf("规则错误: %v", err)
+			}
+			if got := ignore.skipDir(tt.dir); got != tt.want {
+				t.Errorf("skipDir(%q) = %v，期望 %v", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIgnoreRuleErrors(t *testing.T) {
+	for _, line := range []string{"!", "/", "[abc"} {
+		t.Run(line, func(t *testing.T) {
+			if _, err := parseIgnoreRule(line); err == nil {
+				t.Errorf("parseIgnoreRule(%q) 应返回错误", line)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hugo-manager-go/config"
+)
+
+// 未配置分支时部署到的分支
+const DefaultGitBranch = "gh-pages"
+
+// 部署提交的作者
+const (
+	gitCommitAuthorName  = "Hugo Manager"
+	gitCommitAuthorEmail = "hugo-manager@localhost"
+)
+
+// Git分支部署目标：将站点内容提交到远程仓库的指定分支（如 gh-pages）
+// 远程仓库可以是URL，也可以是本地裸仓库路径；认证使用系统的git配置（SSH密钥、凭据助手等）
+type gitDeployer struct {
+	server config.ServerConfig
+	remote string
+	branch string
+}
+
+func newGitDeployer(server config.ServerConfig) (*gitDeployer, error) {
+	remote := strings.TrimSpace(server.GitRemote)
+	if remote == "" {
+		return nil, errors.New("Git远程仓库不能为空")
+	}
+	if strings.HasPrefix(remote, "-") {
+		return nil, fmt.Errorf("无效的远程仓库: %s", remote)
+	}
+	branch := strings.TrimSpace(server.GitBranch)
+	if branch == "" {
+		branch = DefaultGitBranch
+	}
+	if strings.HasPrefix(branch, "-") || strings.ContainsAny(branch, " ~^:?*[\\") {
+		return nil, fmt.Errorf("无效的分支名称: %s", branch)
+	}
+	return &gitDeployer{server: server, remote: remote, branch: branch}, nil
+}
+
+// 执行git命令，返回标准输出
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// 禁止交互式输入凭据，避免部署挂起
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if stderr.Len() > 0 {
+			return stdout.String(), fmt.Errorf("git %s 失败: %v, 详情: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return stdout.String(), fmt.Errorf("git %s 失败: %v", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// 解析以NUL分隔的路径列表
+func splitNul(output string) []string {
+	var items []string
+	for _, item := range strings.Split(output, "\x00") {
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (d *gitDeployer) TestConnection(ctx context.Context) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return errors.New("未找到git命令，请先安装git")
+	}
+	if _, err := runGit(ctx, "", "ls-remote", "--heads", d.remote); err != nil {
+		return fmt.Errorf("无法访问远程仓库: %v", err)
+	}
+	return nil
+}
+
+// 在临时目录中初始化仓库并获取目标分支的最新提交
+// 返回工作目录，以及分支是否已存在
+func (d *gitDeployer) fetchBranch(ctx context.Context) (string, bool, error) {
+	workDir, err := os.MkdirTemp("", "hugo-manager-git-*")
+	if err != nil {
+		return "", false, err
+	}
+
+	if _, err := runGit(ctx, workDir, "init", "-q"); err != nil {
+		os.RemoveAll(workDir)
+		return "", false, err
+	}
+	if _, err := runGit(ctx, workDir, "remote", "add", "origin", d.remote); err != nil {
+		os.RemoveAll(workDir)
+		return "", false, err
+	}
+
+	output, err := runGit(ctx, workDir, "ls-remote", "--heads", "origin", "refs/heads/"+d.branch)
+	if err != nil {
+		os.RemoveAll(workDir)
+		return "", false, fmt.Errorf("无法访问远程仓库: %v", err)
+	}
+	if strings.TrimSpace(output) == "" {
+		return workDir, false, nil
+	}
+
+	if _, err := runGit(ctx, workDir, "fetch", "-q", "--depth", "1", "origin", "refs/heads/"+d.branch); err != nil {
+		os.RemoveAll(workDir)
+		return "", false, err
+	}
+	return workDir, true, nil
+}
+
+// 列出分支中已有的文件
+func (d *gitDeployer) listBranchFiles(ctx context.Context, workDir string) ([]string, error) {
+	output, err := runGit(ctx, workDir, "ls-tree", "-r", "-z", "--name-only", "FETCH_HEAD")
+	if err != nil {
+		return nil, err
+	}
+	return splitNul(output), nil
+}
+
+func (d *gitDeployer) PreviewMirrorDeletions(ctx context.Context, localPath string) (*MirrorPlan, error) {
+	ignore, err := loadServerDeployIgnore(d.server)
+	if err != nil {
+		return nil, err
+	}
+	localFiles, excluded, err := collectLocalRelPaths(localPath, ignore)
+	if err != nil {
+		return nil, fmt.Errorf("收集本地文件失败: %v", err)
+	}
+
+	workDir, exists, err := d.fetchBranch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	var branchFiles []string
+	if exists {
+		if branchFiles, err = d.listBranchFiles(ctx, workDir); err != nil {
+			return nil, err
+		}
+	}
+	plan := buildMirrorPlan(localFiles, branchFiles, d.server.ProtectedPaths, ignore)
+	plan.Excluded = excluded
+	return plan, nil
+}
+
+// 生成部署提交信息，Hugo项目本身是git仓库时附带源码版本
+func gitCommitMessage(ctx context.Context) string {
+	message := fmt.Sprintf("Deploy site at %s", time.Now().Format("2006-01-02 15:04:05"))
+	if source, err := runGit(ctx, config.GetHugoProjectPath(), "rev-parse", "--short", "HEAD"); err == nil {
+		message += "\n\nSource: " + strings.TrimSpace(source)
+	}
+	return message
+}
+
+// 部署：检出目标分支，同步站点文件后提交并推送；内容没有变化时不创建提交
+// git只会提交有变化的文件，因此增量部署与完整部署的效果相同
+func (d *gitDeployer) Deploy(ctx context.Context, localPath string, incremental bool) (*DeployResult, error) {
+	fail := func(message string, err error) (*DeployResult, error) {
+		return &DeployResult{
+			Success: false,
+			Message: fmt.Sprintf("%s: %v", message, err),
+		}, err
+	}
+
+	if _, err := os.Stat(localPath); err != nil {
+		return fail("本地目录不存在", err)
+	}
+	ignore, err := loadServerDeployIgnore(d.server)
+	if err != nil {
+		return fail("加载部署排除规则失败", err)
+	}
+	localFiles, _, err := collectLocalRelPaths(localPath, ignore)
+	if err != nil {
+		return fail("收集本地文件失败", err)
+	}
+
+	broadcastDeployProgress(d.server.ID, d.server.Name, "正在获取远程分支 "+d.branch+"...", 10, 0, 0, "")
+
+	workDir, exists, err := d.fetchBranch(ctx)
+	if err != nil {
+		return fail("获取远程分支失败", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	if exists {
+		_, err = runGit(ctx, workDir, "checkout", "-q", "-B", d.branch, "FETCH_HEAD")
+	} else {
+		_, err = runGit(ctx, workDir, "checkout", "-q", "--orphan", d.branch)
+	}
+	if err != nil {
+		return fail("检出分支失败", err)
+	}
+
+	broadcastDeployProgress(d.server.ID, d.server.Name, "正在同步站点文件...", 30, len(localFiles), 0, "")
+
+	// 镜像删除：分支中存在而本地不存在的文件
+	if d.server.MirrorDeletions && exists {
+		branchFiles, err := d.listBranchFiles(ctx, workDir)
+		if err != nil {
+			return fail("列出分支文件失败", err)
+		}
+		plan := buildMirrorPlan(localFiles, branchFiles, d.server.ProtectedPaths, ignore)
+		for _, relPath := range plan.ToDelete {
+			if err := os.Remove(filepath.Join(workDir, filepath.FromSlash(relPath))); err != nil && !os.IsNotExist(err) {
+				return fail("删除文件失败", err)
+			}
+		}
+	}
+
+	// 复制站点文件到工作目录
+	worktree := &localTarget{root: workDir}
+	for relPath := range localFiles {
+		if relPath == ".git" || strings.HasPrefix(relPath, ".git/") {
+			continue
+		}
+		localFile := filepath.Join(localPath, filepath.FromSlash(relPath))
+		info, err := os.Stat(localFile)
+		if err != nil {
+			return fail("读取本地文件失败", err)
+		}
+		if err := worktree.copyFile(ctx, relPath, localFile, info); err != nil {
+			return fail("复制文件失败", err)
+		}
+	}
+
+	if _, err := runGit(ctx, workDir, "add", "-A"); err != nil {
+		return fail("暂存文件失败", err)
+	}
+
+	// 统计本次变化的文件
+	output, err := runGit(ctx, workDir, "diff", "--cached", "--name-status", "-z", "--no-renames")
+	if err != nil {
+		return fail("比较文件变化失败", err)
+	}
+	result := &DeployResult{}
+	fields := splitNul(output)
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, relPath := fields[i], fields[i+1]
+		if status == "D" {
+			result.DeletedFiles = append(result.DeletedFiles, relPath)
+			continue
+		}
+		result.UploadedFiles = append(result.UploadedFiles, relPath)
+		if info, err := os.Stat(filepath.Join(workDir, filepath.FromSlash(relPath))); err == nil {
+			result.BytesTransferred += info.Size()
+		}
+	}
+
+	result.Success = true
+	result.FilesDeployed = len(result.UploadedFiles)
+	result.FilesDeleted = len(result.DeletedFiles)
+	if result.FilesDeployed == 0 && result.FilesDeleted == 0 {
+		result.Message = "没有文件需要传输"
+		result.Output = fmt.Sprintf("分支 %s 已是最新，未创建提交", d.branch)
+		return result, nil
+	}
+
+	broadcastDeployProgress(d.server.ID, d.server.Name, "正在提交到分支 "+d.branch+"...", 70, 0, 0, "")
+
+	if _, err := runGit(ctx, workDir,
+		"-c", "user.name="+gitCommitAuthorName,
+		"-c", "user.email="+gitCommitAuthorEmail,
+		"-c", "commit.gpgsign=false",
+		"commit", "-q", "-m", gitCommitMessage(ctx)); err != nil {
+		return fail("提交失败", err)
+	}
+	commit, err := runGit(ctx, workDir, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return fail("读取提交失败", err)
+	}
+	commit = strings.TrimSpace(commit)
+
+	broadcastDeployProgress(d.server.ID, d.server.Name, "正在推送到远程仓库...", 90, 0, 0, "")
+
+	if _, err := runGit(ctx, workDir, "push", "-q", "origin", "HEAD:refs/heads/"+d.branch); err != nil {
+		return fail("推送失败", err)
+	}
+
+	result.Message = "文件传输完成"
+	result.Output = fmt.Sprintf("已提交 %s 到分支 %s：更新 %d 个文件，共 %d 字节", commit, d.branch, result.FilesDeployed, result.BytesTransferred)
+	if result.FilesDeleted > 0 {
+		result.Output += fmt.Sprintf("，删除了 %d 个文件", result.FilesDeleted)
+	}
+	return result, nil
+}
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"hugo-manager-go/config"
+)
+
+// 部署钩子的默认超时和超时上限
+const (
+	DefaultHookTimeout = 60 * time.Second
+	MaxHookTimeout     = time.Hour
+)
+
+// 每个钩子最多保留的输出字节数，超出部分只推送到日志，不保存
+const maxHookOutput = 64 * 1024
+
+// 推送到日志的单行最大字节数
+const hookLineLimit = 4096
+
+// 校验部署钩子配置
+func ValidateDeployHooks(hooks ...[]config.DeployHook) error {
+	for _, list := range hooks {
+		for _, hook := range list {
+			if strings.TrimSpace(hook.Command) == "" {
+				return errors.New("部署命令不能为空")
+			}
+			if hook.Timeout < 0 || time.Duration(hook.Timeout)*time.Second > MaxHookTimeout {
+				return fmt.Errorf("部署命令超时必须在 0-%d 秒之间", int(MaxHookTimeout/time.Second))
+			}
+		}
+	}
+	return nil
+}
+
+// 获取钩子实际使用的超时
+func hookTimeout(hook config.DeployHook) time.Duration {
+	if hook.Timeout <= 0 {
+		return DefaultHookTimeout
+	}
+	return time.Duration(hook.Timeout) * time.Second
+}
+
+// 部署钩子的超时之和，部署的超时加上这段时间，执行钩子不占用传输的时间
+func totalHookTimeout(hooks ...[]config.DeployHook) time.Duration {
+	var total time.Duration
+	for _, list := range hooks {
+		for _, hook := range list {
+			total += hookTimeout(hook)
+		}
+	}
+	return total
+}
+
+// 钩子输出：按行推送到WebSocket日志，同时保存前 maxHookOutput 字节
+type hookOutput struct {
+	serverID   string
+	serverName string
+
+	mutex     sync.Mutex
+	captured  bytes.Buffer
+	truncated bool
+	partial   []byte // 尚未遇到换行符的内容
+}
+
+func (o *hookOutput) Write(p []byte) (int, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	remaining := maxHookOutput - o.captured.Len()
+	if len(p) > remaining {
+		o.captured.Write(p[:max(remaining, 0)])
+		o.truncated = true
+	} else {
+		o.captured.Write(p)
+	}
+
+	o.partial = append(o.partial, p...)
+	for {
+		i := bytes.IndexByte(o.partial, '\n')
+		if i < 0 {
+			break
+		}
+		o.broadcast(string(o.partial[:i]))
+		o.partial = o.partial[i+1:]
+	}
+	// 没有换行的长输出也分段推送
+	if len(o.partial) >= hookLineLimit {
+		o.broadcast(string(o.partial))
+		o.partial = nil
+	}
+	return len(p), nil
+}
+
+// 推送剩余不完整的一行
+func (o *hookOutput) flush() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if len(o.partial) > 0 {
+		o.broadcast(string(o.partial))
+		o.partial = nil
+	}
+}
+
+func (o *hookOutput) broadcast(line string) {
+	line = strings.TrimRight(line, "\r")
+	if o.serverID != "" && o.serverName != "" {
+		BroadcastMultiServerProgress(o.serverID, o.serverName, "hook", "deploying", line, 0, 0, 0, "")
+	} else {
+		BroadcastProgress("hook", "deploying", line, 0, 0, 0, "")
+	}
+}
+
+func (o *hookOutput) String() string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.truncated {
+		return o.captured.String() + "\n...（输出过长，已截断）"
+	}
+	return o.captured.String()
+}
+
+// 依次执行部署钩子，任一命令失败时停止并返回错误
+// 命令在远程部署目录中通过 /bin/sh 执行，只受钩子自己的超时限制，不受部署的传输超时限制
+func (c *SSHClient) runHooks(ctx context.Context, stage string, hooks []config.DeployHook, remotePath, serverID, serverName string) ([]config.DeployHookResult, error) {
+	ctx = context.WithoutCancel(ctx)
+	var results []config.DeployHookResult
+	for i, hook := range hooks {
+		label := "部署前"
+		if stage == config.HookStagePost {
+			label = "部署后"
+		}
+		message := fmt.Sprintf("执行%s命令 (%d/%d): %s", label, i+1, len(hooks), hook.Command)
+		if serverID != "" && serverName != "" {
+			BroadcastMultiServerDeployProgress(serverID, serverName, message, 0, 0, 0, "")
+		} else {
+			BroadcastDeployProgress(message, 0, 0, 0, "")
+		}
+
+		result := c.runHook(ctx, stage, hook, remotePath, serverID, serverName)
+		results = append(results, result)
+		if result.Error != "" {
+			return results, fmt.Errorf("%s: %s", hook.Command, result.Error)
+		}
+	}
+	return results, nil
+}
+
+// 执行单个部署钩子
+func (c *SSHClient) runHook(ctx context.Context, stage string, hook config.DeployHook, remotePath, serverID, serverName string) (result config.DeployHookResult) {
+	result = config.DeployHookResult{
+		Stage:    stage,
+		Command:  hook.Command,
+		ExitCode: -1,
+	}
+	start := time.Now()
+	defer func() {
+		result.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		result.Error = fmt.Sprintf("创建SSH会话失败: %v", err)
+		return result
+	}
+	defer session.Close()
+
+	output := &hookOutput{serverID: serverID, serverName: serverName}
+	session.Stdout = output
+	session.Stderr = output
+
+	cmd := fmt.Sprintf("cd %s && /bin/sh -c %s", shellQuote(remotePath), shellQuote(hook.Command))
+	if err := session.Start(cmd); err != nil {
+		result.Error = fmt.Sprintf("启动命令失败: %v", err)
+		return result
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	timeout := hookTimeout(hook)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err = <-done:
+	case <-timer.C:
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		err = fmt.Errorf("命令超时（%d秒）", int(timeout/time.Second))
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		err = ctx.Err()
+	}
+
+	output.flush()
+	result.Output = output.String()
+
+	var exitErr *ssh.ExitError
+	switch {
+	case err == nil:
+		result.ExitCode = 0
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitStatus()
+		result.Error = fmt.Sprintf("退出状态 %d", result.ExitCode)
+	default:
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// 将钩子的执行结果追加到部署输出
+func appendHookOutput(result *DeployResult, hooks []config.DeployHookResult) {
+	for _, hook := range hooks {
+		status := "成功"
+		if hook.Error != "" {
+			status = "失败: " + hook.Error
+		}
+		result.Output += fmt.Sprintf("\n[%s] $ %s（%s）\n%s", hook.Stage, hook.Command, status, hook.Output)
+	}
+}
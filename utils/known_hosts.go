@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyMismatchError 服务器出示的主机密钥与已信任的密钥不一致
+type HostKeyMismatchError struct {
+	Host      string
+	Expected  string // 已信任密钥的指纹
+	Actual    string // 服务器当前密钥的指纹
+	ActualKey string // 服务器当前公钥（authorized_keys格式）
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("主机密钥不匹配: %s 的密钥已从 %s 变为 %s，可能存在中间人攻击，请确认后再信任新密钥",
+		e.Host, e.Expected, e.Actual)
+}
+
+// 判断错误是否为主机密钥不匹配
+func AsHostKeyMismatch(err error) (*HostKeyMismatchError, bool) {
+	var mismatch *HostKeyMismatchError
+	if errors.As(err, &mismatch) {
+		return mismatch, true
+	}
+	return nil, false
+}
+
+// 将公钥序列化为authorized_keys格式（不含换行）
+func MarshalHostKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+// 解析authorized_keys格式的主机公钥
+func ParseHostKey(hostKey string) (ssh.PublicKey, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+	if err != nil {
+		return nil, fmt.Errorf("无法解析主机密钥: %v", err)
+	}
+	return key, nil
+}
+
+// 计算主机公钥的SHA256指纹，解析失败时返回空字符串
+func HostKeyFingerprint(hostKey string) string {
+	if hostKey == "" {
+		return ""
+	}
+	key, err := ParseHostKey(hostKey)
+	if err != nil {
+		return ""
+	}
+	return ssh.FingerprintSHA256(key)
+}
+
+// 创建主机密钥校验回调
+// 未信任任何密钥时接受服务器密钥（首次信任），否则要求与已信任的密钥完全一致。
+// 服务器出示的公钥会写入observed，供调用方在首次连接后固定。
+func newHostKeyCallback(pinned string, observed *string) (ssh.HostKeyCallback, error) {
+	var pinnedKey ssh.PublicKey
+	if pinned != "" {
+		key, err := ParseHostKey(pinned)
+		if err != nil {
+			return nil, err
+		}
+		pinnedKey = key
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		*observed = MarshalHostKey(key)
+		if pinnedKey == nil {
+			return nil
+		}
+		if bytes.Equal(pinnedKey.Marshal(), key.Marshal()) {
+			return nil
+		}
+		return &HostKeyMismatchError{
+			Host:      hostname,
+			Expected:  ssh.FingerprintSHA256(pinnedKey),
+			Actual:    ssh.FingerprintSHA256(key),
+			ActualKey: MarshalHostKey(key),
+		}
+	}, nil
+}
+
+// 根据已信任的密钥类型限定握手时协商的主机密钥算法
+// 否则服务器可能出示另一种类型的密钥，导致误判为密钥变更
+func hostKeyAlgorithms(pinned string) []string {
+	if pinned == "" {
+		return nil
+	}
+	key, err := ParseHostKey(pinned)
+	if err != nil {
+		return nil
+	}
+	if key.Type() == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{key.Type()}
+}
+
+// 默认的known_hosts文件路径
+func DefaultKnownHostsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
+// 从known_hosts文件中查找指定主机的公钥
+// 支持哈希主机名、通配符和非标准端口的 [host]:port 写法；
+// 同一主机存在多个密钥时优先选择 ed25519，其次 ecdsa，最后 rsa。
+func LookupKnownHost(knownHostsPath, host string, port int) (string, error) {
+	if knownHostsPath == "" {
+		knownHostsPath = DefaultKnownHostsPath()
+	}
+	if strings.HasPrefix(knownHostsPath, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			knownHostsPath = filepath.Join(home, knownHostsPath[2:])
+		}
+	}
+	if port == 0 {
+		port = 22
+	}
+
+	callback, err := knownhosts.New(knownHostsPath)
+	if err != nil {
+		return "", fmt.Errorf("无法读取known_hosts文件 %s: %v", knownHostsPath, err)
+	}
+
+	// 使用一个随机生成的探测密钥触发校验失败，从错误中取出该主机已知的全部密钥
+	probePub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	probe, err := ssh.NewPublicKey(probePub)
+	if err != nil {
+		return "", err
+	}
+
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	err = callback(address, &net.TCPAddr{}, probe)
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		if err != nil {
+			return "", fmt.Errorf("读取known_hosts失败: %v", err)
+		}
+		return "", fmt.Errorf("known_hosts中没有 %s 的记录", address)
+	}
+	if len(keyErr.Want) == 0 {
+		return "", fmt.Errorf("known_hosts中没有 %s 的记录", address)
+	}
+
+	preferred := []string{ssh.KeyAlgoED25519, ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521, ssh.KeyAlgoRSA}
+	for _, keyType := range preferred {
+		for _, known := range keyErr.Want {
+			if known.Key.Type() == keyType {
+				return MarshalHostKey(known.Key), nil
+			}
+		}
+	}
+	return MarshalHostKey(keyErr.Want[0].Key), nil
+}
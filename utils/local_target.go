@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 本地或已挂载目录的部署目标
+type localTarget struct {
+	root string
+}
+
+func newLocalTarget(root string) (*localTarget, error) {
+	root = strings.TrimSpace(root)
+	if root == "" {
+		return nil, errors.New("目标目录不能为空")
+	}
+	if !filepath.IsAbs(root) {
+		return nil, fmt.Errorf("目标目录必须是绝对路径: %s", root)
+	}
+	return &localTarget{root: filepath.Clean(root)}, nil
+}
+
+// 相对路径对应的本地文件路径
+func (t *localTarget) fullPath(relPath string) string {
+	return filepath.Join(t.root, filepath.FromSlash(relPath))
+}
+
+// 检查目标目录存在且可写
+func (t *localTarget) Check(ctx context.Context) error {
+	if err := os.MkdirAll(t.root, 0755); err != nil {
+		return fmt.Errorf("无法创建目标目录 %s: %v", t.root, err)
+	}
+
+	probe, err := os.CreateTemp(t.root, ".hugo-manager-probe-*")
+	if err != nil {
+		return fmt.Errorf("目标目录不可写 %s: %v", t.root, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+func (t *localTarget) ReadFile(ctx context.Context, relPath string) ([]byte, error) {
+	return os.ReadFile(t.fullPath(relPath))
+}
+
+// 先写入同目录的临时文件再重命名，避免网站读取到写了一半的文件
+func (t *localTarget) writeAtomic(relPath string, mode os.FileMode, write func(io.Writer) error) (string, error) {
+	target := t.fullPath(relPath)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("无法创建目录 %s: %v", filepath.Dir(target), err)
+	}
+
+	tmpPath := filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".uploading")
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return "", err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return tmpPath, nil
+}
+
+func (t *localTarget) PutFile(ctx context.Context, relPath string, reader io.Reader, info os.FileInfo) error {
+	tmpPath, err := t.writeAtomic(relPath, info.Mode().Perm(), func(w io.Writer) error {
+		_, err := io.Copy(w, reader)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// 保留修改时间，便于站点的缓存校验
+	os.Chtimes(tmpPath, info.ModTime(), info.ModTime())
+	return os.Rename(tmpPath, t.fullPath(relPath))
+}
+
+// 复制本地文件（不限速）
+func (t *localTarget) copyFile(ctx context.Context, relPath, localFile string, info os.FileInfo) error {
+	source, err := os.Open(localFile)
+	if err != nil {
+		return fmt.Errorf("无法打开本地文件: %v", err)
+	}
+	defer source.Close()
+	return t.PutFile(ctx, relPath, source, info)
+}
+
+func (t *localTarget) PutBytes(ctx context.Context, relPath string, data []byte) error {
+	tmpPath, err := t.writeAtomic(relPath, 0644, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, t.fullPath(relPath))
+}
+
+func (t *localTarget) List(ctx context.Context) ([]string, error) {
+	var files []string
+	err := filepath.Walk(t.root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			// 目标目录尚不存在时没有任何文件
+			if os.IsNotExist(err) && file == t.root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(t.root, file)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(relPath))
+		return nil
+	})
+	return files, err
+}
+
+// 内容清单保存在目标目录之外的同级文件中
+func (t *localTarget) manifestPath() string {
+	return t.root + manifestFileSuffix
+}
+
+func (t *localTarget) ReadManifest() ([]byte, error) {
+	return os.ReadFile(t.manifestPath())
+}
+
+func (t *localTarget) WriteManifest(data []byte) error {
+	tmpPath := t.manifestPath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, t.manifestPath())
+}
+
+// 删除文件，并清理因此变空的目录
+func (t *localTarget) Remove(ctx context.Context, relPaths []string) error {
+	for _, relPath := range relPaths {
+		if err := os.Remove(t.fullPath(relPath)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除文件 %s 失败: %v", relPath, err)
+		}
+	}
+	for _, dir := range parentDirsDeepestFirst(relPaths) {
+		// 目录非空时删除失败，直接忽略
+		os.Remove(t.fullPath(dir))
+	}
+	return nil
+}
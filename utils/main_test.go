@@ -0,0 +1,13 @@
+package utils
+
+import (
+	"os"
+	"testing"
+)
+
+// config 包初始化时在当前目录（utils）找不到配置文件会写入默认配置，测试结束后删除
+func TestMain(m *testing.M) {
+	code := m.Run()
+	os.Remove("config.json")
+	os.Exit(code)
+}
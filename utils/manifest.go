@@ -0,0 +1,299 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"hugo-manager-go/config"
+)
+
+// 内容清单保存在部署目录之外、与部署目录同级的文件中（<部署目录>.manifest.json），避免被网站访问
+const manifestFileSuffix = ".manifest.json"
+
+// 旧版本保存在部署目录中的内容清单，读取时作为回退，写入新清单后删除
+const legacyManifestFileName = ".hugo-manager-manifest.json"
+
+// 部署目录对应的远程内容清单路径
+func remoteManifestPath(remotePath string) string {
+	return strings.TrimSuffix(strings.ReplaceAll(remotePath, "\\", "/"), "/") + manifestFileSuffix
+}
+
+// DeployManifest 记录上次成功部署到服务器的文件内容
+type DeployManifest struct {
+	Version   int               `json:"version"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Files     map[string]string `json:"files"`            // 相对路径 -> SHA-256
+	Target    string            `json:"target,omitempty"` // 部署位置，用于确认本地缓存属于当前部署目录
+}
+
+// 创建空的内容清单
+func NewDeployManifest() *DeployManifest {
+	return &DeployManifest{
+		Version: 1,
+		Files:   make(map[string]string),
+	}
+}
+
+// 复制内容清单
+func (m *DeployManifest) Clone() *DeployManifest {
+	clone := NewDeployManifest()
+	clone.Target = m.Target
+	for relPath, hash := range m.Files {
+		clone.Files[relPath] = hash
+	}
+	return clone
+}
+
+// 计算文件的SHA-256
+func HashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// 解析内容清单
+func parseDeployManifest(data []byte) (*DeployManifest, error) {
+	manifest := NewDeployManifest()
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("解析内容清单失败: %v", err)
+	}
+	if manifest.Files == nil {
+		manifest.Files = make(map[string]string)
+	}
+	return manifest, nil
+}
+
+var manifestCacheKeyPattern = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// 本地缓存的内容清单路径（每个服务器一份）
+func manifestCachePath(serverID string) string {
+	key := serverID
+	if key == "" {
+		key = "default"
+	}
+	key = manifestCacheKeyPattern.ReplaceAllString(key, "_")
+	return filepath.Join(config.GetDataDir(), "manifests", key+".json")
+}
+
+// 读取本地缓存的内容清单
+func LoadCachedManifest(serverID string) (*DeployManifest, error) {
+	data, err := os.ReadFile(manifestCachePath(serverID))
+	if err != nil {
+		return nil, err
+	}
+	return parseDeployManifest(data)
+}
+
+// 读取属于同一部署位置的本地缓存清单，没有时返回 nil
+// 服务器上不能保存内容清单时（如部署目录的上级目录不可写），增量部署使用本地缓存
+func loadCachedManifestFor(serverID, target string) *DeployManifest {
+	cached, err := LoadCachedManifest(serverID)
+	if err != nil || cached.Target != target {
+		return nil
+	}
+	return cached
+}
+
+// 保存内容清单到本地缓存
+func saveCachedManifest(serverID string, manifest *DeployManifest) error {
+	cachePath := manifestCachePath(serverID)
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cachePath, data, 0644)
+}
+
+// 读取远程内容清单，不存在时读取部署目录中的旧清单
+// 都不存在时返回 (nil, nil)
+func (c *SSHClient) fetchRemoteManifest(remotePath string) (*DeployManifest, error) {
+	manifest, err := c.readRemoteManifest(remoteManifestPath(remotePath))
+	if err != nil || manifest != nil {
+		return manifest, err
+	}
+	return c.readRemoteManifest(path.Join(strings.ReplaceAll(remotePath, "\\", "/"), legacyManifestFileName))
+}
+
+// 读取指定路径的远程内容清单，一次往返完成
+// 文件不存在时返回 (nil, nil)
+func (c *SSHClient) readRemoteManifest(manifestPath string) (*DeployManifest, error) {
+	var data []byte
+	if c.sftpClient != nil {
+		file, err := c.sftpClient.Open(manifestPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		defer file.Close()
+
+		if data, err = io.ReadAll(file); err != nil {
+			return nil, err
+		}
+	} else {
+		session, err := c.client.NewSession()
+		if err != nil {
+			return nil, fmt.Errorf("创建SSH会话失败: %v", err)
+		}
+		defer session.Close()
+
+		quoted := shellQuote(manifestPath)
+		cmd := fmt.Sprintf("if [ -f %s ]; then cat %s; else echo NOTEXIST; fi", quoted, quoted)
+		if data, err = session.Output(cmd); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(string(data)) == "NOTEXIST" {
+			return nil, nil
+		}
+	}
+
+	return parseDeployManifest(data)
+}
+
+// 写入远程内容清单（先写临时文件再重命名），并删除部署目录中的旧清单
+func (c *SSHClient) writeRemoteManifest(remotePath string, manifest *DeployManifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	manifestPath := remoteManifestPath(remotePath)
+	tmpPath := manifestPath + ".tmp"
+	legacyPath := path.Join(strings.ReplaceAll(remotePath, "\\", "/"), legacyManifestFileName)
+
+	if c.sftpClient != nil {
+		file, err := c.sftpClient.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		if err := c.sftpClient.PosixRename(tmpPath, manifestPath); err != nil {
+			c.sftpClient.Remove(manifestPath)
+			if err := c.sftpClient.Rename(tmpPath, manifestPath); err != nil {
+				return err
+			}
+		}
+		c.sftpClient.Remove(legacyPath)
+		return nil
+	}
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	session.Stdin = strings.NewReader(string(data))
+	cmd := fmt.Sprintf("cat > %s && mv -f %s %s && rm -f %s",
+		shellQuote(tmpPath), shellQuote(tmpPath), shellQuote(manifestPath), shellQuote(legacyPath))
+	return session.Run(cmd)
+}
+
+// 删除内容清单（远程和本地缓存），下次增量部署时上传所有文件
+// 用于恢复快照等使远程内容与清单不一致的操作之后
+func (c *SSHClient) removeDeployManifest(remotePath, serverID string) {
+	if _, err := c.runRemoteCommand("rm -f " + shellQuote(remoteManifestPath(remotePath))); err != nil {
+		fmt.Printf("删除远程内容清单失败: %v\n", err)
+	}
+	if err := os.Remove(manifestCachePath(serverID)); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("删除本地内容清单缓存失败: %v\n", err)
+	}
+}
+
+// 加载上次部署的内容清单
+// 以远程清单为准；远程没有清单时使用同一部署目录的本地缓存，读取失败时使用本地缓存；
+// 都没有时返回空清单（所有文件都会上传）
+func (c *SSHClient) loadDeployManifest(remotePath, serverID string) *DeployManifest {
+	manifest, err := c.fetchRemoteManifest(remotePath)
+	if err == nil {
+		if manifest == nil {
+			if cached := loadCachedManifestFor(serverID, remotePath); cached != nil {
+				fmt.Println("远程没有内容清单，使用本地缓存")
+				return cached
+			}
+			fmt.Println("远程没有内容清单，将上传所有文件")
+			return NewDeployManifest()
+		}
+		return manifest
+	}
+
+	fmt.Printf("读取远程内容清单失败，使用本地缓存: %v\n", err)
+	if cached, cacheErr := LoadCachedManifest(serverID); cacheErr == nil {
+		return cached
+	}
+	return NewDeployManifest()
+}
+
+// 保存内容清单到远程和本地缓存，返回写入远程清单的错误
+func (c *SSHClient) saveDeployManifest(remotePath, serverID string, manifest *DeployManifest) error {
+	manifest.UpdatedAt = time.Now()
+	manifest.Target = remotePath
+
+	err := c.writeRemoteManifest(remotePath, manifest)
+	if err != nil {
+		fmt.Printf("写入远程内容清单失败: %v\n", err)
+	}
+	if err := saveCachedManifest(serverID, manifest); err != nil {
+		fmt.Printf("保存本地内容清单缓存失败: %v\n", err)
+	}
+	return err
+}
+
+// 内容清单写入失败时附加到部署结果消息的说明
+func manifestWarning(err error) string {
+	if err == nil {
+		return ""
+	}
+	return fmt.Sprintf("；内容清单写入失败，下次增量部署将使用本地缓存: %v", err)
+}
+
+// 根据本次传输结果更新内容清单
+// 上传成功的文件记录新的哈希；上传失败或未上传（暂停、停止）的文件从清单中移除，保证下次增量部署时重新上传
+func (c *SSHClient) updateManifestFromTasks(manifest *DeployManifest, tasks []FileTask) *DeployManifest {
+	updated := manifest.Clone()
+	for _, task := range tasks {
+		if !c.isTaskCompleted(task) {
+			delete(updated.Files, task.RelPath)
+			continue
+		}
+
+		hash := task.Hash
+		if hash == "" {
+			var err error
+			if hash, err = HashFile(task.LocalFile); err != nil {
+				delete(updated.Files, task.RelPath)
+				continue
+			}
+		}
+		updated.Files[task.RelPath] = hash
+	}
+	return updated
+}
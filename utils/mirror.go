@@ -0,0 +1,333 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"hugo-manager-go/config"
+)
+
+// 未配置保护路径时默认保护的远程路径
+var DefaultProtectedPaths = []string{".well-known"}
+
+// MirrorPlan 镜像删除计划
+type MirrorPlan struct {
+	ToDelete  []string `json:"to_delete"` // 将被删除的远程文件（相对部署目录）
+	Protected []string `json:"protected"` // 本地不存在但受保护或被排除规则匹配而保留的远程文件
+	Excluded  []string `json:"excluded"`  // 被排除规则跳过、不会上传的本地文件（整个目录被排除时以 / 结尾）
+}
+
+// 获取生效的保护路径列表
+func effectiveProtectedPaths(protectedPaths []string) []string {
+	if protectedPaths == nil {
+		return DefaultProtectedPaths
+	}
+	return protectedPaths
+}
+
+// 判断远程相对路径是否受保护
+// 保护规则可以是目录（保护其下所有文件）、文件路径或通配符（如 *.php）
+func isProtectedPath(relPath string, protectedPaths []string) bool {
+	// 旧版本的内容清单始终受保护，写入新清单后会被删除
+	if relPath == legacyManifestFileName || strings.HasPrefix(relPath, legacyManifestFileName+".") {
+		return true
+	}
+
+	for _, rule := range protectedPaths {
+		rule = strings.Trim(strings.TrimSpace(filepath.ToSlash(rule)), "/")
+		if rule == "" {
+			continue
+		}
+		if relPath == rule || strings.HasPrefix(relPath, rule+"/") {
+			return true
+		}
+		if matched, _ := path.Match(rule, relPath); matched {
+			return true
+		}
+		if !strings.Contains(rule, "/") {
+			if matched, _ := path.Match(rule, path.Base(relPath)); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 收集本地目录下所有要部署的文件的相对路径，同时返回被排除规则跳过的路径
+func collectLocalRelPaths(localPath string, ignore *DeployIgnore) (map[string]bool, []string, error) {
+	files := make(map[string]bool)
+	excluded := []string{}
+	err := filepath.Walk(localPath, func(localFile string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(localPath, localFile)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			if relPath != "." && ignore.skipDir(relPath) {
+				excluded = append(excluded, relPath+"/")
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ignore.Match(relPath, false) {
+			excluded = append(excluded, relPath)
+			return nil
+		}
+		files[relPath] = true
+		return nil
+	})
+	return files, excluded, err
+}
+
+// 列出远程部署目录下的所有文件（相对路径），一次往返完成
+func (c *SSHClient) listRemoteFiles(remotePath string) ([]string, error) {
+	remotePath = strings.ReplaceAll(remotePath, "\\", "/")
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	var stderr strings.Builder
+	session.Stderr = &stderr
+
+	// 远程目录尚不存在时没有任何文件
+	quoted := shellQuote(remotePath)
+	cmd := fmt.Sprintf("if [ -d %s ]; then cd %s && find . -type f -print0; fi", quoted, quoted)
+	output, err := session.Output(cmd)
+	if err != nil {
+		// 只开放SFTP的服务器无法执行命令，改为通过SFTP遍历
+		if c.sftpClient != nil {
+			return c.listRemoteFilesSFTP(remotePath)
+		}
+		return nil, fmt.Errorf("列出远程文件失败: %v, 详情: %s", err, stderr.String())
+	}
+
+	var files []string
+	for _, entry := range bytes.Split(output, []byte{0}) {
+		relPath := strings.TrimPrefix(string(entry), "./")
+		if relPath != "" {
+			files = append(files, relPath)
+		}
+	}
+	return files, nil
+}
+
+// 通过SFTP遍历远程部署目录
+func (c *SSHClient) listRemoteFilesSFTP(remotePath string) ([]string, error) {
+	var files []string
+	walker := c.sftpClient.Walk(remotePath)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return nil, fmt.Errorf("列出远程文件失败: %v", err)
+		}
+		if !walker.Stat().Mode().IsRegular() {
+			continue
+		}
+		relPath := strings.TrimPrefix(walker.Path(), strings.TrimSuffix(remotePath, "/")+"/")
+		files = append(files, relPath)
+	}
+	return files, nil
+}
+
+// 计算镜像删除计划：远程存在而本地不存在的文件
+func (c *SSHClient) planMirrorDeletions(localPath, remotePath string, protectedPaths []string) (*MirrorPlan, error) {
+	localFiles, excluded, err := collectLocalRelPaths(localPath, c.ignore)
+	if err != nil {
+		return nil, fmt.Errorf("收集本地文件失败: %v", err)
+	}
+
+	remoteFiles, err := c.listRemoteFiles(remotePath)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := buildMirrorPlan(localFiles, remoteFiles, protectedPaths, c.ignore)
+	plan.Excluded = excluded
+	return plan, nil
+}
+
+// 比较本地和远程文件列表，生成镜像删除计划
+// 被排除规则匹配的远程文件不由部署管理（如通过其他方式同步的媒体文件），与受保护路径一样保留
+func buildMirrorPlan(localFiles map[string]bool, remoteFiles []string, protectedPaths []string, ignore *DeployIgnore) *MirrorPlan {
+	protectedPaths = effectiveProtectedPaths(protectedPaths)
+	plan := &MirrorPlan{ToDelete: []string{}, Protected: []string{}, Excluded: []string{}}
+	for _, relPath := range remoteFiles {
+		if localFiles[relPath] {
+			continue
+		}
+		if isProtectedPath(relPath, protectedPaths) || ignore.Match(relPath, false) {
+			plan.Protected = append(plan.Protected, relPath)
+		} else {
+			plan.ToDelete = append(plan.ToDelete, relPath)
+		}
+	}
+
+	sort.Strings(plan.ToDelete)
+	sort.Strings(plan.Protected)
+	return plan
+}
+
+// 删除远程文件，并清理因此变空的目录
+func (c *SSHClient) deleteRemoteFiles(remotePath string, relPaths []string) error {
+	if len(relPaths) == 0 {
+		return nil
+	}
+	remotePath = strings.TrimSuffix(strings.ReplaceAll(remotePath, "\\", "/"), "/")
+
+	dirs := parentDirsDeepestFirst(relPaths)
+
+	if c.sftpClient != nil {
+		for _, relPath := range relPaths {
+			if err := c.sftpClient.Remove(remotePath + "/" + relPath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("删除远程文件 %s 失败: %v", relPath, err)
+			}
+		}
+		for _, dir := range dirs {
+			// 目录非空时删除失败，直接忽略
+			c.sftpClient.RemoveDirectory(remotePath + "/" + dir)
+		}
+		return nil
+	}
+
+	// 路径列表通过stdin以NUL分隔传入，避免命令行过长和路径转义问题
+	if err := c.runWithNulList(fmt.Sprintf("cd %s && xargs -0 rm -f --", shellQuote(remotePath)), relPaths); err != nil {
+		return fmt.Errorf("删除远程文件失败: %v", err)
+	}
+	// 目录非空时rmdir会失败，直接忽略
+	c.runWithNulList(fmt.Sprintf("cd %s && xargs -0 rmdir -- 2>/dev/null; true", shellQuote(remotePath)), dirs)
+	return nil
+}
+
+// 收集删除文件后可能变空的父目录，按深度从深到浅排列
+func parentDirsDeepestFirst(relPaths []string) []string {
+	dirSet := make(map[string]bool)
+	for _, relPath := range relPaths {
+		for dir := path.Dir(relPath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			dirSet[dir] = true
+		}
+	}
+	var dirs []string
+	for dir := range dirSet {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], "/") > strings.Count(dirs[j], "/")
+	})
+	return dirs
+}
+
+// 执行远程命令，并将路径列表以NUL分隔写入stdin
+func (c *SSHClient) runWithNulList(cmd string, items []string) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	var input bytes.Buffer
+	for _, item := range items {
+		input.WriteString(item)
+		input.WriteByte(0)
+	}
+	session.Stdin = &input
+
+	var stderr strings.Builder
+	session.Stderr = &stderr
+
+	if err := session.Run(cmd); err != nil {
+		if stderr.Len() > 0 {
+			return fmt.Errorf("%v, 详情: %s", err, stderr.String())
+		}
+		return err
+	}
+	return nil
+}
+
+// 执行镜像删除：删除远程存在而本地不存在、且不受保护的文件，返回删除的文件列表
+// 未开启镜像删除时不做任何操作
+func (c *SSHClient) runMirrorDeletions(localPath, remotePath string, manifest *DeployManifest, serverID, serverName string) ([]string, error) {
+	if !c.mirrorDeletions {
+		return nil, nil
+	}
+
+	plan, err := c.planMirrorDeletions(localPath, remotePath, c.protectedPaths)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.ToDelete) == 0 {
+		return nil, nil
+	}
+
+	message := fmt.Sprintf("正在删除 %d 个本地已不存在的远程文件...", len(plan.ToDelete))
+	if serverID != "" && serverName != "" {
+		BroadcastMultiServerDeployProgress(serverID, serverName, message, 100, 0, 0, "")
+	} else {
+		BroadcastDeployProgress(message, 100, 0, 0, "")
+	}
+
+	if err := c.deleteRemoteFiles(remotePath, plan.ToDelete); err != nil {
+		return nil, err
+	}
+
+	if manifest != nil {
+		for _, relPath := range plan.ToDelete {
+			delete(manifest.Files, relPath)
+		}
+	}
+	return plan.ToDelete, nil
+}
+
+// 连接服务器并预览镜像删除（不做任何修改）
+func (c *SSHClient) previewMirrorDeletions(ctx context.Context, localPath, remotePath string) (*MirrorPlan, error) {
+	if err := c.Connect(ctx); err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	if _, err := c.prepareTransferBackend(); err != nil {
+		return nil, err
+	}
+
+	// 版本目录模式下与当前生效的版本比较
+	if c.releaseMode {
+		remotePath = path.Join(strings.ReplaceAll(remotePath, "\\", "/"), CurrentLinkName)
+	}
+
+	ignore, err := LoadDeployIgnore(c.excludePatterns, c.includePatterns)
+	if err != nil {
+		return nil, err
+	}
+	c.ignore = ignore
+
+	return c.planMirrorDeletions(localPath, remotePath, c.protectedPaths)
+}
+
+// 便捷函数：预览镜像删除（不做任何修改）
+func PreviewMirrorDeletions(sshConfig config.SSHConfig, localPath, remotePath string) (*MirrorPlan, error) {
+	client, err := NewSSHClient(sshConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	return client.previewMirrorDeletions(ctx, localPath, remotePath)
+}
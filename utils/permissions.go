@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"hugo-manager-go/config"
+)
+
+// 属组名称：字母、数字、下划线、点和连字符，或数字GID
+var fileGroupPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// 解析八进制权限（如 644、0755），空字符串返回0表示未配置
+func ParsePermissionMode(mode string) (os.FileMode, error) {
+	mode = strings.TrimSpace(mode)
+	if mode == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || value == 0 || value > 0o7777 {
+		return 0, fmt.Errorf("权限格式错误，应为八进制数字如 644: %s", mode)
+	}
+	return os.FileMode(value), nil
+}
+
+// 校验服务器的文件权限、目录权限和属组配置
+func ValidatePermissions(fileMode, dirMode, group string) error {
+	file, err := ParsePermissionMode(fileMode)
+	if err != nil {
+		return fmt.Errorf("文件%s", err)
+	}
+	if file != 0 && file&0o400 == 0 {
+		return fmt.Errorf("文件权限 %s 不允许所有者读取", fileMode)
+	}
+	dir, err := ParsePermissionMode(dirMode)
+	if err != nil {
+		return fmt.Errorf("目录%s", err)
+	}
+	if dir != 0 && dir&0o700 != 0o700 {
+		return fmt.Errorf("目录权限 %s 必须允许所有者读写和进入，否则无法继续上传", dirMode)
+	}
+	if group != "" && !fileGroupPattern.MatchString(group) {
+		return fmt.Errorf("属组名称格式错误: %s", group)
+	}
+	return nil
+}
+
+// 从SSH配置读取权限策略，配置已在保存时校验
+func (c *SSHClient) setPermissionPolicy(sshConfig config.SSHConfig) {
+	c.fileMode, _ = ParsePermissionMode(sshConfig.FileMode)
+	c.dirMode, _ = ParsePermissionMode(sshConfig.DirMode)
+	c.fileGroup = strings.TrimSpace(sshConfig.FileGroup)
+}
+
+// 是否配置了权限策略
+func (c *SSHClient) hasPermissionPolicy() bool {
+	return c.fileMode != 0 || c.dirMode != 0 || c.fileGroup != ""
+}
+
+// 上传文件在远程使用的权限：配置了文件权限时使用配置，否则与本地文件一致
+func (c *SSHClient) remoteFileMode(local os.FileMode) os.FileMode {
+	if c.fileMode != 0 {
+		return c.fileMode
+	}
+	return local.Perm()
+}
+
+// 设置文件权限和属组的shell命令，未配置文件权限和属组时返回空字符串
+func (c *SSHClient) filePermissionCommand(remoteFiles ...string) string {
+	if len(remoteFiles) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(remoteFiles))
+	for i, remoteFile := range remoteFiles {
+		quoted[i] = shellQuote(remoteFile)
+	}
+
+	var commands []string
+	if c.fileMode != 0 {
+		commands = append(commands, fmt.Sprintf("chmod %o -- %s", c.fileMode, strings.Join(quoted, " ")))
+	}
+	if c.fileGroup != "" {
+		commands = append(commands, fmt.Sprintf("chgrp %s -- %s", shellQuote(c.fileGroup), strings.Join(quoted, " ")))
+	}
+	return strings.Join(commands, " && ")
+}
+
+// 开始向 root 传输文件，root 与其下新建的目录都会按策略设置权限
+func (c *SSHClient) setPermissionRoot(root string) {
+	c.preparedDirsMutex.Lock()
+	defer c.preparedDirsMutex.Unlock()
+	c.permissionRoot = strings.TrimSuffix(root, "/")
+	c.preparedDirs = make(map[string]bool)
+}
+
+// 需要设置权限的目录：从传输根目录到 dir 之间尚未设置过的各级目录
+func (c *SSHClient) unpreparedDirs(dir string) []string {
+	c.preparedDirsMutex.Lock()
+	defer c.preparedDirsMutex.Unlock()
+
+	dir = strings.TrimSuffix(dir, "/")
+	var dirs []string
+	for current := dir; ; current = path.Dir(current) {
+		if !c.preparedDirs[current] {
+			dirs = append(dirs, current)
+		}
+		if c.permissionRoot == "" || current == c.permissionRoot || !strings.HasPrefix(current, c.permissionRoot+"/") {
+			break
+		}
+	}
+	return dirs
+}
+
+func (c *SSHClient) markDirsPrepared(dirs []string) {
+	c.preparedDirsMutex.Lock()
+	defer c.preparedDirsMutex.Unlock()
+	if c.preparedDirs == nil {
+		c.preparedDirs = make(map[string]bool)
+	}
+	for _, dir := range dirs {
+		c.preparedDirs[dir] = true
+	}
+}
+
+// 设置目录权限和属组的shell命令，未配置时返回空字符串
+func (c *SSHClient) dirPermissionCommand(dirs []string) string {
+	if len(dirs) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(dirs))
+	for i, dir := range dirs {
+		quoted[i] = shellQuote(dir)
+	}
+
+	var commands []string
+	if c.dirMode != 0 {
+		commands = append(commands, fmt.Sprintf("chmod %o -- %s", c.dirMode, strings.Join(quoted, " ")))
+	}
+	if c.fileGroup != "" {
+		commands = append(commands, fmt.Sprintf("chgrp %s -- %s", shellQuote(c.fileGroup), strings.Join(quoted, " ")))
+	}
+	return strings.Join(commands, " && ")
+}
+
+// 创建远程目录（SFTP或shell），并按策略设置新目录及其上级目录的权限
+func (c *SSHClient) makeRemoteDirectory(backend, remoteDir string) error {
+	if backend != TransferModeSFTP || c.sftpClient == nil {
+		return c.createRemoteDirectory(remoteDir)
+	}
+
+	if err := c.sftpClient.MkdirAll(remoteDir); err != nil {
+		return err
+	}
+	if c.dirMode == 0 && c.fileGroup == "" {
+		return nil
+	}
+
+	dirs := c.unpreparedDirs(remoteDir)
+	if len(dirs) == 0 {
+		return nil
+	}
+	// 设置属组需要shell命令，只设置权限时通过SFTP完成
+	if c.fileGroup != "" {
+		if _, err := c.runRemoteCommand(c.dirPermissionCommand(dirs)); err != nil {
+			return fmt.Errorf("设置目录权限失败: %v", err)
+		}
+	} else {
+		for _, dir := range dirs {
+			if err := c.sftpClient.Chmod(dir, c.dirMode); err != nil {
+				return fmt.Errorf("设置目录权限失败 %s: %v", dir, err)
+			}
+		}
+	}
+	c.markDirsPrepared(dirs)
+	return nil
+}
+
+// 批量上传解压后设置这一批文件及其所在目录的权限和属组
+func (c *SSHClient) applyBatchPermissions(remotePath string, batch []FileTask) error {
+	if !c.hasPermissionPolicy() {
+		return nil
+	}
+
+	var (
+		files []string
+		dirs  []string
+		seen  = make(map[string]bool)
+	)
+	for _, task := range batch {
+		remoteFile := path.Join(remotePath, task.RelPath)
+		files = append(files, remoteFile)
+		for _, dir := range c.unpreparedDirs(path.Dir(remoteFile)) {
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+
+	var commands []string
+	if command := c.dirPermissionCommand(dirs); command != "" {
+		commands = append(commands, command)
+	}
+	if command := c.filePermissionCommand(files...); command != "" {
+		commands = append(commands, command)
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	if _, err := c.runRemoteCommand(strings.Join(commands, " && ")); err != nil {
+		return fmt.Errorf("设置文件权限失败: %v", err)
+	}
+	c.markDirsPrepared(dirs)
+	return nil
+}
+
+// 修复远程权限的结果
+type PermissionRepairResult struct {
+	RemotePath string `json:"remote_path"`
+	FilesFixed int    `json:"files_fixed"` // 修改了权限的文件数
+	DirsFixed  int    `json:"dirs_fixed"`  // 修改了权限的目录数
+	GroupFixed int    `json:"group_fixed"` // 修改了属组的文件和目录数
+	FileMode   string `json:"file_mode,omitempty"`
+	DirMode    string `json:"dir_mode,omitempty"`
+	FileGroup  string `json:"file_group,omitempty"`
+}
+
+// 遍历远程路径下的所有文件和目录，将权限和属组修复为服务器配置的值
+// 不跟随符号链接（版本目录模式下修复 releases/ 中的所有版本）
+func RepairRemotePermissions(sshConfig config.SSHConfig) (*PermissionRepairResult, error) {
+	result := &PermissionRepairResult{
+		FileMode:  sshConfig.FileMode,
+		DirMode:   sshConfig.DirMode,
+		FileGroup: sshConfig.FileGroup,
+	}
+
+	err := withReleaseClient(sshConfig, func(client *SSHClient, remotePath string) error {
+		client.setPermissionPolicy(sshConfig)
+		if !client.hasPermissionPolicy() {
+			return fmt.Errorf("服务器没有配置文件权限、目录权限或属组")
+		}
+		if remotePath == "" || remotePath == "/" {
+			return fmt.Errorf("远程路径无效: %s", sshConfig.RemotePath)
+		}
+		result.RemotePath = remotePath
+		root := shellQuote(remotePath)
+
+		// 只修改与配置不一致的项，每修改一项输出一行
+		count := func(cmd string) (int, error) {
+			output, err := client.runRemoteCommand(cmd)
+			if err != nil {
+				return 0, err
+			}
+			return strings.Count(output, "\n"), nil
+		}
+
+		var err error
+		if client.dirMode != 0 {
+			result.DirsFixed, err = count(fmt.Sprintf("find %s -type d ! -perm %o -print -exec chmod %o {} +", root, client.dirMode, client.dirMode))
+			if err != nil {
+				return fmt.Errorf("修复目录权限失败: %v", err)
+			}
+		}
+		if client.fileMode != 0 {
+			result.FilesFixed, err = count(fmt.Sprintf("find %s -type f ! -perm %o -print -exec chmod %o {} +", root, client.fileMode, client.fileMode))
+			if err != nil {
+				return fmt.Errorf("修复文件权限失败: %v", err)
+			}
+		}
+		if client.fileGroup != "" {
+			group := shellQuote(client.fileGroup)
+			result.GroupFixed, err = count(fmt.Sprintf("find %s ! -group %s -print -exec chgrp -h %s {} +", root, group, group))
+			if err != nil {
+				return fmt.Errorf("修复属组失败: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,297 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"hugo-manager-go/config"
+)
+
+// 版本目录模式的远程目录结构：
+//
+//	<RemotePath>/releases/<时间戳>/  每次部署上传到新的版本目录
+//	<RemotePath>/current           指向当前生效版本的符号链接（网站根目录应指向这里）
+const (
+	ReleasesDirName     = "releases"
+	CurrentLinkName     = "current"
+	DefaultKeepReleases = 5
+)
+
+var releaseNamePattern = regexp.MustCompile(`^[0-9]{14}$`)
+
+// ReleaseList 远程版本列表
+type ReleaseList struct {
+	Releases []string `json:"releases"` // 按时间从旧到新排列
+	Current  string   `json:"current"`  // 当前生效的版本
+}
+
+// 获取生效的保留版本数
+func effectiveKeepReleases(keep int) int {
+	if keep <= 0 {
+		return DefaultKeepReleases
+	}
+	return keep
+}
+
+// 执行远程shell命令并返回标准输出
+func (c *SSHClient) runRemoteCommand(cmd string) (string, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	var stderr strings.Builder
+	session.Stderr = &stderr
+
+	output, err := session.Output(cmd)
+	if err != nil {
+		if stderr.Len() > 0 {
+			return string(output), fmt.Errorf("%v, 详情: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return string(output), err
+	}
+	return string(output), nil
+}
+
+// 读取当前生效的版本
+func (c *SSHClient) currentRelease(remotePath string) (string, error) {
+	linkPath := path.Join(remotePath, CurrentLinkName)
+	output, err := c.runRemoteCommand(fmt.Sprintf("readlink %s 2>/dev/null || true", shellQuote(linkPath)))
+	if err != nil {
+		return "", fmt.Errorf("读取当前版本失败: %v", err)
+	}
+
+	target := strings.TrimSpace(output)
+	if target == "" {
+		return "", nil
+	}
+	return path.Base(target), nil
+}
+
+// 列出远程所有版本
+func (c *SSHClient) listReleases(remotePath string) (*ReleaseList, error) {
+	releasesPath := path.Join(remotePath, ReleasesDirName)
+	quoted := shellQuote(releasesPath)
+	output, err := c.runRemoteCommand(fmt.Sprintf("if [ -d %s ]; then ls -1 %s; fi", quoted, quoted))
+	if err != nil {
+		return nil, fmt.Errorf("列出版本失败: %v", err)
+	}
+
+	list := &ReleaseList{Releases: []string{}}
+	for _, name := range strings.Split(output, "\n") {
+		name = strings.TrimSpace(name)
+		if releaseNamePattern.MatchString(name) {
+			list.Releases = append(list.Releases, name)
+		}
+	}
+	sort.Strings(list.Releases)
+
+	if list.Current, err = c.currentRelease(remotePath); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// 查找上次中断的部署所使用的版本目录，以便继续上传到同一目录
+func pendingRelease(serverID, remotePath string) string {
+	prefix := strings.TrimSuffix(remotePath, "/") + "/" + ReleasesDirName + "/"
+	for _, task := range config.GetServerUploadTasks(serverID) {
+		if task.Completed || !strings.HasPrefix(task.RemoteFile, prefix) {
+			continue
+		}
+		name := strings.SplitN(strings.TrimPrefix(task.RemoteFile, prefix), "/", 2)[0]
+		if releaseNamePattern.MatchString(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// 准备新的版本目录
+// 新版本以当前版本为基础（优先使用硬链接复制），这样增量部署只需上传变化的文件，
+// 受保护的文件（如用户上传内容）也会带入新版本
+func (c *SSHClient) prepareRelease(remotePath string) (string, error) {
+	if name := pendingRelease(c.serverID, remotePath); name != "" {
+		fmt.Printf("继续上传到未完成的版本: %s\n", name)
+		return name, nil
+	}
+
+	name := time.Now().Format("20060102150405")
+	releasePath := path.Join(ReleasesDirName, name)
+	quoted := shellQuote(releasePath)
+
+	// 新版本从当前版本复制，内容清单也从当前版本的清单复制，以便增量比较
+	cmd := fmt.Sprintf("cd %s && mkdir -p %s && mkdir %s && "+
+		"if [ -d %s/ ]; then cp -al %s/. %s/ 2>/dev/null || { rm -rf %s && mkdir %s && cp -a %s/. %s/; }; fi && "+
+		"if [ -L %s ] && [ -f \"$(readlink %s)%s\" ]; then cp \"$(readlink %s)%s\" %s; fi",
+		shellQuote(remotePath), ReleasesDirName, quoted,
+		CurrentLinkName, CurrentLinkName, quoted, quoted, quoted, CurrentLinkName, quoted,
+		CurrentLinkName, CurrentLinkName, manifestFileSuffix, CurrentLinkName, manifestFileSuffix,
+		shellQuote(releasePath+manifestFileSuffix))
+	if _, err := c.runRemoteCommand(cmd); err != nil {
+		return "", fmt.Errorf("创建版本目录失败: %v", err)
+	}
+	return name, nil
+}
+
+// 原子切换 current 符号链接到指定版本
+func (c *SSHClient) switchRelease(remotePath, name string) error {
+	linkPath := path.Join(remotePath, CurrentLinkName)
+	tmpLink := linkPath + ".tmp"
+
+	cmd := fmt.Sprintf("if [ -e %s ] && [ ! -L %s ]; then echo '%s 已存在且不是符号链接' >&2; exit 1; fi; "+
+		"[ -d %s ] && ln -sfn %s %s && mv -Tf %s %s",
+		shellQuote(linkPath), shellQuote(linkPath), CurrentLinkName,
+		shellQuote(path.Join(remotePath, ReleasesDirName, name)),
+		shellQuote(path.Join(ReleasesDirName, name)), shellQuote(tmpLink),
+		shellQuote(tmpLink), shellQuote(linkPath))
+	if _, err := c.runRemoteCommand(cmd); err != nil {
+		return fmt.Errorf("切换到版本 %s 失败: %v", name, err)
+	}
+	return nil
+}
+
+// 删除多余的旧版本，始终保留当前版本
+func (c *SSHClient) pruneReleases(remotePath string, keep int) error {
+	list, err := c.listReleases(remotePath)
+	if err != nil {
+		return err
+	}
+
+	keep = effectiveKeepReleases(keep)
+	if len(list.Releases) <= keep {
+		return nil
+	}
+
+	var toRemove []string
+	for _, name := range list.Releases[:len(list.Releases)-keep] {
+		if name != list.Current {
+			toRemove = append(toRemove, shellQuote(path.Join(ReleasesDirName, name)),
+				shellQuote(path.Join(ReleasesDirName, name)+manifestFileSuffix))
+		}
+	}
+	if len(toRemove) == 0 {
+		return nil
+	}
+
+	cmd := fmt.Sprintf("cd %s && rm -rf -- %s", shellQuote(remotePath), strings.Join(toRemove, " "))
+	if _, err := c.runRemoteCommand(cmd); err != nil {
+		return fmt.Errorf("清理旧版本失败: %v", err)
+	}
+	return nil
+}
+
+// 以版本目录模式部署：上传到新版本目录，全部成功后再切换 current
+func (c *SSHClient) deployRelease(ctx context.Context, localPath, remotePath string, incremental bool, serverID, serverName string) (*DeployResult, error) {
+	remotePath = strings.TrimSuffix(strings.ReplaceAll(remotePath, "\\", "/"), "/")
+
+	release, err := c.prepareRelease(remotePath)
+	if err != nil {
+		return &DeployResult{
+			Success: false,
+			Message: err.Error(),
+		}, err
+	}
+
+	// 新版本必须与本地完全一致，旧文件仍保留在历史版本中可供回滚
+	c.mirrorDeletions = true
+
+	releasePath := path.Join(remotePath, ReleasesDirName, release)
+	result, err := c.transferFilesWithServer(ctx, localPath, releasePath, incremental, serverID, serverName)
+	if err != nil || !result.Success {
+		return result, err
+	}
+
+	if result.FilesFailed > 0 {
+		err := fmt.Errorf("有 %d 个文件上传失败，未切换到新版本 %s", result.FilesFailed, release)
+		result.Success = false
+		result.Message = err.Error()
+		return result, err
+	}
+
+	if err := c.switchRelease(remotePath, release); err != nil {
+		result.Success = false
+		result.Message = err.Error()
+		return result, err
+	}
+	result.Release = release
+	result.Output += fmt.Sprintf("，已切换到版本 %s", release)
+
+	if err := c.pruneReleases(remotePath, c.keepReleases); err != nil {
+		fmt.Printf("清理旧版本失败: %v\n", err)
+	}
+
+	return result, nil
+}
+
+// 连接服务器并执行版本操作
+func withReleaseClient(sshConfig config.SSHConfig, fn func(client *SSHClient, remotePath string) error) error {
+	client, err := NewSSHClient(sshConfig)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := client.Connect(ctx); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	remotePath := strings.TrimSuffix(strings.ReplaceAll(sshConfig.RemotePath, "\\", "/"), "/")
+	return fn(client, remotePath)
+}
+
+// 便捷函数：列出服务器上的所有版本
+func ListReleases(sshConfig config.SSHConfig) (*ReleaseList, error) {
+	var list *ReleaseList
+	err := withReleaseClient(sshConfig, func(client *SSHClient, remotePath string) error {
+		var err error
+		list, err = client.listReleases(remotePath)
+		return err
+	})
+	return list, err
+}
+
+// 便捷函数：回滚到指定版本，release 为空时回滚到当前版本的上一个版本
+// 返回回滚后生效的版本
+func RollbackRelease(sshConfig config.SSHConfig, release string) (string, error) {
+	err := withReleaseClient(sshConfig, func(client *SSHClient, remotePath string) error {
+		list, err := client.listReleases(remotePath)
+		if err != nil {
+			return err
+		}
+
+		if release == "" {
+			for _, name := range list.Releases {
+				if name < list.Current {
+					release = name
+				}
+			}
+			if release == "" {
+				return fmt.Errorf("没有可回滚的历史版本")
+			}
+		} else {
+			found := false
+			for _, name := range list.Releases {
+				if name == release {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("版本不存在: %s", release)
+			}
+		}
+
+		return client.switchRelease(remotePath, release)
+	})
+	return release, err
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"hugo-manager-go/config"
+)
+
+// S3兼容对象存储的部署目标（AWS S3、MinIO、各云厂商对象存储等）
+type s3Target struct {
+	client *minio.Client
+	bucket string
+	prefix string // 对象键前缀，为空时部署到存储桶根目录
+}
+
+func newS3Target(server config.ServerConfig) (*s3Target, error) {
+	endpoint := strings.TrimSpace(server.S3Endpoint)
+	if endpoint == "" {
+		return nil, errors.New("S3服务地址不能为空")
+	}
+	if server.S3Bucket == "" {
+		return nil, errors.New("S3存储桶不能为空")
+	}
+	if (server.EncryptedS3AccessKey != "" && server.S3AccessKey == "") || (server.EncryptedS3SecretKey != "" && server.S3SecretKey == "") {
+		return nil, errors.New("S3密钥已加密，请先输入主密码解锁")
+	}
+
+	// 地址带协议时以协议为准
+	secure := server.S3UseSSL
+	if strings.HasPrefix(endpoint, "https://") {
+		secure = true
+	} else if strings.HasPrefix(endpoint, "http://") {
+		secure = false
+	}
+	endpoint = strings.TrimPrefix(strings.TrimPrefix(endpoint, "https://"), "http://")
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(server.S3AccessKey, server.S3SecretKey, ""),
+		Secure: secure,
+		Region: server.S3Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建S3客户端失败: %v", err)
+	}
+
+	return &s3Target{
+		client: client,
+		bucket: server.S3Bucket,
+		prefix: strings.Trim(strings.ReplaceAll(server.RemotePath, "\\", "/"), "/"),
+	}, nil
+}
+
+// 相对路径对应的对象键
+func (t *s3Target) objectKey(relPath string) string {
+	if t.prefix == "" {
+		return relPath
+	}
+	return t.prefix + "/" + relPath
+}
+
+func (t *s3Target) Check(ctx context.Context) error {
+	exists, err := t.client.BucketExists(ctx, t.bucket)
+	if err != nil {
+		return fmt.Errorf("连接S3失败: %v", err)
+	}
+	if !exists {
+		return fmt.Errorf("存储桶不存在: %s", t.bucket)
+	}
+	return nil
+}
+
+func (t *s3Target) ReadFile(ctx context.Context, relPath string) ([]byte, error) {
+	object, err := t.client.GetObject(ctx, t.bucket, t.objectKey(relPath), minio.GetObjectOptions{})
+	if err == nil {
+		defer object.Close()
+		var data []byte
+		if data, err = io.ReadAll(object); err == nil {
+			return data, nil
+		}
+	}
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return nil, os.ErrNotExist
+	}
+	return nil, err
+}
+
+// 上传文件，Content-Type 按扩展名识别
+func (t *s3Target) PutFile(ctx context.Context, relPath string, reader io.Reader, info os.FileInfo) error {
+	// 与 FPutObject 一样按扩展名设置内容类型，浏览器才能正确显示页面
+	contentType := mime.TypeByExtension(path.Ext(relPath))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	_, err := t.client.PutObject(ctx, t.bucket, t.objectKey(relPath), reader, info.Size(),
+		minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (t *s3Target) PutBytes(ctx context.Context, relPath string, data []byte) error {
+	_, err := t.client.PutObject(ctx, t.bucket, t.objectKey(relPath), bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	return err
+}
+
+func (t *s3Target) List(ctx context.Context) ([]string, error) {
+	prefix := ""
+	if t.prefix != "" {
+		prefix = t.prefix + "/"
+	}
+
+	var files []string
+	for object := range t.client.ListObjects(ctx, t.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		relPath := strings.TrimPrefix(object.Key, prefix)
+		if relPath != "" && !strings.HasSuffix(relPath, "/") {
+			files = append(files, path.Clean(relPath))
+		}
+	}
+	return files, nil
+}
+
+func (t *s3Target) Remove(ctx context.Context, relPaths []string) error {
+	objects := make(chan minio.ObjectInfo, len(relPaths))
+	for _, relPath := range relPaths {
+		objects <- minio.ObjectInfo{Key: t.objectKey(relPath)}
+	}
+	close(objects)
+
+	// 读完所有结果，只返回第一个错误
+	var firstErr error
+	for removeErr := range t.client.RemoveObjects(ctx, t.bucket, objects, minio.RemoveObjectsOptions{}) {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("删除对象 %s 失败: %v", removeErr.ObjectName, removeErr.Err)
+		}
+	}
+	return firstErr
+}
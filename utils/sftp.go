@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// 文件传输方式
+const (
+	TransferModeAuto  = "auto"  // 优先使用SFTP，服务器不支持时退回shell命令
+	TransferModeSFTP  = "sftp"  // 仅使用SFTP
+	TransferModeShell = "shell" // 通过 cat/mkdir/stat/touch 等shell命令传输
+	TransferModeTar   = "tar"   // 打包为gzip压缩的tar流批量上传，远程没有tar时按自动方式逐个上传
+)
+
+// 校验传输方式配置
+func ValidateTransferMode(mode string) error {
+	switch mode {
+	case "", TransferModeAuto, TransferModeSFTP, TransferModeShell, TransferModeTar:
+		return nil
+	}
+	return fmt.Errorf("不支持的传输方式: %s", mode)
+}
+
+// 按配置准备本次部署使用的传输后端
+// 返回 TransferModeSFTP 或 TransferModeShell
+func (c *SSHClient) prepareTransferBackend() (string, error) {
+	if c.transferMode == TransferModeShell {
+		return TransferModeShell, nil
+	}
+
+	if c.sftpClient == nil {
+		client, err := sftp.NewClient(c.client, sftp.UseConcurrentWrites(true))
+		if err != nil {
+			if c.transferMode == TransferModeSFTP {
+				return "", fmt.Errorf("无法启动SFTP子系统: %v", err)
+			}
+			fmt.Printf("SFTP不可用，改用shell命令传输: %v\n", err)
+			return TransferModeShell, nil
+		}
+		c.sftpClient = client
+	}
+
+	return TransferModeSFTP, nil
+}
+
+// 关闭SFTP会话
+func (c *SSHClient) closeSFTP() {
+	if c.sftpClient != nil {
+		c.sftpClient.Close()
+		c.sftpClient = nil
+	}
+}
+
+// 通过SFTP上传单个文件
+// 文件以流的方式写入同目录下的临时文件，设置权限和修改时间后再重命名为目标文件，
+// 避免网站在上传过程中读到不完整的内容
+func (c *SSHClient) uploadSingleFileSFTP(task FileTask) error {
+	localFile, err := os.Open(task.LocalFile)
+	if err != nil {
+		return err
+	}
+	defer localFile.Close()
+
+	localInfo, err := localFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	// 确保使用Unix路径分隔符（因为远程服务器是Linux）
+	remoteFile := strings.ReplaceAll(task.RemoteFile, "\\", "/")
+	remoteDir := path.Dir(remoteFile)
+
+	if err := c.makeRemoteDirectory(TransferModeSFTP, remoteDir); err != nil {
+		return fmt.Errorf("无法创建远程目录 %s: %v", remoteDir, c.analyzeUploadError(err, err.Error(), remoteFile))
+	}
+
+	tmpFile := path.Join(remoteDir, "."+path.Base(remoteFile)+".uploading")
+	remote, err := c.sftpClient.OpenFile(tmpFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return c.analyzeUploadError(err, err.Error(), remoteFile)
+	}
+
+	written, err := remote.ReadFrom(newThrottledReader(localFile, c.limiter, c.meter))
+	if closeErr := remote.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		c.sftpClient.Remove(tmpFile)
+		return c.analyzeUploadError(err, err.Error(), remoteFile)
+	}
+
+	if written != localInfo.Size() {
+		c.sftpClient.Remove(tmpFile)
+		return fmt.Errorf("文件上传验证失败: 期望 %d 字节，实际写入 %d 字节", localInfo.Size(), written)
+	}
+
+	// 设置权限和修改时间（未配置权限策略时失败不影响上传结果）
+	if err := c.sftpClient.Chmod(tmpFile, c.remoteFileMode(localInfo.Mode())); err != nil {
+		if c.fileMode != 0 {
+			c.sftpClient.Remove(tmpFile)
+			return fmt.Errorf("设置文件权限失败: %v", err)
+		}
+		fmt.Printf("设置文件权限失败 %s: %v\n", remoteFile, err)
+	}
+	if c.fileGroup != "" {
+		if _, err := c.runRemoteCommand(fmt.Sprintf("chgrp %s -- %s", shellQuote(c.fileGroup), shellQuote(tmpFile))); err != nil {
+			c.sftpClient.Remove(tmpFile)
+			return fmt.Errorf("设置文件属组失败: %v", err)
+		}
+	}
+	if err := c.sftpClient.Chtimes(tmpFile, localInfo.ModTime(), localInfo.ModTime()); err != nil {
+		fmt.Printf("设置文件时间失败 %s: %v\n", remoteFile, err)
+	}
+
+	// 原子替换目标文件；服务器不支持 posix-rename 扩展时先删除旧文件再重命名
+	if err := c.sftpClient.PosixRename(tmpFile, remoteFile); err != nil {
+		c.sftpClient.Remove(remoteFile)
+		if err := c.sftpClient.Rename(tmpFile, remoteFile); err != nil {
+			c.sftpClient.Remove(tmpFile)
+			return c.analyzeUploadError(err, err.Error(), remoteFile)
+		}
+	}
+
+	// 验证文件是否上传成功
+	remoteInfo, err := c.sftpClient.Stat(remoteFile)
+	if err != nil {
+		return fmt.Errorf("文件上传验证失败: %v", err)
+	}
+	if remoteInfo.Size() != localInfo.Size() {
+		return fmt.Errorf("文件上传验证失败: 文件大小不匹配，期望 %d 字节，实际 %d 字节", localInfo.Size(), remoteInfo.Size())
+	}
+
+	return nil
+}
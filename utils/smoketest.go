@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"hugo-manager-go/config"
+)
+
+// 部署后验证默认抽查的已上传页面数
+const DefaultSmokeTestSamples = 5
+
+// 部署后验证抽查页面数的上限
+const MaxSmokeTestSamples = 50
+
+// 部署后验证的请求超时和重试
+const (
+	smokeTestTimeout  = 15 * time.Second
+	smokeTestAttempts = 3               // 未通过时重试，等待缓存或同步生效
+	smokeTestRetryGap = 2 * time.Second // 重试间隔
+	smokeTestMaxBody  = 32 * 1024 * 1024
+)
+
+// 校验部署后验证配置
+func ValidateSmokeTest(server config.ServerConfig) error {
+	switch server.SmokeTestAction {
+	case "", config.SmokeTestActionWarn, config.SmokeTestActionFail, config.SmokeTestActionRollback:
+	default:
+		return fmt.Errorf("不支持的验证失败处理方式: %s", server.SmokeTestAction)
+	}
+	if server.SmokeTestSamples < 0 || server.SmokeTestSamples > MaxSmokeTestSamples {
+		return fmt.Errorf("抽查页面数必须在 0-%d 之间", MaxSmokeTestSamples)
+	}
+	if !server.SmokeTest {
+		return nil
+	}
+	if server.Domain == "" {
+		return fmt.Errorf("启用部署后验证需要填写网站域名")
+	}
+	_, err := smokeTestBaseURL(server.Domain)
+	return err
+}
+
+// 由域名得到网站根地址，未写协议时使用 https
+func smokeTestBaseURL(domain string) (*url.URL, error) {
+	domain = strings.TrimSpace(domain)
+	if !strings.Contains(domain, "://") {
+		domain = "https://" + domain
+	}
+	base, err := url.Parse(domain)
+	if err != nil || base.Host == "" || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil, fmt.Errorf("网站域名格式错误: %s", domain)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	return base, nil
+}
+
+// 本地文件对应的访问路径：目录下的 index.html 以目录访问
+func smokeTestURLPath(relPath string) string {
+	relPath = filepath.ToSlash(relPath)
+	if relPath == "index.html" {
+		return ""
+	}
+	if strings.HasSuffix(relPath, "/index.html") {
+		return strings.TrimSuffix(relPath, "index.html")
+	}
+	return relPath
+}
+
+// 选择要验证的文件：首页、站点地图，再从已上传的页面中均匀抽查
+func smokeTestFiles(publicDir string, uploaded []string, samples int) []string {
+	files := []string{"index.html"}
+	if _, err := os.Stat(filepath.Join(publicDir, "sitemap.xml")); err == nil {
+		files = append(files, "sitemap.xml")
+	}
+
+	var pages []string
+	for _, relPath := range uploaded {
+		relPath = filepath.ToSlash(relPath)
+		if strings.HasSuffix(relPath, ".html") && relPath != "index.html" {
+			pages = append(pages, relPath)
+		}
+	}
+	sort.Strings(pages)
+
+	if len(pages) > samples {
+		step := float64(len(pages)) / float64(samples)
+		picked := make([]string, 0, samples)
+		for i := 0; i < samples; i++ {
+			picked = append(picked, pages[int(float64(i)*step)])
+		}
+		pages = picked
+	}
+	return append(files, pages...)
+}
+
+// 部署后验证：通过服务器域名访问首页、站点地图和抽查的已上传页面，
+// 检查状态码为200且内容与本地 public/ 中的文件一致
+// uploaded 为本次上传的文件（相对 public/），为空时从所有本地页面中抽查
+func RunSmokeTest(server config.ServerConfig, publicDir string, uploaded []string) *config.SmokeTestResult {
+	result := &config.SmokeTestResult{CheckedAt: time.Now()}
+
+	base, err := smokeTestBaseURL(server.Domain)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	result.BaseURL = base.String()
+
+	if len(uploaded) == 0 {
+		filepath.Walk(publicDir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				if relPath, err := filepath.Rel(publicDir, path); err == nil {
+					uploaded = append(uploaded, relPath)
+				}
+			}
+			return nil
+		})
+	}
+
+	samples := server.SmokeTestSamples
+	if samples <= 0 {
+		samples = DefaultSmokeTestSamples
+	}
+
+	// 未通过的页面在所有页面检查完后统一重试
+	client := &http.Client{Timeout: smokeTestTimeout}
+	files := smokeTestFiles(publicDir, uploaded, samples)
+	result.Checks = make([]config.SmokeTestCheck, len(files))
+	pending := make([]int, len(files))
+	for i := range files {
+		pending[i] = i
+	}
+	for attempt := 1; attempt <= smokeTestAttempts && len(pending) > 0; attempt++ {
+		if attempt > 1 {
+			time.Sleep(smokeTestRetryGap)
+		}
+		var failed []int
+		for _, i := range pending {
+			result.Checks[i] = runSmokeTestCheck(client, base, publicDir, files[i])
+			if !result.Checks[i].Passed {
+				failed = append(failed, i)
+			}
+		}
+		pending = failed
+	}
+	failed := len(pending)
+
+	result.Passed = failed == 0
+	if result.Passed {
+		result.Message = fmt.Sprintf("部署后验证通过：检查了 %d 个页面", len(result.Checks))
+	} else {
+		result.Message = fmt.Sprintf("部署后验证失败：%d/%d 个页面不正确", failed, len(result.Checks))
+	}
+	return result
+}
+
+// 检查单个文件
+func runSmokeTestCheck(client *http.Client, base *url.URL, publicDir, relPath string) config.SmokeTestCheck {
+	target := base.ResolveReference(&url.URL{Path: smokeTestURLPath(relPath)})
+	check := config.SmokeTestCheck{
+		URL:       target.String(),
+		LocalFile: filepath.ToSlash(relPath),
+	}
+
+	localHash, err := HashFile(filepath.Join(publicDir, relPath))
+	if err != nil {
+		check.Error = fmt.Sprintf("读取本地文件失败: %v", err)
+		return check
+	}
+	check.LocalHash = localHash
+
+	check.StatusCode, check.RemoteHash, err = fetchSmokeTestURL(client, check.URL)
+	switch {
+	case err != nil:
+		check.Error = fmt.Sprintf("请求失败: %v", err)
+	case check.StatusCode != http.StatusOK:
+		check.Error = fmt.Sprintf("状态码 %d", check.StatusCode)
+	case check.RemoteHash != check.LocalHash:
+		check.Error = "内容与本地文件不一致"
+	default:
+		check.Passed = true
+	}
+	return check
+}
+
+// 请求URL，返回状态码和响应内容的SHA-256
+func fetchSmokeTestURL(client *http.Client, target string) (int, string, error) {
+	request, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	// 绕过缓存，确认读取到的是刚部署的内容
+	request.Header.Set("Cache-Control", "no-cache")
+	request.Header.Set("User-Agent", "hugo-manager-smoke-test")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, "", err
+	}
+	defer response.Body.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, io.LimitReader(response.Body, smokeTestMaxBody)); err != nil {
+		return response.StatusCode, "", err
+	}
+	return response.StatusCode, hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"hugo-manager-go/config"
+)
+
+// 服务器上保存快照的目录：与远程路径同级，避免被网站访问或被镜像删除
+const snapshotDirSuffix = ".snapshots"
+
+// 校验部署前快照配置
+func ValidateSnapshotMode(mode string, keep int, releaseMode bool) error {
+	switch mode {
+	case "":
+		return nil
+	case config.SnapshotModeRemote, config.SnapshotModeLocal:
+	default:
+		return fmt.Errorf("不支持的快照方式: %s", mode)
+	}
+	if releaseMode {
+		return fmt.Errorf("版本目录模式已保留历史版本，不需要部署前快照")
+	}
+	if keep < 0 || keep > 100 {
+		return fmt.Errorf("保留快照数必须在 0 到 100 之间（0 表示默认 %d 个）", config.DefaultKeepSnapshots)
+	}
+	return nil
+}
+
+// 获取生效的保留快照数
+func effectiveKeepSnapshots(keep int) int {
+	if keep <= 0 {
+		return config.DefaultKeepSnapshots
+	}
+	return keep
+}
+
+// 服务器上的快照目录
+func remoteSnapshotDir(remotePath string) string {
+	return remotePath + snapshotDirSuffix
+}
+
+// 执行远程命令，stdin 和 stdout 可以为 nil
+func (c *SSHClient) runStreamCommand(cmd string, stdin io.Reader, stdout io.Writer) error {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	var stderr strings.Builder
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = &stderr
+
+	if err := session.Run(cmd); err != nil {
+		if stderr.Len() > 0 {
+			return fmt.Errorf("%v, 详情: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return err
+	}
+	return nil
+}
+
+// 打包远程路径，按配置保存在服务器上或下载到本地，并清理超出数量的旧快照
+// 远程路径不存在或为空时没有需要保护的内容，返回 nil
+func (c *SSHClient) createSnapshot(remotePath, serverID, mode string, keep int, manual bool) (*config.Snapshot, error) {
+	remotePath = strings.TrimSuffix(strings.ReplaceAll(remotePath, "\\", "/"), "/")
+	if remotePath == "" {
+		return nil, fmt.Errorf("远程路径无效，无法创建快照")
+	}
+	root := shellQuote(remotePath)
+
+	output, err := c.runRemoteCommand(fmt.Sprintf("if [ -d %s ] && [ -n \"$(ls -A %s)\" ]; then echo yes; fi", root, root))
+	if err != nil {
+		return nil, fmt.Errorf("检查远程目录失败: %v", err)
+	}
+	if strings.TrimSpace(output) != "yes" {
+		return nil, nil
+	}
+
+	snapshot := config.Snapshot{
+		ID:         config.GenerateSnapshotID(),
+		ServerID:   serverID,
+		Location:   mode,
+		RemotePath: remotePath,
+		Manual:     manual,
+		CreatedAt:  time.Now(),
+	}
+
+	switch mode {
+	case config.SnapshotModeRemote:
+		snapshot.RemoteFile = path.Join(remoteSnapshotDir(remotePath), snapshot.ID+".tar.gz")
+		file := shellQuote(snapshot.RemoteFile)
+		tmpFile := shellQuote(snapshot.RemoteFile + ".tmp")
+		output, err := c.runRemoteCommand(fmt.Sprintf("mkdir -p %s && tar -czf %s -C %s . && mv -f %s %s && wc -c < %s",
+			shellQuote(remoteSnapshotDir(remotePath)), tmpFile, root, tmpFile, file, file))
+		if err != nil {
+			c.runRemoteCommand("rm -f " + tmpFile)
+			return nil, fmt.Errorf("打包远程目录失败: %v", err)
+		}
+		snapshot.Size, _ = strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	case config.SnapshotModeLocal:
+		archive := config.SnapshotArchivePath(serverID, snapshot.ID)
+		if err := os.MkdirAll(filepath.Dir(archive), 0755); err != nil {
+			return nil, err
+		}
+		file, err := os.Create(archive + ".tmp")
+		if err != nil {
+			return nil, err
+		}
+		err = c.runStreamCommand(fmt.Sprintf("tar -czf - -C %s .", root), nil, file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(archive+".tmp", archive)
+		}
+		if err != nil {
+			os.Remove(archive + ".tmp")
+			return nil, fmt.Errorf("下载远程目录快照失败: %v", err)
+		}
+		if info, err := os.Stat(archive); err == nil {
+			snapshot.Size = info.Size()
+		}
+	default:
+		return nil, fmt.Errorf("不支持的快照方式: %s", mode)
+	}
+
+	if err := config.SaveSnapshot(snapshot); err != nil {
+		return nil, fmt.Errorf("保存快照信息失败: %v", err)
+	}
+
+	// 清理旧快照失败不影响本次快照
+	snapshots := config.ListSnapshots(serverID)
+	for i := effectiveKeepSnapshots(keep); i < len(snapshots); i++ {
+		if err := c.deleteSnapshot(snapshots[i]); err != nil {
+			fmt.Printf("清理旧快照失败: %v\n", err)
+		}
+	}
+	return &snapshot, nil
+}
+
+// 删除快照：服务器上的快照文件和本地快照信息
+func (c *SSHClient) deleteSnapshot(snapshot config.Snapshot) error {
+	if snapshot.Location == config.SnapshotModeRemote && snapshot.RemoteFile != "" {
+		if _, err := c.runRemoteCommand("rm -f " + shellQuote(snapshot.RemoteFile)); err != nil {
+			return fmt.Errorf("删除服务器上的快照失败: %v", err)
+		}
+	}
+	return config.DeleteSnapshotRecord(snapshot.ServerID, snapshot.ID)
+}
+
+// 用快照替换远程路径的内容：先解压到临时目录确认快照完整，再清空远程路径并复制
+func (c *SSHClient) restoreSnapshot(snapshot config.Snapshot) error {
+	remotePath := snapshot.RemotePath
+	if remotePath == "" || remotePath == "/" {
+		return fmt.Errorf("远程路径无效: %s", remotePath)
+	}
+	root := shellQuote(remotePath)
+	tmpDir := shellQuote(remotePath + ".restore-" + snapshot.ID)
+
+	var (
+		extract string
+		stdin   io.Reader
+	)
+	switch snapshot.Location {
+	case config.SnapshotModeRemote:
+		extract = fmt.Sprintf("tar -xzpf %s -C %s", shellQuote(snapshot.RemoteFile), tmpDir)
+	case config.SnapshotModeLocal:
+		file, err := os.Open(config.SnapshotArchivePath(snapshot.ServerID, snapshot.ID))
+		if err != nil {
+			return fmt.Errorf("读取本地快照失败: %v", err)
+		}
+		defer file.Close()
+		extract = fmt.Sprintf("tar -xzpf - -C %s", tmpDir)
+		stdin = file
+	default:
+		return fmt.Errorf("不支持的快照方式: %s", snapshot.Location)
+	}
+
+	cmd := fmt.Sprintf("rm -rf %s && mkdir -p %s %s && %s && find %s -mindepth 1 -delete && cp -a %s/. %s/ && rm -rf %s",
+		tmpDir, tmpDir, root, extract, root, tmpDir, root, tmpDir)
+	if err := c.runStreamCommand(cmd, stdin, nil); err != nil {
+		c.runRemoteCommand("rm -rf " + tmpDir)
+		return fmt.Errorf("恢复快照失败: %v", err)
+	}
+	// 恢复后的内容与内容清单不再一致
+	c.removeDeployManifest(remotePath, snapshot.ServerID)
+	return nil
+}
+
+// 便捷函数：立即为服务器创建快照，远程路径为空时返回 nil
+func CreateServerSnapshot(sshConfig config.SSHConfig, serverID string) (*config.Snapshot, error) {
+	mode := sshConfig.SnapshotMode
+	if mode == "" {
+		mode = config.SnapshotModeRemote
+	}
+	var snapshot *config.Snapshot
+	err := withReleaseClient(sshConfig, func(client *SSHClient, remotePath string) error {
+		var err error
+		snapshot, err = client.createSnapshot(remotePath, serverID, mode, sshConfig.KeepSnapshots, true)
+		return err
+	})
+	return snapshot, err
+}
+
+// 便捷函数：恢复快照
+func RestoreSnapshot(sshConfig config.SSHConfig, snapshot config.Snapshot) error {
+	return withReleaseClient(sshConfig, func(client *SSHClient, remotePath string) error {
+		return client.restoreSnapshot(snapshot)
+	})
+}
+
+// 便捷函数：删除快照，本地快照不需要连接服务器
+func DeleteSnapshot(sshConfig config.SSHConfig, snapshot config.Snapshot) error {
+	if snapshot.Location != config.SnapshotModeRemote {
+		return config.DeleteSnapshotRecord(snapshot.ServerID, snapshot.ID)
+	}
+	return withReleaseClient(sshConfig, func(client *SSHClient, remotePath string) error {
+		return client.deleteSnapshot(snapshot)
+	})
+}
+
+// 便捷函数：将服务器上的快照文件写入 w（用于下载）
+func StreamRemoteSnapshot(sshConfig config.SSHConfig, snapshot config.Snapshot, w io.Writer) error {
+	return withReleaseClient(sshConfig, func(client *SSHClient, remotePath string) error {
+		return client.runStreamCommand("cat "+shellQuote(snapshot.RemoteFile), nil, w)
+	})
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"crypto/rand"
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -13,16 +15,51 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 	"hugo-manager-go/config"
 )
 
 // SSHClient 包装了SSH连接和相关方法
 type SSHClient struct {
-	client *ssh.Client
-	config *ssh.ClientConfig
-	host   string
-	port   int
+	client       *ssh.Client
+	config       *ssh.ClientConfig
+	host         string
+	port         int
+	hostKey      string       // 握手时服务器出示的主机公钥
+	transferMode string       // 配置的文件传输方式
+	sftpClient   *sftp.Client // 使用SFTP传输时的会话
+	
+	mirrorDeletions bool     // 部署时删除本地已不存在的远程文件
+	protectedPaths  []string // 镜像删除时保留的远程路径
+	releaseMode     bool     // 版本目录模式
+	keepReleases    int      // 保留的历史版本数
+	snapshotMode    string   // 部署前快照的保存位置，为空时不创建
+	keepSnapshots   int      // 保留的快照数
+	agentConn       net.Conn // SSH代理连接
+	via             *SSHClient // 跳板机，为 nil 时直接连接
+	preDeployHooks  []config.DeployHook // 传输文件前执行的命令
+	postDeployHooks []config.DeployHook // 传输文件后执行的命令
+	serverID        string   // 多服务器部署的服务器ID，用于区分各服务器的上传任务
+	uploadWorkers   int      // 并发上传数
+	
+	excludePatterns []string      // 服务器的部署排除规则
+	includePatterns []string      // 服务器的部署包含规则，覆盖排除规则
+	ignore          *DeployIgnore // 当前部署生效的排除规则
+	
+	fileMode          os.FileMode     // 上传文件的权限，0 表示与本地文件一致
+	dirMode           os.FileMode     // 创建目录的权限，0 表示不修改
+	fileGroup         string          // 上传文件和目录的属组，为空时不修改
+	permissionRoot    string          // 当前传输的远程根目录
+	preparedDirs      map[string]bool // 已设置过权限的目录
+	preparedDirsMutex sync.Mutex
+	
+	limiter *bandwidthLimiter // 所有上传共享的带宽限制，nil 表示不限速
+	meter   *transferMeter    // 当前传输的字节计量
+	
+	uploadErrors      map[string]string // 远程文件 -> 最近一次上传错误
+	uploaded          map[string]bool   // 本次传输中已上传成功的远程文件
+	uploadErrorsMutex sync.Mutex        // 保护 uploadErrors 和 uploaded
 }
 
 // DeployResult 部署结果
@@ -31,49 +68,81 @@ type DeployResult struct {
 	Message          string
 	Output           string
 	FilesDeployed    int
+	FilesDeleted     int // 镜像删除的远程文件数
+	FilesFailed      int // 重试后仍上传失败的文件数
 	BytesTransferred int64
+	Speed            string // 平均传输速度
+	HostKey          string // 本次连接服务器出示的主机公钥
+	Release          string // 版本目录模式下本次部署切换到的版本
+	Snapshot         string // 部署前创建的快照
+	UploadedFiles    []string                     // 上传成功的文件（相对部署目录）
+	FailedFiles      []config.DeploymentFileError // 上传失败的文件及原因
+	DeletedFiles     []string                     // 镜像删除的远程文件
+	Hooks            []config.DeployHookResult    // 部署前后命令的执行结果
 }
 
 // 创建SSH客户端
 func NewSSHClient(sshConfig config.SSHConfig) (*SSHClient, error) {
-	var auth []ssh.AuthMethod
+	if err := ValidateTransferMode(sshConfig.TransferMode); err != nil {
+		return nil, err
+	}
 	
-	if sshConfig.KeyPath != "" {
-		// 使用私钥认证
-		key, err := os.ReadFile(sshConfig.KeyPath)
-		if err != nil {
-			return nil, fmt.Errorf("无法读取私钥文件: %v", err)
-		}
-		
-		signer, err := ssh.ParsePrivateKey(key)
-		if err != nil {
-			return nil, fmt.Errorf("无法解析私钥: %v", err)
-		}
+	sshClient := &SSHClient{
+		host:         sshConfig.Host,
+		port:         sshConfig.Port,
+		transferMode: sshConfig.TransferMode,
 		
-		auth = []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		}
-	} else if sshConfig.Password != "" {
-		// 使用密码认证
-		auth = []ssh.AuthMethod{
-			ssh.Password(sshConfig.Password),
-		}
-	} else {
-		return nil, fmt.Errorf("必须提供密钥文件或密码")
+		mirrorDeletions: sshConfig.MirrorDeletions,
+		protectedPaths:  sshConfig.ProtectedPaths,
+		releaseMode:     sshConfig.ReleaseMode,
+		keepReleases:    sshConfig.KeepReleases,
+		snapshotMode:    sshConfig.SnapshotMode,
+		keepSnapshots:   sshConfig.KeepSnapshots,
+		uploadWorkers:   effectiveUploadWorkers(sshConfig.UploadWorkers),
+		limiter:         newBandwidthLimiter(sshConfig.BandwidthLimit),
+		preDeployHooks:  sshConfig.PreDeployHooks,
+		postDeployHooks: sshConfig.PostDeployHooks,
+		excludePatterns: sshConfig.ExcludePatterns,
+		includePatterns: sshConfig.IncludePatterns,
+	}
+	
+	sshClient.setPermissionPolicy(sshConfig)
+	
+	auth, err := sshClient.authMethods(sshConfig)
+	if err != nil {
+		return nil, err
+	}
+	
+	// 校验主机密钥：首次连接时信任并记录，之后必须与已信任的密钥一致
+	hostKeyCallback, err := newHostKeyCallback(sshConfig.HostKey, &sshClient.hostKey)
+	if err != nil {
+		return nil, err
+	}
+	
+	sshClient.config = &ssh.ClientConfig{
+		User:              sshConfig.Username,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms(sshConfig.HostKey),
+		Timeout:           15 * time.Second,
 	}
 	
-	clientConfig := &ssh.ClientConfig{
-		User: sshConfig.Username,
-		Auth: auth,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 注意：生产环境应该验证主机密钥
-		Timeout: 15 * time.Second,
+	// 跳板机链：每一跳都经由前一跳连接，最后一跳转发到目标服务器
+	for _, hop := range sshConfig.JumpHosts {
+		hopClient, err := NewSSHClient(hop)
+		if err != nil {
+			return nil, fmt.Errorf("跳板机 %s 配置错误: %v", net.JoinHostPort(hop.Host, strconv.Itoa(hop.Port)), err)
+		}
+		hopClient.via = sshClient.via
+		sshClient.via = hopClient
 	}
 	
-	return &SSHClient{
-		config: clientConfig,
-		host:   sshConfig.Host,
-		port:   sshConfig.Port,
-	}, nil
+	return sshClient, nil
+}
+
+// 获取握手时服务器出示的主机公钥
+func (c *SSHClient) HostKey() string {
+	return c.hostKey
 }
 
 // 连接到SSH服务器
@@ -81,15 +150,16 @@ func (c *SSHClient) Connect(ctx context.Context) error {
 	addr := net.JoinHostPort(c.host, strconv.Itoa(c.port))
 	
 	// 创建带超时的连接
-	conn, err := net.DialTimeout("tcp", addr, c.config.Timeout)
+	conn, err := c.dial(ctx, addr)
 	if err != nil {
-		return fmt.Errorf("无法连接到 %s: %v", addr, err)
+		return err
 	}
 	
 	// 检查上下文是否已取消
 	select {
 	case <-ctx.Done():
 		conn.Close()
+		c.Close()
 		return ctx.Err()
 	default:
 	}
@@ -98,19 +168,52 @@ func (c *SSHClient) Connect(ctx context.Context) error {
 	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, c.config)
 	if err != nil {
 		conn.Close()
-		return fmt.Errorf("SSH握手失败: %v", err)
+		c.Close()
+		return fmt.Errorf("SSH握手失败: %w", err)
 	}
 	
 	c.client = ssh.NewClient(sshConn, chans, reqs)
 	return nil
 }
 
+// 建立到服务器的TCP连接：配置了跳板机时先连接跳板机，再由跳板机转发
+// 跳板机的错误不保留主机密钥不匹配的类型，避免被记录为目标服务器的待确认密钥
+func (c *SSHClient) dial(ctx context.Context, addr string) (net.Conn, error) {
+	if c.via == nil {
+		conn, err := net.DialTimeout("tcp", addr, c.config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("无法连接到 %s: %v", addr, err)
+		}
+		return conn, nil
+	}
+	
+	jumpAddr := net.JoinHostPort(c.via.host, strconv.Itoa(c.via.port))
+	if err := c.via.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("连接跳板机 %s 失败: %v", jumpAddr, err)
+	}
+	
+	dialCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+	conn, err := c.via.client.DialContext(dialCtx, "tcp", addr)
+	if err != nil {
+		c.via.Close()
+		return nil, fmt.Errorf("经由跳板机 %s 无法连接到 %s: %v", jumpAddr, addr, err)
+	}
+	return conn, nil
+}
+
 // 关闭连接
 func (c *SSHClient) Close() error {
+	c.closeSFTP()
+	c.closeAgent()
+	var err error
 	if c.client != nil {
-		return c.client.Close()
+		err = c.client.Close()
 	}
-	return nil
+	if c.via != nil {
+		c.via.Close()
+	}
+	return err
 }
 
 // 测试SSH连接
@@ -173,12 +276,17 @@ func (c *SSHClient) ExecuteRsync(ctx context.Context, localPath, remotePath stri
 		}, err
 	}
 	
+	if c.releaseMode {
+		return c.deployRelease(ctx, localPath, remotePath, incremental, "", "")
+	}
+	
 	// 使用tar进行文件传输（更可靠的方法）
 	return c.transferFilesWithServer(ctx, localPath, remotePath, incremental, "", "")
 }
 
 // 执行rsync命令进行文件同步（支持服务器信息）
 func (c *SSHClient) ExecuteRsyncWithServer(ctx context.Context, localPath, remotePath string, incremental bool, serverID, serverName string) (*DeployResult, error) {
+	c.serverID = serverID
 	if err := c.Connect(ctx); err != nil {
 		return &DeployResult{
 			Success: false,
@@ -211,8 +319,64 @@ func (c *SSHClient) ExecuteRsyncWithServer(ctx context.Context, localPath, remot
 		}, err
 	}
 
-	// 使用tar进行文件传输（更可靠的方法）
-	return c.transferFilesWithServer(ctx, localPath, remotePath, incremental, serverID, serverName)
+	// 覆盖远程文件前创建快照，快照失败时不部署
+	var snapshot *config.Snapshot
+	if c.snapshotMode != "" && serverID != "" && !c.releaseMode {
+		if serverName != "" {
+			BroadcastMultiServerDeployProgress(serverID, serverName, "正在创建部署前快照...", 0, 0, 0, "")
+		}
+		var err error
+		snapshot, err = c.createSnapshot(remotePath, serverID, c.snapshotMode, c.keepSnapshots, false)
+		if err != nil {
+			return &DeployResult{
+				Success: false,
+				Message: fmt.Sprintf("创建部署前快照失败: %v", err),
+			}, err
+		}
+	}
+
+	// 部署前命令失败时不传输文件
+	preHooks, err := c.runHooks(ctx, config.HookStagePre, c.preDeployHooks, remotePath, serverID, serverName)
+	if err != nil {
+		result := &DeployResult{
+			Success: false,
+			Message: fmt.Sprintf("部署前命令失败: %v", err),
+			Hooks:   preHooks,
+		}
+		appendHookOutput(result, preHooks)
+		return result, err
+	}
+
+	var result *DeployResult
+	if c.releaseMode {
+		result, err = c.deployRelease(ctx, localPath, remotePath, incremental, serverID, serverName)
+	} else {
+		// 使用tar进行文件传输（更可靠的方法）
+		result, err = c.transferFilesWithServer(ctx, localPath, remotePath, incremental, serverID, serverName)
+	}
+	if result == nil {
+		return result, err
+	}
+	result.Hooks = preHooks
+	appendHookOutput(result, preHooks)
+	if snapshot != nil {
+		result.Snapshot = snapshot.ID
+		result.Output += fmt.Sprintf("，部署前快照 %s", snapshot.ID)
+	}
+	if err != nil || !result.Success {
+		return result, err
+	}
+
+	// 部署后命令的退出状态决定部署是否成功
+	postHooks, err := c.runHooks(ctx, config.HookStagePost, c.postDeployHooks, remotePath, serverID, serverName)
+	result.Hooks = append(result.Hooks, postHooks...)
+	appendHookOutput(result, postHooks)
+	if err != nil {
+		result.Success = false
+		result.Message = fmt.Sprintf("文件已传输，但部署后命令失败: %v", err)
+		return result, err
+	}
+	return result, nil
 }
 
 // 确保远程目录存在
@@ -223,7 +387,7 @@ func (c *SSHClient) ensureRemoteDirectory(remotePath string) error {
 	}
 	defer session.Close()
 	
-	cmd := fmt.Sprintf("mkdir -p %s", remotePath)
+	cmd := fmt.Sprintf("mkdir -p %s", shellQuote(remotePath))
 	return session.Run(cmd)
 }
 
@@ -233,6 +397,9 @@ type FileTask struct {
 	RemoteFile string
 	Size       int64
 	ModTime    time.Time
+	Backend    string // 传输后端: TransferModeSFTP 或 TransferModeShell
+	RelPath    string // 相对部署目录的路径（内容清单的键）
+	Hash       string // 本地文件的SHA-256，为空时在更新清单时计算
 }
 
 // 使用并发传输文件
@@ -244,11 +411,34 @@ func (c *SSHClient) transferFiles(ctx context.Context, localPath, remotePath str
 func (c *SSHClient) transferFilesWithServer(ctx context.Context, localPath, remotePath string, incremental bool, serverID, serverName string) (*DeployResult, error) {
 	result := &DeployResult{}
 	
+	// 选择传输后端：优先SFTP，服务器不支持时退回shell命令
+	backend, err := c.prepareTransferBackend()
+	if err != nil {
+		return &DeployResult{
+			Success: false,
+			Message: fmt.Sprintf("准备文件传输失败: %v", err),
+		}, err
+	}
+	
+	// 新建的目录从远程根目录开始按权限策略设置
+	c.setPermissionRoot(remotePath)
+	
+	// 加载部署排除规则，收集文件和镜像删除都按此过滤
+	if c.ignore, err = LoadDeployIgnore(c.excludePatterns, c.includePatterns); err != nil {
+		return &DeployResult{
+			Success: false,
+			Message: fmt.Sprintf("加载部署排除规则失败: %v", err),
+		}, err
+	}
+	
+	// 读取上次部署的内容清单，用于增量比较和部署后更新
+	manifest := c.loadDeployManifest(remotePath, serverID)
+	
 	// 检查是否有未完成的上传任务
-	existingTasks := config.GetUploadTasks()
+	existingTasks := config.GetServerUploadTasks(c.serverID)
 	var fileTasks []FileTask
 	
-	if len(existingTasks) > 0 && !config.IsDeploymentPaused() {
+	if len(existingTasks) > 0 && !config.IsServerDeploymentPaused(c.serverID) {
 		// 使用现有任务列表，过滤掉已完成的
 		fmt.Println("发现未完成的上传任务，继续上传...")
 		for _, uploadTask := range existingTasks {
@@ -258,13 +448,13 @@ func (c *SSHClient) transferFilesWithServer(ctx context.Context, localPath, remo
 					RemoteFile: uploadTask.RemoteFile,
 					Size:       uploadTask.Size,
 					ModTime:    uploadTask.CreatedAt,
+					RelPath:    remoteRelPath(remotePath, uploadTask.RemoteFile),
 				})
 			}
 		}
 	} else {
 		// 收集需要传输的文件
-		var err error
-		fileTasks, err = c.collectFileTasks(localPath, remotePath, incremental)
+		fileTasks, err = c.collectFileTasks(localPath, remotePath, incremental, manifest)
 		if err != nil {
 			return &DeployResult{
 				Success: false,
@@ -272,22 +462,42 @@ func (c *SSHClient) transferFilesWithServer(ctx context.Context, localPath, remo
 			}, err
 		}
 		
-		// 保存任务列表以便恢复
+		// 保存任务列表以便恢复，重新收集后不再处于暂停状态
 		c.saveUploadTasks(fileTasks)
+		config.SetServerDeploymentPaused(c.serverID, false)
 	}
 	
 	if len(fileTasks) == 0 {
-		config.RemoveCompletedTasks()
+		config.RemoveServerCompletedTasks(c.serverID)
+		
+		// 没有文件需要上传时，仍然需要同步删除
+		deleted, err := c.runMirrorDeletions(localPath, remotePath, manifest, serverID, serverName)
+		if err != nil {
+			return &DeployResult{
+				Success: false,
+				Message: fmt.Sprintf("镜像删除失败: %v", err),
+			}, err
+		}
+		// 没有变化时也保存，部署目录中的旧清单会被迁移到部署目录之外
+		manifestErr := c.saveDeployManifest(remotePath, serverID, manifest)
+		
 		result.Success = true
 		result.Message = "没有文件需要传输"
 		result.Output = "所有文件都是最新的"
+		if len(deleted) > 0 {
+			result.Output += fmt.Sprintf("，删除了 %d 个远程文件", len(deleted))
+		}
+		result.Message += manifestWarning(manifestErr)
 		result.FilesDeployed = 0
+		result.FilesDeleted = len(deleted)
+		result.DeletedFiles = deleted
 		result.BytesTransferred = 0
 		return result, nil
 	}
 	
-	// 设置为非暂停状态
-	config.SetDeploymentPaused(false)
+	for i := range fileTasks {
+		fileTasks[i].Backend = backend
+	}
 	
 	// 广播部署开始
 	if serverID != "" && serverName != "" {
@@ -296,9 +506,22 @@ func (c *SSHClient) transferFilesWithServer(ctx context.Context, localPath, remo
 		BroadcastDeployProgress("正在准备文件传输...", 0, len(fileTasks), 0, "")
 	}
 	
-	// 并发传输文件
-	err := c.transferFilesConcurrentlyWithServer(ctx, fileTasks, serverID, serverName)
+	// 传输文件：批量模式打包上传，否则并发逐个上传
+	c.meter = newTransferMeter()
+	c.uploadErrorsMutex.Lock()
+	c.uploaded = make(map[string]bool, len(fileTasks))
+	c.uploadErrorsMutex.Unlock()
+	if c.transferMode == TransferModeTar {
+		err = c.transferFilesBatched(ctx, remotePath, fileTasks, serverID, serverName)
+	} else {
+		err = c.transferFilesConcurrentlyWithServer(ctx, fileTasks, serverID, serverName)
+	}
+	
+	// 无论是否中断，都记录已成功上传的文件
+	manifest = c.updateManifestFromTasks(manifest, fileTasks)
+	
 	if err != nil {
+		c.saveDeployManifest(remotePath, serverID, manifest)
 		BroadcastError("deploy", fmt.Sprintf("文件传输失败: %v", err))
 		return &DeployResult{
 			Success: false,
@@ -306,14 +529,35 @@ func (c *SSHClient) transferFilesWithServer(ctx context.Context, localPath, remo
 		}, err
 	}
 	
+	// 上传完成后再删除远程多余文件，避免删除后新页面尚未上传
+	deleted, err := c.runMirrorDeletions(localPath, remotePath, manifest, serverID, serverName)
+	manifestErr := c.saveDeployManifest(remotePath, serverID, manifest)
+	if err != nil {
+		return &DeployResult{
+			Success: false,
+			Message: fmt.Sprintf("文件已上传，但镜像删除失败: %v", err),
+		}, err
+	}
+	
 	// 计算传输统计
 	var totalSize int64
 	for _, task := range fileTasks {
 		totalSize += task.Size
+		if c.isTaskCompleted(task) {
+			result.UploadedFiles = append(result.UploadedFiles, task.RelPath)
+		} else {
+			c.uploadErrorsMutex.Lock()
+			errMsg := c.uploadErrors[task.RemoteFile]
+			c.uploadErrorsMutex.Unlock()
+			result.FailedFiles = append(result.FailedFiles, config.DeploymentFileError{
+				File:  task.RelPath,
+				Error: errMsg,
+			})
+		}
 	}
 	
 	// 清理完成的任务
-	config.RemoveCompletedTasks()
+	config.RemoveServerCompletedTasks(c.serverID)
 	
 	// 广播完成消息
 	BroadcastComplete("deploy", 
@@ -323,14 +567,26 @@ func (c *SSHClient) transferFilesWithServer(ctx context.Context, localPath, remo
 	result.Success = true
 	result.Message = "文件传输完成"
 	result.Output = fmt.Sprintf("成功传输 %d 个文件，共 %d 字节", len(fileTasks), totalSize)
+	if len(deleted) > 0 {
+		result.Output += fmt.Sprintf("，删除了 %d 个远程文件", len(deleted))
+	}
 	result.FilesDeployed = len(fileTasks)
+	result.FilesDeleted = len(deleted)
+	result.FilesFailed = len(result.FailedFiles)
+	result.DeletedFiles = deleted
 	result.BytesTransferred = totalSize
+	if c.meter != nil {
+		result.Speed = c.meter.average()
+		result.Output += fmt.Sprintf("，平均速度 %s", result.Speed)
+	}
+	result.Message += manifestWarning(manifestErr)
 	
 	return result, nil
 }
 
 // 收集需要传输的文件任务
-func (c *SSHClient) collectFileTasks(localPath, remotePath string, incremental bool) ([]FileTask, error) {
+// 增量模式下只收集内容哈希与上次部署清单不一致的文件
+func (c *SSHClient) collectFileTasks(localPath, remotePath string, incremental bool, manifest *DeployManifest) ([]FileTask, error) {
 	var tasks []FileTask
 	
 	err := filepath.Walk(localPath, func(localFile string, info os.FileInfo, err error) error {
@@ -338,103 +594,63 @@ func (c *SSHClient) collectFileTasks(localPath, remotePath string, incremental b
 			return err
 		}
 		
-		// 跳过目录
-		if info.IsDir() {
-			return nil
-		}
-		
 		// 计算相对路径
 		relPath, err := filepath.Rel(localPath, localFile)
 		if err != nil {
 			return err
 		}
 		
+		relPath = filepath.ToSlash(relPath)
+		
+		// 跳过目录，整个目录被排除时不再进入
+		if info.IsDir() {
+			if relPath != "." && c.ignore.skipDir(relPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		
+		// 跳过被排除规则匹配的文件
+		if c.ignore.Match(relPath, false) {
+			fmt.Printf("跳过文件（排除规则）: %s\n", relPath)
+			return nil
+		}
+		
 		// 构建远程文件路径
 		remoteFile := filepath.Join(remotePath, relPath)
 		// 在Unix系统上使用正斜杠
 		remoteFile = strings.ReplaceAll(remoteFile, "\\", "/")
 		
-		// 检查是否需要传输
-		needTransfer := true
-		if incremental {
-			needTransfer, err = c.shouldTransferFile(localFile, remoteFile, info)
-			if err != nil {
-				fmt.Printf("检查文件失败 %s: %v\n", remoteFile, err)
-				// 如果检查失败，仍然传输文件
-				needTransfer = true
-			}
+		hash, err := HashFile(localFile)
+		if err != nil {
+			return fmt.Errorf("计算文件哈希失败 %s: %v", localFile, err)
 		}
 		
-		if needTransfer {
-			tasks = append(tasks, FileTask{
-				LocalFile:  localFile,
-				RemoteFile: remoteFile,
-				Size:       info.Size(),
-				ModTime:    info.ModTime(),
-			})
-		} else {
-			fmt.Printf("跳过文件（已是最新）: %s\n", remoteFile)
+		// 检查内容是否变化
+		if incremental && manifest.Files[relPath] == hash {
+			fmt.Printf("跳过文件（内容未变化）: %s\n", remoteFile)
+			return nil
 		}
 		
+		tasks = append(tasks, FileTask{
+			LocalFile:  localFile,
+			RemoteFile: remoteFile,
+			Size:       info.Size(),
+			ModTime:    info.ModTime(),
+			RelPath:    relPath,
+			Hash:       hash,
+		})
+		
 		return nil
 	})
 	
 	return tasks, err
 }
 
-// 检查文件是否需要传输
-func (c *SSHClient) shouldTransferFile(localFile, remoteFile string, localInfo os.FileInfo) (bool, error) {
-	// 检查远程文件是否存在
-	session, err := c.client.NewSession()
-	if err != nil {
-		return true, err
-	}
-	defer session.Close()
-	
-	// 使用stat命令获取远程文件信息
-	cmd := fmt.Sprintf("stat -c '%%s %%Y' %s 2>/dev/null || echo 'NOTEXIST'", remoteFile)
-	output, err := session.Output(cmd)
-	if err != nil {
-		return true, err
-	}
-	
-	result := strings.TrimSpace(string(output))
-	if result == "NOTEXIST" {
-		return true, nil // 远程文件不存在，需要传输
-	}
-	
-	// 解析远程文件信息
-	parts := strings.Fields(result)
-	if len(parts) != 2 {
-		return true, nil // 无法解析，传输文件
-	}
-	
-	remoteSize, err := strconv.ParseInt(parts[0], 10, 64)
-	if err != nil {
-		return true, nil
-	}
-	
-	remoteMtime, err := strconv.ParseInt(parts[1], 10, 64)
-	if err != nil {
-		return true, nil
-	}
-	
-	// 比较文件大小和修改时间
-	localSize := localInfo.Size()
-	localMtime := localInfo.ModTime().Unix()
-	
-	// 如果大小不同，需要传输
-	if localSize != remoteSize {
-		return true, nil
-	}
-	
-	// 如果本地文件更新，需要传输
-	if localMtime > remoteMtime {
-		return true, nil
-	}
-	
-	// 文件相同，不需要传输
-	return false, nil
+// 由远程文件路径计算相对部署目录的路径
+func remoteRelPath(remotePath, remoteFile string) string {
+	base := strings.TrimSuffix(strings.ReplaceAll(remotePath, "\\", "/"), "/") + "/"
+	return strings.TrimPrefix(strings.ReplaceAll(remoteFile, "\\", "/"), base)
 }
 
 // 保存上传任务列表
@@ -452,7 +668,7 @@ func (c *SSHClient) saveUploadTasks(fileTasks []FileTask) {
 			CreatedAt:  time.Now(),
 		})
 	}
-	config.SetUploadTasks(uploadTasks)
+	config.SetServerUploadTasks(c.serverID, uploadTasks)
 }
 
 // 生成任务ID
@@ -468,7 +684,13 @@ func (c *SSHClient) transferFilesConcurrently(ctx context.Context, tasks []FileT
 }
 
 func (c *SSHClient) transferFilesConcurrentlyWithServer(ctx context.Context, tasks []FileTask, serverID, serverName string) error {
-	const maxConcurrency = 4 // 最大并发数
+	maxConcurrency := c.uploadWorkers
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultUploadWorkers
+	}
+	if c.meter == nil {
+		c.meter = newTransferMeter()
+	}
 	
 	// 进度跟踪
 	var completedCount int32 = 0
@@ -478,9 +700,31 @@ func (c *SSHClient) transferFilesConcurrentlyWithServer(ctx context.Context, tas
 	// 创建工作池
 	taskChan := make(chan FileTask, len(tasks))
 	failedTasks := make(chan FileTask, len(tasks)) // 失败的任务用于重试
-	pauseChan := make(chan struct{}, 1)
+	pauseChan := make(chan struct{})
 	var wg sync.WaitGroup
 	
+	// 每秒采样一次传输速度，多服务器部署时同时更新服务器状态
+	var currentSpeed atomic.Value
+	currentSpeed.Store("")
+	stopSpeed := make(chan struct{})
+	defer close(stopSpeed)
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				speed := c.meter.sample()
+				currentSpeed.Store(speed)
+				if serverID != "" {
+					config.SetServerDeploymentSpeed(serverID, speed)
+				}
+			case <-stopSpeed:
+				return
+			}
+		}
+	}()
+	
 	// 启动暂停检查goroutine
 	go func() {
 		ticker := time.NewTicker(time.Second)
@@ -488,11 +732,9 @@ func (c *SSHClient) transferFilesConcurrentlyWithServer(ctx context.Context, tas
 		for {
 			select {
 			case <-ticker.C:
-				if config.IsDeploymentPaused() {
-					select {
-					case pauseChan <- struct{}{}:
-					default:
-					}
+				if config.IsServerDeploymentPaused(c.serverID) {
+					// 关闭通道，所有worker和任务分发都能收到暂停信号
+					close(pauseChan)
 					return
 				}
 			case <-ctx.Done():
@@ -536,6 +778,7 @@ func (c *SSHClient) transferFilesConcurrentlyWithServer(ctx context.Context, tas
 				}
 				
 				err := c.uploadSingleFile(task)
+				c.recordUploadError(task, err)
 				if err != nil {
 					// 记录失败，但不中断上传过程
 					atomic.AddInt32(&failedCount, 1)
@@ -567,19 +810,9 @@ func (c *SSHClient) transferFilesConcurrentlyWithServer(ctx context.Context, tas
 					
 					// 广播进度更新
 					progress := int(float64(completed) / float64(totalTasks) * 100)
-					if serverID != "" && serverName != "" {
-						BroadcastMultiServerDeployProgress(serverID, serverName,
-							fmt.Sprintf("已完成 %d/%d 文件", completed, totalTasks),
-							progress, totalTasks, int(completed), "")
-					} else {
-						BroadcastDeployProgress(
-							fmt.Sprintf("已完成 %d/%d 文件", completed, totalTasks),
-							progress,
-							totalTasks,
-							int(completed),
-							"",
-						)
-					}
+					BroadcastTransferProgress(serverID, serverName,
+						fmt.Sprintf("已完成 %d/%d 文件", completed, totalTasks),
+						progress, totalTasks, int(completed), "", currentSpeed.Load().(string))
 				}
 			}
 		}(i)
@@ -611,10 +844,12 @@ func (c *SSHClient) transferFilesConcurrentlyWithServer(ctx context.Context, tas
 	case <-done:
 		// 正常完成
 	case <-pauseChan:
-		// 暂停信号
-		return fmt.Errorf("上传已暂停")
+		// 暂停信号：等待正在上传的文件完成，之后连接会被关闭
+		<-done
+		return ErrDeploymentPaused
 	case <-ctx.Done():
 		// 上下文取消
+		<-done
 		return ctx.Err()
 	}
 	
@@ -691,16 +926,24 @@ func (c *SSHClient) transferFilesConcurrentlyWithServer(ctx context.Context, tas
 	return nil
 }
 
-// 检查任务是否已完成
+// 检查任务在本次传输中是否已上传成功
+// 以传输过程中的记录为准，上传队列可能在停止部署时被清空，不能据此判断
 func (c *SSHClient) isTaskCompleted(task FileTask) bool {
-	existingTasks := config.GetUploadTasks()
-	for _, uploadTask := range existingTasks {
-		if uploadTask.LocalFile == task.LocalFile && uploadTask.RemoteFile == task.RemoteFile {
-			return uploadTask.Completed
-		}
+	c.uploadErrorsMutex.Lock()
+	defer c.uploadErrorsMutex.Unlock()
+	return c.uploaded[task.RemoteFile]
+}
+
+// 记录任务已上传成功
+func (c *SSHClient) recordUploaded(tasks ...FileTask) {
+	c.uploadErrorsMutex.Lock()
+	defer c.uploadErrorsMutex.Unlock()
+	if c.uploaded == nil {
+		c.uploaded = make(map[string]bool)
+	}
+	for _, task := range tasks {
+		c.uploaded[task.RemoteFile] = true
 	}
-	// 如果找不到任务记录，可能已经被删除（表示完成）
-	return true
 }
 
 // 重试失败的任务
@@ -713,9 +956,9 @@ func (c *SSHClient) retryFailedTasks(ctx context.Context, failedTasks []FileTask
 	
 	for i, task := range failedTasks {
 		// 检查是否被暂停
-		if config.IsDeploymentPaused() {
+		if config.IsServerDeploymentPaused(c.serverID) {
 			fmt.Printf("重试过程中检测到暂停信号，停止重试\n")
-			return fmt.Errorf("重试已暂停")
+			return fmt.Errorf("重试过程中%w", ErrDeploymentPaused)
 		}
 		
 		fmt.Printf("重试第 %d/%d 个文件: %s\n", i+1, len(failedTasks), task.RemoteFile)
@@ -730,6 +973,7 @@ func (c *SSHClient) retryFailedTasks(ctx context.Context, failedTasks []FileTask
 		
 		// 重试上传
 		err := c.uploadSingleFile(task)
+		c.recordUploadError(task, err)
 		if err != nil {
 			atomic.AddInt32(&retryFailedCount, 1)
 			fmt.Printf("重试失败: %s -> %s, 错误: %v\n", task.LocalFile, task.RemoteFile, err)
@@ -768,13 +1012,29 @@ func (c *SSHClient) retryFailedTasks(ctx context.Context, failedTasks []FileTask
 	return nil
 }
 
+// 记录文件最近一次的上传结果，err 为 nil 时清除错误
+func (c *SSHClient) recordUploadError(task FileTask, err error) {
+	c.uploadErrorsMutex.Lock()
+	defer c.uploadErrorsMutex.Unlock()
+	
+	if err == nil {
+		delete(c.uploadErrors, task.RemoteFile)
+		return
+	}
+	if c.uploadErrors == nil {
+		c.uploadErrors = make(map[string]string)
+	}
+	c.uploadErrors[task.RemoteFile] = err.Error()
+}
+
 // 标记任务为已完成
 func (c *SSHClient) markTaskCompleted(task FileTask) {
-	existingTasks := config.GetUploadTasks()
+	c.recordUploaded(task)
+	existingTasks := config.GetServerUploadTasks(c.serverID)
 	found := false
 	for _, uploadTask := range existingTasks {
 		if uploadTask.LocalFile == task.LocalFile && uploadTask.RemoteFile == task.RemoteFile {
-			config.MarkTaskCompleted(uploadTask.ID)
+			config.MarkTaskCompleted(c.serverID, uploadTask.ID)
 			fmt.Printf("标记任务完成: %s\n", task.RemoteFile)
 			found = true
 			break
@@ -787,9 +1047,26 @@ func (c *SSHClient) markTaskCompleted(task FileTask) {
 	}
 }
 
+// 批量标记任务为已完成
+func (c *SSHClient) markTasksCompleted(tasks []FileTask) {
+	c.recordUploaded(tasks...)
+	completed := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		completed[task.LocalFile+"\x00"+task.RemoteFile] = true
+	}
+	
+	var taskIDs []string
+	for _, uploadTask := range config.GetServerUploadTasks(c.serverID) {
+		if completed[uploadTask.LocalFile+"\x00"+uploadTask.RemoteFile] {
+			taskIDs = append(taskIDs, uploadTask.ID)
+		}
+	}
+	config.MarkTasksCompleted(c.serverID, taskIDs)
+}
+
 // 删除指定文件的上传任务记录
 func (c *SSHClient) removeUploadTaskByFile(localFile, remoteFile string) {
-	existingTasks := config.GetUploadTasks()
+	existingTasks := config.GetServerUploadTasks(c.serverID)
 	var remainingTasks []config.UploadTask
 	
 	for _, uploadTask := range existingTasks {
@@ -800,7 +1077,7 @@ func (c *SSHClient) removeUploadTaskByFile(localFile, remoteFile string) {
 	}
 	
 	// 更新任务列表
-	config.SetUploadTasks(remainingTasks)
+	config.SetServerUploadTasks(c.serverID, remainingTasks)
 	fmt.Printf("删除无效上传记录: %s -> %s\n", localFile, remoteFile)
 }
 
@@ -814,6 +1091,15 @@ func (c *SSHClient) uploadSingleFile(task FileTask) error {
 		return nil // 返回nil表示任务处理完成（通过删除记录）
 	}
 	
+	// 大文件分块上传，中断后可以继续
+	if task.Size >= chunkedUploadThreshold {
+		return c.uploadChunked(task)
+	}
+	
+	if task.Backend == TransferModeSFTP && c.sftpClient != nil {
+		return c.uploadSingleFileSFTP(task)
+	}
+	
 	// 确保远程目录存在
 	remoteDir := filepath.Dir(task.RemoteFile)
 	// 确保使用Unix路径分隔符（因为远程服务器是Linux）
@@ -822,11 +1108,12 @@ func (c *SSHClient) uploadSingleFile(task FileTask) error {
 		return fmt.Errorf("无法创建远程目录 %s: %v", remoteDir, err)
 	}
 	
-	// 读取本地文件
-	localData, err := os.ReadFile(task.LocalFile)
+	// 打开本地文件，以流的方式写入远程
+	localFile, err := os.Open(task.LocalFile)
 	if err != nil {
 		return err
 	}
+	defer localFile.Close()
 	
 	// 创建SSH会话
 	session, err := c.client.NewSession()
@@ -839,10 +1126,16 @@ func (c *SSHClient) uploadSingleFile(task FileTask) error {
 	var stderr strings.Builder
 	session.Stderr = &stderr
 	
-	// 使用cat命令直接写入文件
+	// 使用cat命令写入临时文件后再重命名，避免网站读到不完整的内容
+	// 文件权限和属组在重命名前设置，网站不会读到权限不正确的文件
 	// 确保使用Unix路径分隔符（因为远程服务器是Linux）
 	remoteFile := strings.ReplaceAll(task.RemoteFile, "\\", "/")
-	cmd := fmt.Sprintf("cat > %s", remoteFile)
+	tmpFile := path.Join(path.Dir(remoteFile), "."+path.Base(remoteFile)+".uploading")
+	cmd := fmt.Sprintf("cat > %s", shellQuote(tmpFile))
+	if permissionCmd := c.filePermissionCommand(tmpFile); permissionCmd != "" {
+		cmd += " && " + permissionCmd
+	}
+	cmd += fmt.Sprintf(" && mv -f %s %s", shellQuote(tmpFile), shellQuote(remoteFile))
 	stdin, err := session.StdinPipe()
 	if err != nil {
 		return fmt.Errorf("创建stdin管道失败: %v", err)
@@ -853,7 +1146,7 @@ func (c *SSHClient) uploadSingleFile(task FileTask) error {
 	}
 	
 	// 写入文件内容
-	_, err = stdin.Write(localData)
+	_, err = io.Copy(stdin, newThrottledReader(localFile, c.limiter, c.meter))
 	if err != nil {
 		stdin.Close()
 		stderrOutput := stderr.String()
@@ -927,7 +1220,7 @@ func (c *SSHClient) verifyFileUpload(remoteFile string, expectedSize int64) erro
 	defer session.Close()
 	
 	// 检查文件大小
-	cmd := fmt.Sprintf("stat -c %%s %s 2>/dev/null || echo '0'", remoteFile)
+	cmd := fmt.Sprintf("stat -c %%s %s 2>/dev/null || echo '0'", shellQuote(remoteFile))
 	output, err := session.Output(cmd)
 	if err != nil {
 		return err
@@ -954,7 +1247,7 @@ func (c *SSHClient) setFileAttributes(remoteFile string, modTime time.Time) erro
 	defer session.Close()
 	
 	// 设置文件时间戳
-	touchCmd := fmt.Sprintf("touch -d '%s' %s", modTime.Format("2006-01-02 15:04:05"), remoteFile)
+	touchCmd := fmt.Sprintf("touch -d '%s' %s", modTime.Format("2006-01-02 15:04:05"), shellQuote(remoteFile))
 	session.Run(touchCmd)
 	
 	return nil
@@ -972,7 +1265,12 @@ func (c *SSHClient) createRemoteDirectory(remotePath string) error {
 	var stderr strings.Builder
 	session.Stderr = &stderr
 	
-	cmd := fmt.Sprintf("mkdir -p %s", remotePath)
+	// 按权限策略设置新目录及其上级目录的权限和属组
+	cmd := fmt.Sprintf("mkdir -p %s", shellQuote(remotePath))
+	dirs := c.unpreparedDirs(remotePath)
+	if permissionCmd := c.dirPermissionCommand(dirs); permissionCmd != "" {
+		cmd += " && " + permissionCmd
+	}
 	if err := session.Run(cmd); err != nil {
 		stderrOutput := stderr.String()
 		if stderrOutput != "" {
@@ -989,9 +1287,16 @@ func (c *SSHClient) createRemoteDirectory(remotePath string) error {
 		return fmt.Errorf("创建目录失败: %v", err)
 	}
 	
+	if c.dirMode != 0 || c.fileGroup != "" {
+		c.markDirsPrepared(dirs)
+	}
 	return nil
 }
 
+// 为shell命令参数加单引号，处理路径中的空格、引号和特殊字符
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
 
 // 计算本地文件统计
 func (c *SSHClient) calculateLocalStats(localPath string) (int, int64, error) {
@@ -1012,17 +1317,18 @@ func (c *SSHClient) calculateLocalStats(localPath string) (int, int64, error) {
 	return fileCount, totalSize, err
 }
 
-// 便捷函数：测试SSH连接
-func TestSSHConnection(sshConfig config.SSHConfig) error {
+// 便捷函数：测试SSH连接，返回服务器出示的主机公钥
+func TestSSHConnection(sshConfig config.SSHConfig) (string, error) {
 	client, err := NewSSHClient(sshConfig)
 	if err != nil {
-		return err
+		return "", err
 	}
 	
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 	
-	return client.TestConnection(ctx)
+	err = client.TestConnection(ctx)
+	return client.HostKey(), err
 }
 
 // 便捷函数：执行部署
@@ -1039,8 +1345,13 @@ func ExecuteDeploymentWithServer(sshConfig config.SSHConfig, localPath, remotePa
 		}, err
 	}
 	
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	timeout := deployTimeoutFor(localPath, sshConfig.BandwidthLimit) + totalHookTimeout(sshConfig.PreDeployHooks, sshConfig.PostDeployHooks)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	
-	return client.ExecuteRsyncWithServer(ctx, localPath, remotePath, incremental, serverID, serverName)
+	result, err := client.ExecuteRsyncWithServer(ctx, localPath, remotePath, incremental, serverID, serverName)
+	if result != nil {
+		result.HostKey = client.HostKey()
+	}
+	return result, err
 }
\ No newline at end of file
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"hugo-manager-go/config"
+)
+
+// ExpandHomePath 展开路径开头的 ~ 为用户主目录
+func ExpandHomePath(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, strings.TrimPrefix(p, "~"))
+}
+
+// 读取私钥，私钥已加密时使用私钥密码解密
+func loadPrivateKey(keyPath, passphrase string) (ssh.Signer, error) {
+	key, err := os.ReadFile(ExpandHomePath(keyPath))
+	if err != nil {
+		return nil, fmt.Errorf("无法读取私钥文件: %v", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, errors.New("私钥已加密，请填写私钥密码")
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+		if errors.Is(err, x509.IncorrectPasswordError) {
+			return nil, errors.New("私钥密码错误")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("无法解析私钥: %v", err)
+	}
+	return signer, nil
+}
+
+// 通过 SSH_AUTH_SOCK 连接SSH代理进行认证
+// 代理连接在握手时建立，保存在客户端中，关闭客户端时一并关闭
+func (c *SSHClient) agentAuthMethod() (ssh.AuthMethod, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("未找到SSH代理（SSH_AUTH_SOCK 未设置）")
+	}
+
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("无法连接SSH代理: %v", err)
+		}
+		c.closeAgent()
+		c.agentConn = conn
+		return agent.NewClient(conn).Signers()
+	}), nil
+}
+
+// 关闭SSH代理连接
+func (c *SSHClient) closeAgent() {
+	if c.agentConn != nil {
+		c.agentConn.Close()
+		c.agentConn = nil
+	}
+}
+
+// 按配置构建认证方式：私钥、SSH代理、密码依次尝试
+// 未配置私钥和密码时，如果存在SSH代理则自动使用
+func (c *SSHClient) authMethods(sshConfig config.SSHConfig) ([]ssh.AuthMethod, error) {
+	var auth []ssh.AuthMethod
+
+	if sshConfig.KeyPath != "" {
+		signer, err := loadPrivateKey(sshConfig.KeyPath, sshConfig.KeyPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	useAgent := sshConfig.UseAgent ||
+		(sshConfig.KeyPath == "" && sshConfig.Password == "" && os.Getenv("SSH_AUTH_SOCK") != "")
+	if useAgent {
+		method, err := c.agentAuthMethod()
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, method)
+	}
+
+	if sshConfig.Password != "" {
+		auth = append(auth, ssh.Password(sshConfig.Password))
+	}
+
+	if len(auth) == 0 {
+		return nil, errors.New("必须提供密钥文件、密码或启用SSH代理")
+	}
+	return auth, nil
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 从 ~/.ssh/config 解析出的主机
+type SSHConfigHost struct {
+	Alias        string `json:"alias"`
+	HostName     string `json:"host_name"`
+	Port         int    `json:"port"`
+	User         string `json:"user"`
+	IdentityFile string `json:"identity_file,omitempty"`
+	ProxyJump    string `json:"proxy_jump,omitempty"` // 直接经由的跳板机别名（多级跳板机取最后一级）
+}
+
+// ssh_config 中的一个 Host 块
+type sshConfigBlock struct {
+	patterns []string
+	options  [][2]string // 按出现顺序保存的 关键字/值
+}
+
+// 默认的SSH客户端配置文件路径
+func DefaultSSHConfigPath() string {
+	return ExpandHomePath("~/.ssh/config")
+}
+
+// 读取SSH客户端配置文件，返回所有可以导入的主机
+// 只导入不含通配符的 Host 别名；参数按 ssh 的规则取第一次出现的值，
+// 因此 "Host *" 之类的通用配置也会应用到各主机上。Match 块不支持，会被忽略
+func ParseSSHConfigFile(configPath string) ([]SSHConfigHost, error) {
+	if configPath == "" {
+		configPath = DefaultSSHConfigPath()
+	}
+	file, err := os.Open(ExpandHomePath(configPath))
+	if err != nil {
+		return nil, fmt.Errorf("无法读取SSH配置文件: %v", err)
+	}
+	defer file.Close()
+
+	var (
+		blocks  []*sshConfigBlock
+		current = &sshConfigBlock{patterns: []string{"*"}} // 第一个 Host 之前的参数适用于所有主机
+		skip    bool
+	)
+	blocks = append(blocks, current)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		keyword, value := parseSSHConfigLine(scanner.Text())
+		if keyword == "" {
+			continue
+		}
+		switch keyword {
+		case "host":
+			current = &sshConfigBlock{patterns: strings.Fields(value)}
+			blocks = append(blocks, current)
+			skip = false
+		case "match":
+			skip = true
+		default:
+			if !skip {
+				current.options = append(current.options, [2]string{keyword, value})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取SSH配置文件失败: %v", err)
+	}
+
+	var hosts []SSHConfigHost
+	seen := make(map[string]bool)
+	for _, block := range blocks {
+		for _, alias := range block.patterns {
+			if seen[alias] || strings.ContainsAny(alias, "*?!") {
+				continue
+			}
+			seen[alias] = true
+			hosts = append(hosts, resolveSSHConfigHost(alias, blocks))
+		}
+	}
+	return hosts, nil
+}
+
+// 拆分一行配置为小写关键字和值，支持 "Key Value" 和 "Key=Value" 两种写法
+func parseSSHConfigLine(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), ""
+	}
+	keyword := strings.ToLower(line[:end])
+	value := strings.TrimLeft(line[end:], " \t")
+	value = strings.TrimPrefix(value, "=")
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	return keyword, value
+}
+
+// 主机别名是否匹配 Host 块的模式，支持通配符和 ! 取反
+func sshConfigHostMatches(alias string, patterns []string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		if ok, _ := path.Match(pattern, alias); ok {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// 计算主机的实际参数：所有匹配的块中，每个参数以第一次出现的值为准
+func resolveSSHConfigHost(alias string, blocks []*sshConfigBlock) SSHConfigHost {
+	values := make(map[string]string)
+	for _, block := range blocks {
+		if !sshConfigHostMatches(alias, block.patterns) {
+			continue
+		}
+		for _, option := range block.options {
+			if _, ok := values[option[0]]; !ok {
+				values[option[0]] = option[1]
+			}
+		}
+	}
+
+	host := SSHConfigHost{
+		Alias:    alias,
+		HostName: alias,
+		Port:     22,
+		User:     values["user"],
+	}
+	// 未指定用户时与 ssh 一样使用当前登录用户
+	if host.User == "" {
+		if current, err := user.Current(); err == nil {
+			host.User = current.Username
+		}
+	}
+	if hostName := values["hostname"]; hostName != "" {
+		host.HostName = strings.ReplaceAll(hostName, "%h", alias)
+	}
+	if proxyJump := values["proxyjump"]; proxyJump != "" && !strings.EqualFold(proxyJump, "none") {
+		hops := strings.Split(proxyJump, ",")
+		host.ProxyJump = strings.TrimSpace(hops[len(hops)-1])
+	}
+	if port, err := strconv.Atoi(values["port"]); err == nil && port > 0 {
+		host.Port = port
+	}
+	if identityFile := values["identityfile"]; identityFile != "" && !strings.EqualFold(identityFile, "none") {
+		identityFile = strings.ReplaceAll(identityFile, "%d", ExpandHomePath("~"))
+		identityFile = strings.ReplaceAll(identityFile, "%h", host.HostName)
+		identityFile = strings.ReplaceAll(identityFile, "%r", host.User)
+		host.IdentityFile = filepath.Clean(ExpandHomePath(identityFile))
+	}
+	return host
+}
@@ -0,0 +1,393 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hugo-manager-go/config"
+)
+
+// 文件存储型部署目标的基本操作，路径均为相对部署目录、以 / 分隔
+type storageTarget interface {
+	// 检查目标是否可用
+	Check(ctx context.Context) error
+	// 读取文件内容，文件不存在时返回 os.ErrNotExist
+	ReadFile(ctx context.Context, relPath string) ([]byte, error)
+	// 上传文件内容，info 为本地文件的信息
+	PutFile(ctx context.Context, relPath string, reader io.Reader, info os.FileInfo) error
+	// 写入数据
+	PutBytes(ctx context.Context, relPath string, data []byte) error
+	// 列出目标上的所有文件
+	List(ctx context.Context) ([]string, error)
+	// 删除文件
+	Remove(ctx context.Context, relPaths []string) error
+}
+
+// 可以在部署目录之外保存内容清单的部署目标
+// 对象存储没有网站访问不到的位置，不实现此接口，只使用本地缓存的内容清单
+type manifestStore interface {
+	// 读取内容清单，不存在时返回 os.ErrNotExist
+	ReadManifest() ([]byte, error)
+	// 写入内容清单
+	WriteManifest(data []byte) error
+}
+
+// 文件存储型部署目标：与SSH部署使用相同的内容清单和镜像删除规则
+type storageDeployer struct {
+	server config.ServerConfig
+	target storageTarget
+}
+
+// 待上传的文件
+type storageTask struct {
+	relPath   string
+	localFile string
+	info      os.FileInfo
+	hash      string
+}
+
+func (d *storageDeployer) TestConnection(ctx context.Context) error {
+	return d.target.Check(ctx)
+}
+
+func (d *storageDeployer) PreviewMirrorDeletions(ctx context.Context, localPath string) (*MirrorPlan, error) {
+	return d.planMirrorDeletions(ctx, localPath)
+}
+
+// 计算镜像删除计划
+func (d *storageDeployer) planMirrorDeletions(ctx context.Context, localPath string) (*MirrorPlan, error) {
+	ignore, err := loadServerDeployIgnore(d.server)
+	if err != nil {
+		return nil, err
+	}
+	localFiles, excluded, err := collectLocalRelPaths(localPath, ignore)
+	if err != nil {
+		return nil, fmt.Errorf("收集本地文件失败: %v", err)
+	}
+
+	remoteFiles, err := d.target.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("列出目标文件失败: %v", err)
+	}
+
+	plan := buildMirrorPlan(localFiles, remoteFiles, d.server.ProtectedPaths, ignore)
+	plan.Excluded = excluded
+	return plan, nil
+}
+
+// 内容清单对应的部署位置
+func (d *storageDeployer) manifestTarget() string {
+	if ServerDeployerType(d.server) == DeployerTypeS3 {
+		return "s3://" + d.server.S3Endpoint + "/" + d.server.S3Bucket + "/" + strings.Trim(d.server.RemotePath, "/")
+	}
+	return d.server.RemotePath
+}
+
+// 读取上次部署的内容清单，没有则读取部署目录中的旧清单
+// 目标上没有清单时使用同一部署位置的本地缓存，读取失败或目标不能保存内容清单时使用本地缓存
+func (d *storageDeployer) loadManifest(ctx context.Context) *DeployManifest {
+	store, hasStore := d.target.(manifestStore)
+	var data []byte
+	err := os.ErrNotExist
+	if hasStore {
+		data, err = store.ReadManifest()
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = d.target.ReadFile(ctx, legacyManifestFileName)
+	}
+	if err == nil {
+		if manifest, err := parseDeployManifest(data); err == nil {
+			return manifest
+		}
+	} else if errors.Is(err, os.ErrNotExist) && hasStore {
+		if cached := loadCachedManifestFor(d.server.ID, d.manifestTarget()); cached != nil {
+			fmt.Println("目标上没有内容清单，使用本地缓存")
+			return cached
+		}
+		fmt.Println("目标上没有内容清单，将上传所有文件")
+		return NewDeployManifest()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("读取内容清单失败，使用本地缓存: %v\n", err)
+	}
+
+	if cached, cacheErr := LoadCachedManifest(d.server.ID); cacheErr == nil {
+		return cached
+	}
+	return NewDeployManifest()
+}
+
+// 保存内容清单到目标（部署目录之外）和本地缓存，并删除部署目录中的旧清单
+// 返回写入目标上的内容清单的错误
+func (d *storageDeployer) saveManifest(ctx context.Context, manifest *DeployManifest) error {
+	manifest.UpdatedAt = time.Now()
+	manifest.Target = d.manifestTarget()
+
+	var writeErr error
+	if store, ok := d.target.(manifestStore); ok {
+		data, err := json.Marshal(manifest)
+		if err == nil {
+			err = store.WriteManifest(data)
+		}
+		if err != nil {
+			fmt.Printf("写入内容清单失败: %v\n", err)
+			writeErr = err
+		}
+	}
+	if err := d.target.Remove(ctx, []string{legacyManifestFileName}); err != nil {
+		fmt.Printf("删除旧内容清单失败: %v\n", err)
+	}
+	if err := saveCachedManifest(d.server.ID, manifest); err != nil {
+		fmt.Printf("保存本地内容清单缓存失败: %v\n", err)
+	}
+	return writeErr
+}
+
+// 收集需要上传的文件，跳过被排除规则匹配的文件，增量模式下跳过内容与清单一致的文件
+func (d *storageDeployer) collectTasks(localPath string, incremental bool, manifest *DeployManifest) ([]storageTask, error) {
+	ignore, err := loadServerDeployIgnore(d.server)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []storageTask
+	err = filepath.Walk(localPath, func(localFile string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(localPath, localFile)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if info.IsDir() {
+			if relPath != "." && ignore.skipDir(relPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ignore.Match(relPath, false) {
+			return nil
+		}
+
+		hash, err := HashFile(localFile)
+		if err != nil {
+			return err
+		}
+		if incremental && manifest.Files[relPath] == hash {
+			return nil
+		}
+
+		tasks = append(tasks, storageTask{
+			relPath:   relPath,
+			localFile: localFile,
+			info:      info,
+			hash:      hash,
+		})
+		return nil
+	})
+	return tasks, err
+}
+
+// 上传单个文件，经过共享的带宽限制器和传输计量
+func (d *storageDeployer) putFile(ctx context.Context, task storageTask, limiter *bandwidthLimiter, meter *transferMeter) error {
+	localFile, err := os.Open(task.localFile)
+	if err != nil {
+		return fmt.Errorf("无法打开本地文件: %v", err)
+	}
+	defer localFile.Close()
+
+	return d.target.PutFile(ctx, task.relPath, newThrottledReader(localFile, limiter, meter), task.info)
+}
+
+// 按服务器的并发上传数和带宽限制上传文件，返回每个失败文件的错误
+func (d *storageDeployer) upload(ctx context.Context, tasks []storageTask, meter *transferMeter) map[string]string {
+	var (
+		completed int32
+		wg        sync.WaitGroup
+		mutex     sync.Mutex
+		failures  = make(map[string]string)
+	)
+	limiter := newBandwidthLimiter(d.server.BandwidthLimit)
+
+	// 每秒采样一次传输速度，同时更新服务器状态
+	var currentSpeed atomic.Value
+	currentSpeed.Store("")
+	stopSpeed := make(chan struct{})
+	defer close(stopSpeed)
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				speed := meter.sample()
+				currentSpeed.Store(speed)
+				config.SetServerDeploymentSpeed(d.server.ID, speed)
+			case <-stopSpeed:
+				return
+			}
+		}
+	}()
+
+	taskChan := make(chan storageTask, len(tasks))
+	for _, task := range tasks {
+		taskChan <- task
+	}
+	close(taskChan)
+
+	for i := 0; i < effectiveUploadWorkers(d.server.UploadWorkers); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range taskChan {
+				if ctx.Err() != nil {
+					mutex.Lock()
+					failures[task.relPath] = ctx.Err().Error()
+					mutex.Unlock()
+					continue
+				}
+
+				err := d.putFile(ctx, task, limiter, meter)
+				if err != nil {
+					fmt.Printf("文件上传失败: %s, 错误: %v\n", task.relPath, err)
+					mutex.Lock()
+					failures[task.relPath] = err.Error()
+					mutex.Unlock()
+				}
+
+				current := int(atomic.AddInt32(&completed, 1))
+				BroadcastTransferProgress(d.server.ID, d.server.Name,
+					fmt.Sprintf("正在上传文件 (%d/%d)", current, len(tasks)),
+					current*100/len(tasks), len(tasks), current, task.relPath, currentSpeed.Load().(string))
+			}
+		}()
+	}
+	wg.Wait()
+	return failures
+}
+
+// 执行镜像删除，返回删除的文件列表
+func (d *storageDeployer) runMirrorDeletions(ctx context.Context, localPath string, manifest *DeployManifest) ([]string, error) {
+	if !d.server.MirrorDeletions {
+		return nil, nil
+	}
+
+	plan, err := d.planMirrorDeletions(ctx, localPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.ToDelete) == 0 {
+		return nil, nil
+	}
+
+	broadcastDeployProgress(d.server.ID, d.server.Name,
+		fmt.Sprintf("正在删除 %d 个本地已不存在的文件...", len(plan.ToDelete)), 100, 0, 0, "")
+
+	if err := d.target.Remove(ctx, plan.ToDelete); err != nil {
+		return nil, err
+	}
+	for _, relPath := range plan.ToDelete {
+		delete(manifest.Files, relPath)
+	}
+	return plan.ToDelete, nil
+}
+
+func (d *storageDeployer) Deploy(ctx context.Context, localPath string, incremental bool) (*DeployResult, error) {
+	if _, err := os.Stat(localPath); err != nil {
+		return &DeployResult{
+			Success: false,
+			Message: fmt.Sprintf("本地目录不存在: %s", localPath),
+		}, err
+	}
+
+	if err := d.target.Check(ctx); err != nil {
+		return &DeployResult{
+			Success: false,
+			Message: fmt.Sprintf("部署目标不可用: %v", err),
+		}, err
+	}
+
+	manifest := d.loadManifest(ctx)
+
+	tasks, err := d.collectTasks(localPath, incremental, manifest)
+	if err != nil {
+		return &DeployResult{
+			Success: false,
+			Message: fmt.Sprintf("收集文件任务失败: %v", err),
+		}, err
+	}
+
+	result := &DeployResult{}
+	if len(tasks) > 0 {
+		broadcastDeployProgress(d.server.ID, d.server.Name, "正在准备文件传输...", 0, len(tasks), 0, "")
+	}
+	meter := newTransferMeter()
+	failures := d.upload(ctx, tasks, meter)
+
+	// 上传成功的文件记录新的哈希，失败的文件从清单中移除
+	for _, task := range tasks {
+		if errMsg, failed := failures[task.relPath]; failed {
+			delete(manifest.Files, task.relPath)
+			result.FailedFiles = append(result.FailedFiles, config.DeploymentFileError{
+				File:  task.relPath,
+				Error: errMsg,
+			})
+			continue
+		}
+		manifest.Files[task.relPath] = task.hash
+		result.UploadedFiles = append(result.UploadedFiles, task.relPath)
+		result.BytesTransferred += task.info.Size()
+	}
+
+	if err := ctx.Err(); err != nil {
+		d.saveManifest(context.Background(), manifest)
+		return &DeployResult{
+			Success: false,
+			Message: fmt.Sprintf("文件传输失败: %v", err),
+		}, err
+	}
+
+	// 上传完成后再删除多余文件，避免删除后新页面尚未上传
+	deleted, err := d.runMirrorDeletions(ctx, localPath, manifest)
+	manifestErr := d.saveManifest(ctx, manifest)
+	if err != nil {
+		return &DeployResult{
+			Success: false,
+			Message: fmt.Sprintf("文件已上传，但镜像删除失败: %v", err),
+		}, err
+	}
+
+	result.Success = true
+	result.FilesDeployed = len(result.UploadedFiles)
+	result.FilesFailed = len(result.FailedFiles)
+	result.FilesDeleted = len(deleted)
+	result.DeletedFiles = deleted
+	if len(tasks) == 0 {
+		result.Message = "没有文件需要传输"
+		result.Output = "所有文件都是最新的"
+	} else {
+		result.Message = "文件传输完成"
+		result.Output = fmt.Sprintf("成功传输 %d 个文件，共 %d 字节", result.FilesDeployed, result.BytesTransferred)
+		if result.FilesFailed > 0 {
+			result.Output += fmt.Sprintf("，%d 个文件上传失败", result.FilesFailed)
+		}
+		result.Speed = meter.average()
+		result.Output += fmt.Sprintf("，平均速度 %s", result.Speed)
+	}
+	if len(deleted) > 0 {
+		result.Output += fmt.Sprintf("，删除了 %d 个文件", len(deleted))
+	}
+	result.Message += manifestWarning(manifestErr)
+	return result, nil
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 默认的并发上传数
+const DefaultUploadWorkers = 4
+
+// 并发上传数上限，避免配置错误时打开过多会话
+const MaxUploadWorkers = 32
+
+// 限速时单次读取的最大字节数，越小速率越平滑
+const throttleChunkSize = 32 * 1024
+
+// 获取实际使用的并发上传数
+func effectiveUploadWorkers(workers int) int {
+	if workers <= 0 {
+		return DefaultUploadWorkers
+	}
+	if workers > MaxUploadWorkers {
+		return MaxUploadWorkers
+	}
+	return workers
+}
+
+// 校验并发上传数和带宽限制
+func ValidateTransferLimits(workers int, bandwidthLimit int64) error {
+	if workers < 0 || workers > MaxUploadWorkers {
+		return fmt.Errorf("并发上传数必须在 0-%d 之间", MaxUploadWorkers)
+	}
+	if bandwidthLimit < 0 {
+		return fmt.Errorf("带宽限制不能为负数")
+	}
+	return nil
+}
+
+// 带宽限制器：所有上传worker共享，限制总的传输速率
+type bandwidthLimiter struct {
+	rate  int64 // 每秒字节数
+	mutex sync.Mutex
+	next  time.Time // 下一次可以传输的时间
+}
+
+// 创建带宽限制器，rate 为 0 时不限速（返回 nil）
+func newBandwidthLimiter(rate int64) *bandwidthLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &bandwidthLimiter{rate: rate}
+}
+
+// 预约传输 n 个字节，必要时等待
+func (l *bandwidthLimiter) wait(n int) {
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	l.mutex.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// 单次读取的字节数：限速较低时使用更小的块，避免突发
+func (l *bandwidthLimiter) chunkSize() int {
+	size := int(l.rate / 4)
+	if size < 512 {
+		size = 512
+	}
+	if size > throttleChunkSize {
+		size = throttleChunkSize
+	}
+	return size
+}
+
+// 传输计量：统计已传输的字节数，用于计算实时速度
+type transferMeter struct {
+	bytes     int64
+	startTime time.Time
+
+	mutex      sync.Mutex
+	lastBytes  int64
+	lastSample time.Time
+}
+
+func newTransferMeter() *transferMeter {
+	now := time.Now()
+	return &transferMeter{startTime: now, lastSample: now}
+}
+
+func (m *transferMeter) add(n int) {
+	atomic.AddInt64(&m.bytes, int64(n))
+}
+
+// 自上次采样以来的传输速度
+func (m *transferMeter) sample() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	bytes := atomic.LoadInt64(&m.bytes)
+	elapsed := now.Sub(m.lastSample).Seconds()
+	if elapsed <= 0 {
+		return ""
+	}
+	speed := float64(bytes-m.lastBytes) / elapsed
+	m.lastBytes = bytes
+	m.lastSample = now
+	return formatSpeed(speed)
+}
+
+// 整个传输过程的平均速度
+func (m *transferMeter) average() string {
+	elapsed := time.Since(m.startTime).Seconds()
+	if elapsed <= 0 {
+		return ""
+	}
+	return formatSpeed(float64(atomic.LoadInt64(&m.bytes)) / elapsed)
+}
+
+// 格式化传输速度
+func formatSpeed(bytesPerSecond float64) string {
+	const unit = 1024
+	if bytesPerSecond < unit {
+		return fmt.Sprintf("%.0f B/s", bytesPerSecond)
+	}
+	div, exp := float64(unit), 0
+	for n := bytesPerSecond / unit; n >= unit && exp < 3; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB/s", bytesPerSecond/div, "KMGT"[exp])
+}
+
+// 限速并计量的读取器
+type throttledReader struct {
+	reader  io.Reader
+	limiter *bandwidthLimiter
+	meter   *transferMeter
+}
+
+// 包装读取器：limiter 和 meter 均可为 nil
+func newThrottledReader(reader io.Reader, limiter *bandwidthLimiter, meter *transferMeter) io.Reader {
+	if limiter == nil && meter == nil {
+		return reader
+	}
+	return &throttledReader{reader: reader, limiter: limiter, meter: meter}
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if r.limiter != nil {
+		if size := r.limiter.chunkSize(); len(p) > size {
+			p = p[:size]
+		}
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if r.limiter != nil {
+			r.limiter.wait(n)
+		}
+		if r.meter != nil {
+			r.meter.add(n)
+		}
+	}
+	return n, err
+}
@@ -220,6 +220,31 @@ func BroadcastMultiServerProgress(serverID, serverName, msgType, status, message
 	}
 }
 
+// 广播文件传输进度（附带实时传输速度），serverID 为空时是单服务器部署
+func BroadcastTransferProgress(serverID, serverName, message string, progress, total, current int, currentFile, speed string) {
+	progressMsg := ProgressMessage{
+		Type:        "deploy",
+		Status:      "deploying",
+		Message:     message,
+		Progress:    progress,
+		Total:       total,
+		Current:     current,
+		CurrentFile: currentFile,
+		Speed:       speed,
+		Timestamp:   time.Now(),
+		ServerID:    serverID,
+		ServerName:  serverName,
+	}
+
+	select {
+	case Manager.broadcast <- progressMsg:
+		// 消息已发送到广播通道
+	default:
+		// 通道已满，跳过这条消息
+		log.Printf("传输进度广播通道已满，跳过消息: %s", message)
+	}
+}
+
 // 广播构建进度
 func BroadcastBuildProgress(message string, progress int) {
 	BroadcastProgress("build", "building", message, progress, 100, progress, "")